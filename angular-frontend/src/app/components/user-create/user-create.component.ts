@@ -2,6 +2,7 @@ import { Component, OnInit } from '@angular/core';
 import { Router } from '@angular/router';
 import { FormBuilder, FormGroup, Validators } from '@angular/forms';
 import { ApiService } from '../../services/api.service';
+import { applyProblem } from '../../models/problem.model';
 
 const VALID_USER_STATUSES = ['A', 'I', 'T'];
 
@@ -25,7 +26,7 @@ export class UserCreateComponent implements OnInit {
       last_name: ['', [Validators.required, Validators.maxLength(50)]],
       email: ['', [Validators.required, Validators.email, Validators.maxLength(100)]],
       user_status: ['', [Validators.required, Validators.pattern(VALID_USER_STATUSES.join('|'))]],
-      department: ['', [Validators.required, Validators.maxLength(50)]]
+      department: ['', [Validators.required, Validators.maxLength(255)]]
     });
   }
 
@@ -38,7 +39,7 @@ export class UserCreateComponent implements OnInit {
           this.router.navigate(['/users']);
         },
         (error) => {
-          this.errorMessage = error.error;
+          this.errorMessage = applyProblem(this.userForm, error);
         }
       );
     }
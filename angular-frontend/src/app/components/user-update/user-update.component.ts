@@ -2,6 +2,7 @@ import { Component, OnInit } from '@angular/core';
 import { ActivatedRoute, Router } from '@angular/router';
 import { FormBuilder, FormGroup, Validators } from '@angular/forms';
 import { ApiService } from '../../services/api.service';
+import { applyProblem } from '../../models/problem.model';
 
 const VALID_USER_STATUSES = ['A', 'I', 'T'];
 
@@ -12,6 +13,7 @@ const VALID_USER_STATUSES = ['A', 'I', 'T'];
 })
 export class UserUpdateComponent implements OnInit {
   userId!: number;
+  etag: string | null = null;
   userForm: FormGroup;
   errorMessage: string | null = null;
 
@@ -27,25 +29,32 @@ export class UserUpdateComponent implements OnInit {
       last_name: ['', [Validators.required, Validators.maxLength(50)]],
       email: ['', [Validators.required, Validators.email, Validators.maxLength(100)]],
       user_status: ['', [Validators.required, Validators.pattern(VALID_USER_STATUSES.join('|'))]],
-      department: ['', [Validators.required, Validators.maxLength(50)]]
+      department: ['', [Validators.required, Validators.maxLength(255)]],
+      // Updates replace the whole user, so the manager is sent back as read.
+      manager_id: [null as number | null, [Validators.min(1)]]
     });
   }
 
   ngOnInit(): void {
     this.userId = this.route.snapshot.params['id'];
-    this.apiService.getUserById(this.userId).subscribe(user => {
-      this.userForm.patchValue(user);
+    this.apiService.getUserWithEtag(this.userId).subscribe(response => {
+      this.etag = response.headers.get('ETag');
+      this.userForm.patchValue(response.body ?? {});
     });
   }
 
   onSubmit(): void {
     if (this.userForm.valid) {
-      this.apiService.updateUser(this.userId, this.userForm.value).subscribe(
+      this.apiService.updateUser(this.userId, this.userForm.value, this.etag).subscribe(
         () => {
           this.router.navigate(['/users']);
         },
         (error) => {
-          this.errorMessage = error.error;
+          if (error.status === 412) {
+            this.errorMessage = 'This user was changed by someone else. Reload the page to see the latest version.';
+          } else {
+            this.errorMessage = applyProblem(this.userForm, error);
+          }
         }
       );
     }
@@ -0,0 +1,35 @@
+import { HttpErrorResponse } from '@angular/common/http';
+import { FormControl, FormGroup } from '@angular/forms';
+import { applyProblem } from './problem.model';
+
+describe('applyProblem', () => {
+  it('should mark the failing controls with the matching validator errors', () => {
+    const form = new FormGroup({
+      user_name: new FormControl('j doe'),
+      email: new FormControl('x'),
+      user_status: new FormControl('X')
+    });
+    const error = new HttpErrorResponse({
+      status: 400,
+      error: {
+        type: 'about:blank',
+        title: 'Bad Request',
+        status: 400,
+        detail: 'one or more fields are invalid',
+        code: 'validation_failed',
+        errors: [
+          { field: 'email', rule: 'email', message: 'email must be a valid email address' },
+          { field: 'user_status', rule: 'oneof', param: 'A I T', message: 'user_status must be one of [A I T]' },
+          { field: 'user_name', rule: 'alphanum', message: 'user_name can only contain alphanumeric characters' }
+        ]
+      }
+    });
+
+    const message = applyProblem(form, error);
+
+    expect(message).toBe('one or more fields are invalid');
+    expect(form.get('email')?.hasError('email')).toBeTrue();
+    expect(form.get('user_status')?.getError('pattern')).toBe('A I T');
+    expect(form.get('user_name')?.getError('server')).toBe('user_name can only contain alphanumeric characters');
+  });
+});
@@ -0,0 +1,53 @@
+import { HttpErrorResponse } from '@angular/common/http';
+import { FormGroup } from '@angular/forms';
+
+// An RFC 7807 problem details body, as returned by every failing API call.
+export interface Problem {
+    type: string;
+    title: string;
+    status: number;
+    detail?: string;
+    instance?: string;
+    code?: string;
+    errors?: FieldError[];
+  }
+
+export interface FieldError {
+    field: string;
+    rule: string;
+    param?: string;
+    // message describes the failure in the language of Accept-Language.
+    message: string;
+  }
+
+// Server validation rules and the Angular validator errors they correspond
+// to, so the existing mat-error messages are shown for server-side failures.
+const RULE_ERRORS: Record<string, string> = {
+  required: 'required',
+  max: 'maxlength',
+  email: 'email',
+  oneof: 'pattern'
+};
+
+// applyProblem marks the form controls named in a validation problem as
+// invalid and returns the message to show for the request as a whole.
+export function applyProblem(form: FormGroup, error: HttpErrorResponse): string {
+  const problem = error.error as Problem | null;
+  if (!problem || typeof problem !== 'object') {
+    return error.message;
+  }
+
+  for (const fieldError of problem.errors ?? []) {
+    const control = form.get(fieldError.field);
+    if (control) {
+      const key = RULE_ERRORS[fieldError.rule];
+      if (key) {
+        control.setErrors({ ...control.errors, [key]: fieldError.param ?? true });
+      } else {
+        control.setErrors({ ...control.errors, server: fieldError.message });
+      }
+      control.markAsTouched();
+    }
+  }
+  return problem.detail ?? problem.title;
+}
@@ -6,5 +6,19 @@ export interface User {
     email: string;
     user_status: 'A' | 'I' | 'T';
     department: string;
+    manager_id?: number | null;
+  }
+
+export interface UserPage {
+    data: User[];
+    total: number;
+    limit: number;
+    offset: number;
+    next_cursor?: string;
+    prev_cursor?: string;
+    links: {
+      self: string;
+      next?: string;
+      prev?: string;
+    };
   }
-  
\ No newline at end of file
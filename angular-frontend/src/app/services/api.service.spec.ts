@@ -38,7 +38,7 @@ describe('ApiService', () => {
 
     const req = httpMock.expectOne(`${service.getApiUrl()}/users`);
     expect(req.request.method).toBe('GET');
-    req.flush(dummyUsers);
+    req.flush({ data: dummyUsers, total: 2, limit: 50, offset: 0, links: { self: '/users' } });
   });
 
   it('should create a user', () => {
@@ -1,8 +1,8 @@
-import { HttpClient } from '@angular/common/http';
+import { HttpClient, HttpHeaders, HttpResponse } from '@angular/common/http';
 import { Injectable } from '@angular/core';
 import { environment } from '../../environments/environment';
-import { Observable } from 'rxjs';
-import { User } from '../models/user.model'; // Import the User type from the models folder
+import { Observable, map } from 'rxjs';
+import { User, UserPage } from '../models/user.model'; // Import the User type from the models folder
 
 @Injectable({
   providedIn: 'root'
@@ -13,19 +13,30 @@ export class ApiService {
   constructor(private http: HttpClient) {}
 
   getUsers(): Observable<User[]> {
-    return this.http.get<User[]>(`${this.apiUrl}/users`); // Specify the type of the response as User[]
+    return this.getUserPage().pipe(map(page => page.data));
+  }
+
+  getUserPage(params: Record<string, string | number> = {}): Observable<UserPage> {
+    return this.http.get<UserPage>(`${this.apiUrl}/users`, { params });
   }
 
   getUserById(id: number) {
     return this.http.get(`${this.apiUrl}/users/${id}`);
   }
 
+  // Returns the full response so callers can read the ETag header and send
+  // it back in If-Match when saving.
+  getUserWithEtag(id: number): Observable<HttpResponse<User>> {
+    return this.http.get<User>(`${this.apiUrl}/users/${id}`, { observe: 'response' });
+  }
+
   createUser(user: any) {
     return this.http.post(`${this.apiUrl}/users`, user);
   }
 
-  updateUser(id: number, user: any) {
-    return this.http.put(`${this.apiUrl}/users/${id}`, user);
+  updateUser(id: number, user: any, etag?: string | null) {
+    const headers = etag ? new HttpHeaders({ 'If-Match': etag }) : undefined;
+    return this.http.put(`${this.apiUrl}/users/${id}`, user, { headers });
   }
 
   deleteUser(id: number) {
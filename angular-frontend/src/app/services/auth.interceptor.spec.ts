@@ -0,0 +1,41 @@
+import { TestBed } from '@angular/core/testing';
+import { HTTP_INTERCEPTORS, HttpClient } from '@angular/common/http';
+import { HttpClientTestingModule, HttpTestingController } from '@angular/common/http/testing';
+import { API_TOKEN_KEY, AuthInterceptor } from './auth.interceptor';
+
+describe('AuthInterceptor', () => {
+  let http: HttpClient;
+  let httpMock: HttpTestingController;
+
+  beforeEach(() => {
+    TestBed.configureTestingModule({
+      imports: [HttpClientTestingModule],
+      providers: [{ provide: HTTP_INTERCEPTORS, useClass: AuthInterceptor, multi: true }]
+    });
+
+    http = TestBed.inject(HttpClient);
+    httpMock = TestBed.inject(HttpTestingController);
+  });
+
+  afterEach(() => {
+    httpMock.verify();
+    localStorage.removeItem(API_TOKEN_KEY);
+  });
+
+  it('should send the stored token as a bearer token', () => {
+    localStorage.setItem(API_TOKEN_KEY, 'abc.def.ghi');
+    http.get('/users').subscribe();
+
+    const req = httpMock.expectOne('/users');
+    expect(req.request.headers.get('Authorization')).toBe('Bearer abc.def.ghi');
+    req.flush({});
+  });
+
+  it('should not send an Authorization header without a token', () => {
+    http.get('/users').subscribe();
+
+    const req = httpMock.expectOne('/users');
+    expect(req.request.headers.has('Authorization')).toBeFalse();
+    req.flush({});
+  });
+});
@@ -0,0 +1,17 @@
+import { Injectable } from '@angular/core';
+import { HttpEvent, HttpHandler, HttpInterceptor, HttpRequest } from '@angular/common/http';
+import { Observable } from 'rxjs';
+
+// The localStorage key of the bearer token sent with every API request.
+export const API_TOKEN_KEY = 'apiToken';
+
+@Injectable()
+export class AuthInterceptor implements HttpInterceptor {
+  intercept(req: HttpRequest<unknown>, next: HttpHandler): Observable<HttpEvent<unknown>> {
+    const token = localStorage.getItem(API_TOKEN_KEY);
+    if (!token) {
+      return next.handle(req);
+    }
+    return next.handle(req.clone({ setHeaders: { Authorization: `Bearer ${token}` } }));
+  }
+}
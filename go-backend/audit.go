@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// auditCommand verifies the hash chain of the audit log. It fails when a
+// link is broken, so it can run from cron or CI.
+func auditCommand(args []string) error {
+	if len(args) != 1 || args[0] != "verify" {
+		return errors.New("usage: audit verify")
+	}
+
+	repo, closeRepo, err := openUserRepository()
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	report, err := repository.VerifyAuditChain(context.Background(), repo)
+	if err != nil {
+		return err
+	}
+	if report.Unsealed > 0 {
+		fmt.Printf("%d entries predate the chain\n", report.Unsealed)
+	}
+	if !report.Valid {
+		return fmt.Errorf("audit chain broken at entry %d: %s", report.Broken.AuditID, report.Broken.Reason)
+	}
+	fmt.Printf("%d entries verified, head %s\n", report.Entries-report.Unsealed, report.Head)
+	return nil
+}
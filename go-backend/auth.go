@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/auth"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// publicPaths are served without a token.
+var publicPaths = []string{"/swagger/*", "/healthz"}
+
+// authConfig reads the token verification settings from the environment:
+// JWT_SECRET for HS256 tokens, JWT_JWKS_FILE for RS256 and ES256 tokens and
+// the optional JWT_ISSUER and JWT_AUDIENCE.
+func authConfig() (auth.Config, error) {
+	config := auth.Config{
+		Secret:   []byte(os.Getenv("JWT_SECRET")),
+		Issuer:   os.Getenv("JWT_ISSUER"),
+		Audience: os.Getenv("JWT_AUDIENCE"),
+		Public:   publicPaths,
+	}
+	if path := os.Getenv("JWT_JWKS_FILE"); path != "" {
+		keys, err := auth.LoadKeySet(path)
+		if err != nil {
+			return config, err
+		}
+		config.Keys = keys
+	}
+	if len(config.Secret) == 0 && config.Keys == nil {
+		return config, errors.New("set JWT_SECRET or JWT_JWKS_FILE to authenticate API requests")
+	}
+	return config, nil
+}
+
+// subjectRoles looks up the grants of the user whose user name is the
+// token's subject. Subjects that are not users have none.
+func subjectRoles(repo repository.UserRepository) auth.RolesFunc {
+	return func(ctx context.Context, subject string) ([]models.Grant, error) {
+		user, err := repo.GetByUsername(ctx, subject)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return repo.UserRoles(ctx, user.UserID)
+	}
+}
+
+// apiKeyStore serves the API keys of a repository to auth.Middleware.
+type apiKeyStore struct {
+	keys repository.APIKeyRepository
+}
+
+func (s apiKeyStore) APIKey(ctx context.Context, prefix string) (*models.APIKey, error) {
+	key, err := s.keys.APIKeyByPrefix(ctx, prefix)
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return nil, nil
+	}
+	return key, err
+}
+
+func (s apiKeyStore) KeyUsed(ctx context.Context, id int64, at time.Time) error {
+	return s.keys.TouchAPIKey(ctx, id, at.UTC().Truncate(time.Microsecond))
+}
+
+type claimFlags map[string]interface{}
+
+func (f claimFlags) String() string { return fmt.Sprint(map[string]interface{}(f)) }
+
+func (f claimFlags) Set(value string) error {
+	name, v, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("claim %q is not name=value", value)
+	}
+	f[name] = v
+	return nil
+}
+
+// tokenCommand prints an HS256 token for subject, for development and
+// scripts against a server sharing the same JWT_SECRET.
+func tokenCommand(args []string) error {
+	flags := flag.NewFlagSet("token", flag.ExitOnError)
+	ttl := flags.Duration("ttl", time.Hour, "lifetime of the token")
+	extra := claimFlags{}
+	flags.Var(extra, "claim", "extra name=value claim (repeatable)")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: token [-ttl 1h] [-claim k=v] <subject>")
+	}
+
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return errors.New("JWT_SECRET is not set")
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{}
+	for name, value := range extra {
+		claims[name] = value
+	}
+	claims["sub"] = flags.Arg(0)
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(*ttl).Unix()
+	if iss := os.Getenv("JWT_ISSUER"); iss != "" {
+		claims["iss"] = iss
+	}
+	if aud := os.Getenv("JWT_AUDIENCE"); aud != "" {
+		claims["aud"] = aud
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		return err
+	}
+	fmt.Println(signed)
+	return nil
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+const scopesKey = "auth.scopes"
+
+// APIKeyPrefix starts every API key, which reads ik_<prefix>_<secret>.
+const APIKeyPrefix = "ik_"
+
+// APIKeyHeader carries an API key for clients that cannot send it as a
+// bearer token.
+const APIKeyHeader = "X-API-Key"
+
+// touchInterval limits how often the last use of a key is written back.
+const touchInterval = time.Minute
+
+// APIKeyStore looks up the API keys accepted by Middleware.
+type APIKeyStore interface {
+	// APIKey returns the key with prefix, or nil if there is none.
+	APIKey(ctx context.Context, prefix string) (*models.APIKey, error)
+	// KeyUsed records that key id authenticated a request at the given time.
+	KeyUsed(ctx context.Context, id int64, at time.Time) error
+}
+
+// GenerateAPIKey returns a new API key together with its prefix, which
+// identifies it, and the hash of its secret, which is what gets stored.
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+	prefix = hex.EncodeToString(id)
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	return APIKeyPrefix + prefix + "_" + encoded, prefix, HashAPISecret(encoded), nil
+}
+
+// HashAPISecret returns the stored hash of the secret part of an API key.
+// The secret is random, so a plain SHA-256 is as hard to reverse as a slow
+// password hash.
+func HashAPISecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseAPIKey splits key into its prefix and secret.
+func parseAPIKey(key string) (prefix, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(key, APIKeyPrefix)
+	if !ok {
+		return "", "", false
+	}
+	prefix, secret, ok = strings.Cut(rest, "_")
+	return prefix, secret, ok && prefix != "" && secret != ""
+}
+
+// verifyAPIKey returns the stored key that raw is, provided it is neither
+// revoked nor expired.
+func verifyAPIKey(ctx context.Context, store APIKeyStore, raw string, now time.Time) (*models.APIKey, error) {
+	prefix, secret, ok := parseAPIKey(raw)
+	if !ok {
+		return nil, errors.New("malformed api key")
+	}
+	key, err := store.APIKey(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || subtle.ConstantTimeCompare([]byte(HashAPISecret(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, errors.New("unknown api key")
+	}
+	if key.RevokedAt != nil {
+		return nil, errors.New("api key revoked")
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, errors.New("api key expired")
+	}
+	return key, nil
+}
+
+// authenticateKey authenticates c with raw, an API key. Its subject is
+// apikey:<prefix> and its permissions are the key's scopes.
+func authenticateKey(c echo.Context, store APIKeyStore, raw string) error {
+	ctx := c.Request().Context()
+	now := time.Now()
+	key, err := verifyAPIKey(ctx, store, raw, now)
+	if err != nil {
+		return err
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= touchInterval {
+		if err := store.KeyUsed(ctx, key.ID, now); err != nil {
+			c.Logger().Warnf("recording use of api key %s: %v", key.Prefix, err)
+		}
+	}
+
+	scopes := make([]Permission, len(key.Scopes))
+	for i, scope := range key.Scopes {
+		scopes[i] = Permission(scope)
+	}
+	c.Set(subjectKey, "apikey:"+key.Prefix)
+	c.Set(scopesKey, scopes)
+	return nil
+}
+
+// APIKeyScopes returns the scopes of the API key that authenticated the
+// request; ok is false for requests authenticated otherwise.
+func APIKeyScopes(c echo.Context) (scopes []Permission, ok bool) {
+	scopes, ok = c.Get(scopesKey).([]Permission)
+	return scopes, ok
+}
@@ -0,0 +1,120 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sedmo/integra-coding-assessment/go-backend/auth"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+// keyStore is an auth.APIKeyStore over a map of keys by prefix.
+type keyStore struct {
+	keys map[string]*models.APIKey
+	used map[int64]time.Time
+}
+
+func (s *keyStore) APIKey(_ context.Context, prefix string) (*models.APIKey, error) {
+	return s.keys[prefix], nil
+}
+
+func (s *keyStore) KeyUsed(_ context.Context, id int64, at time.Time) error {
+	s.used[id] = at
+	return nil
+}
+
+var _ = Describe("API keys", func() {
+	var (
+		store *keyStore
+		e     *echo.Echo
+	)
+
+	add := func(scopes ...string) (string, *models.APIKey) {
+		raw, prefix, hash, err := auth.GenerateAPIKey()
+		Expect(err).NotTo(HaveOccurred())
+		key := &models.APIKey{ID: int64(len(store.keys) + 1), Prefix: prefix, Scopes: scopes, SecretHash: hash}
+		store.keys[prefix] = key
+		return raw, key
+	}
+
+	BeforeEach(func() {
+		store = &keyStore{keys: map[string]*models.APIKey{}, used: map[int64]time.Time{}}
+		e = echo.New()
+		e.Use(auth.Middleware(auth.Config{Secret: []byte("test-secret"), APIKeys: store}))
+		api := e.Group("", auth.Authorize(func(context.Context, string) ([]models.Grant, error) {
+			Fail("API keys must not be looked up as users")
+			return nil, nil
+		}))
+		api.GET("/users", func(c echo.Context) error {
+			return c.String(http.StatusOK, auth.Subject(c))
+		}, auth.Require(auth.ReadUsers))
+		api.DELETE("/users/1", func(c echo.Context) error {
+			return c.NoContent(http.StatusOK)
+		}, auth.Require(auth.DeleteUsers))
+	})
+
+	send := func(method, header, value string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "/users", nil)
+		if method == http.MethodDelete {
+			request = httptest.NewRequest(method, "/users/1", nil)
+		}
+		request.Header.Set(header, value)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, request)
+		return rec
+	}
+
+	It("should accept a key as a bearer token or in X-API-Key", func() {
+		raw, key := add("users:read")
+		Expect(raw).To(HavePrefix(auth.APIKeyPrefix + key.Prefix + "_"))
+
+		rec := send(http.MethodGet, echo.HeaderAuthorization, "Bearer "+raw)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal("apikey:" + key.Prefix))
+		Expect(send(http.MethodGet, auth.APIKeyHeader, raw).Code).To(Equal(http.StatusOK))
+		Expect(store.used).To(HaveKey(key.ID))
+	})
+
+	It("should only allow the scopes of the key", func() {
+		raw, _ := add("users:read")
+		rec := send(http.MethodDelete, auth.APIKeyHeader, raw)
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("should not write the last use of a key on every request", func() {
+		raw, key := add("users:read")
+		recent := time.Now().Add(-time.Second)
+		key.LastUsedAt = &recent
+		Expect(send(http.MethodGet, auth.APIKeyHeader, raw).Code).To(Equal(http.StatusOK))
+		Expect(store.used).To(BeEmpty())
+	})
+
+	It("should reject unknown, tampered, revoked and expired keys", func() {
+		raw, _ := add("users:read")
+		revokedRaw, revoked := add("users:read")
+		expiredRaw, expired := add("users:read")
+		past := time.Now().Add(-time.Minute)
+		revoked.RevokedAt = &past
+		expired.ExpiresAt = &past
+		other, _, _, _ := auth.GenerateAPIKey()
+
+		for _, key := range []string{
+			"ik_",
+			"ik_nosecret",
+			other,
+			raw[:len(raw)-1] + strings.Map(func(r rune) rune { return r ^ 1 }, raw[len(raw)-1:]),
+			revokedRaw,
+			expiredRaw,
+		} {
+			rec := send(http.MethodGet, auth.APIKeyHeader, key)
+			Expect(rec.Code).To(Equal(http.StatusUnauthorized), key)
+			Expect(rec.Header().Get(echo.HeaderWWWAuthenticate)).To(Equal(`Bearer error="invalid_token"`))
+		}
+	})
+})
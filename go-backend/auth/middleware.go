@@ -0,0 +1,159 @@
+// Package auth authenticates API requests with JWT bearer tokens or API
+// keys.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	subjectKey = "auth.subject"
+	claimsKey  = "auth.claims"
+)
+
+// Claims are the claims of a verified token, keyed by claim name.
+type Claims map[string]interface{}
+
+// Config configures Middleware. At least one of Secret, Keys and APIKeys
+// must be set.
+type Config struct {
+	// Secret verifies HS256 tokens. HS256 tokens are refused if it is empty.
+	Secret []byte
+	// Keys verifies RS256 and ES256 tokens. They are refused if it is nil.
+	Keys *KeySet
+	// APIKeys verifies API keys. They are refused if it is nil.
+	APIKeys APIKeyStore
+	// Issuer and Audience, if set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+	// Public lists the paths served without a token. A trailing * matches
+	// any suffix, e.g. /swagger/*.
+	Public []string
+}
+
+// Middleware rejects requests without a valid "Authorization: Bearer" token
+// with a 401, except for the Public paths. The bearer token is either a JWT
+// or an API key, which may also be sent in the X-API-Key header. The
+// token's subject and claims are available to handlers through Subject and
+// ClaimsFrom.
+func Middleware(config Config) echo.MiddlewareFunc {
+	if len(config.Secret) == 0 && config.Keys == nil && config.APIKeys == nil {
+		panic("auth: Middleware needs a Secret, Keys or APIKeys")
+	}
+
+	parser := &jwt.Parser{ValidMethods: []string{"HS256", "RS256", "ES256"}}
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		switch alg := token.Method.Alg(); alg {
+		case "HS256":
+			if len(config.Secret) == 0 {
+				return nil, errors.New("HS256 tokens are not accepted")
+			}
+			return config.Secret, nil
+		case "RS256", "ES256":
+			if config.Keys == nil {
+				return nil, fmt.Errorf("%s tokens are not accepted", alg)
+			}
+			kid, _ := token.Header["kid"].(string)
+			return config.Keys.key(kid, alg)
+		default:
+			return nil, fmt.Errorf("unsupported alg %q", alg)
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if isPublic(config.Public, c.Request().URL.Path) {
+				return next(c)
+			}
+
+			key := c.Request().Header.Get(APIKeyHeader)
+			scheme, raw, _ := strings.Cut(c.Request().Header.Get(echo.HeaderAuthorization), " ")
+			raw = strings.TrimSpace(raw)
+			if key == "" && strings.EqualFold(scheme, "Bearer") && strings.HasPrefix(raw, APIKeyPrefix) {
+				key = raw
+			}
+			if key != "" {
+				if config.APIKeys == nil {
+					return invalidToken(c, errors.New("api keys are not accepted"))
+				}
+				if err := authenticateKey(c, config.APIKeys, key); err != nil {
+					return invalidToken(c, err)
+				}
+				return next(c)
+			}
+			if !strings.EqualFold(scheme, "Bearer") || raw == "" {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return echo.NewHTTPError(http.StatusUnauthorized, "missing bearer token")
+			}
+
+			claims := jwt.MapClaims{}
+			_, err := parser.ParseWithClaims(raw, claims, keyFunc)
+			if err == nil {
+				err = verify(config, claims)
+			}
+			if err != nil {
+				return invalidToken(c, err)
+			}
+
+			c.Set(subjectKey, claims["sub"].(string))
+			c.Set(claimsKey, Claims(claims))
+			return next(c)
+		}
+	}
+}
+
+func invalidToken(c echo.Context, err error) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+	return echo.NewHTTPError(http.StatusUnauthorized, "invalid token: "+err.Error())
+}
+
+// verify checks the claims that jwt.MapClaims.Valid leaves to the caller.
+func verify(config Config, claims jwt.MapClaims) error {
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return errors.New("missing sub claim")
+	}
+	// Valid only checks exp when it is present; tokens must not live forever.
+	if _, ok := claims["exp"]; !ok {
+		return errors.New("missing exp claim")
+	}
+	if config.Issuer != "" && !claims.VerifyIssuer(config.Issuer, true) {
+		return errors.New("unexpected issuer")
+	}
+	if config.Audience != "" && !claims.VerifyAudience(config.Audience, true) {
+		return errors.New("unexpected audience")
+	}
+	return nil
+}
+
+func isPublic(public []string, path string) bool {
+	for _, p := range public {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Subject returns the sub claim of the request's token, or "" for a public
+// path.
+func Subject(c echo.Context) string {
+	sub, _ := c.Get(subjectKey).(string)
+	return sub
+}
+
+// ClaimsFrom returns the claims of the request's token, or nil for a public
+// path or an API key.
+func ClaimsFrom(c echo.Context) Claims {
+	claims, _ := c.Get(claimsKey).(Claims)
+	return claims
+}
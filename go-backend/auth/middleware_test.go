@@ -0,0 +1,172 @@
+package auth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sedmo/integra-coding-assessment/go-backend/auth"
+)
+
+func TestAuth(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Auth Suite")
+}
+
+func b64(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+var _ = Describe("Middleware", func() {
+	var (
+		secret   = []byte("test-secret")
+		rsaKey   *rsa.PrivateKey
+		ecKey    *ecdsa.PrivateKey
+		keysFile string
+		e        *echo.Echo
+	)
+
+	BeforeEach(func() {
+		var err error
+		rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+
+		jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N), "e": b64(big.NewInt(int64(rsaKey.E)))},
+			{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X), "y": b64(ecKey.Y)},
+		}})
+		keysFile = filepath.Join(GinkgoT().TempDir(), "jwks.json")
+		Expect(os.WriteFile(keysFile, jwks, 0o600)).To(Succeed())
+		keys, err := auth.LoadKeySet(keysFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		e = echo.New()
+		e.Use(auth.Middleware(auth.Config{
+			Secret:   secret,
+			Keys:     keys,
+			Audience: "integra",
+			Public:   []string{"/healthz", "/swagger/*"},
+		}))
+		whoami := func(c echo.Context) error {
+			return c.JSON(http.StatusOK, map[string]interface{}{"sub": auth.Subject(c), "claims": auth.ClaimsFrom(c)})
+		}
+		e.GET("/users", whoami)
+		e.GET("/healthz", whoami)
+		e.GET("/swagger/*", whoami)
+	})
+
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{"sub": "alice", "aud": "integra", "exp": time.Now().Add(time.Hour).Unix(), "role": "editor"}
+	}
+
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		Expect(err).NotTo(HaveOccurred())
+		return signed
+	}
+
+	get := func(path, authorization string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorization != "" {
+			request.Header.Set(echo.HeaderAuthorization, authorization)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, request)
+		return rec
+	}
+
+	expectSubject := func(rec *httptest.ResponseRecorder) {
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var body struct {
+			Sub    string
+			Claims map[string]interface{}
+		}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &body)).To(Succeed())
+		Expect(body.Sub).To(Equal("alice"))
+		Expect(body.Claims).To(HaveKeyWithValue("role", "editor"))
+	}
+
+	It("should accept HS256, RS256 and ES256 tokens", func() {
+		expectSubject(get("/users", "Bearer "+sign(jwt.SigningMethodHS256, "", secret, claims())))
+		expectSubject(get("/users", "Bearer "+sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, claims())))
+		expectSubject(get("/users", "Bearer "+sign(jwt.SigningMethodES256, "ec-1", ecKey, claims())))
+	})
+
+	It("should reject requests without a bearer token", func() {
+		rec := get("/users", "")
+		Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+		Expect(rec.Header().Get(echo.HeaderWWWAuthenticate)).To(Equal("Bearer"))
+
+		rec = get("/users", "Basic YWxpY2U6c2VjcmV0")
+		Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should reject invalid tokens", func() {
+		expired := claims()
+		expired["exp"] = time.Now().Add(-time.Minute).Unix()
+		noExpiry := claims()
+		delete(noExpiry, "exp")
+		noSubject := claims()
+		delete(noSubject, "sub")
+		otherAudience := claims()
+		otherAudience["aud"] = "someone-else"
+		otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+		for _, token := range []string{
+			"not-a-jwt",
+			sign(jwt.SigningMethodHS256, "", []byte("wrong-secret"), claims()),
+			sign(jwt.SigningMethodHS256, "", secret, expired),
+			sign(jwt.SigningMethodHS256, "", secret, noExpiry),
+			sign(jwt.SigningMethodHS256, "", secret, noSubject),
+			sign(jwt.SigningMethodHS256, "", secret, otherAudience),
+			sign(jwt.SigningMethodHS384, "", secret, claims()),
+			sign(jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, claims()),
+			sign(jwt.SigningMethodES256, "ec-1", otherKey, claims()),
+			sign(jwt.SigningMethodES256, "unknown", ecKey, claims()),
+			// Without a kid the set has no single key to pick.
+			sign(jwt.SigningMethodRS256, "", rsaKey, claims()),
+			// An RSA key id must not verify an ES256 signature.
+			sign(jwt.SigningMethodES256, "rsa-1", ecKey, claims()),
+		} {
+			rec := get("/users", "Bearer "+token)
+			Expect(rec.Code).To(Equal(http.StatusUnauthorized), token)
+			Expect(rec.Header().Get(echo.HeaderWWWAuthenticate)).To(Equal(`Bearer error="invalid_token"`))
+		}
+	})
+
+	It("should serve public paths without a token", func() {
+		Expect(get("/healthz", "").Code).To(Equal(http.StatusOK))
+		Expect(get("/swagger/index.html", "").Code).To(Equal(http.StatusOK))
+	})
+
+	It("should refuse malformed key sets", func() {
+		_, err := auth.ParseKeySet([]byte(`{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`))
+		Expect(err).To(MatchError(ContainSubstring("not on the curve")))
+
+		_, err = auth.ParseKeySet([]byte(`{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`))
+		Expect(err).To(MatchError(ContainSubstring("unsupported kty")))
+
+		_, err = auth.ParseKeySet([]byte(`{"keys": []}`))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+const grantsKey = "auth.grants"
+
+// Permission names an action on the API, e.g. users:delete.
+type Permission string
+
+const (
+	ReadUsers      Permission = "users:read"
+	CreateUsers    Permission = "users:create"
+	UpdateUsers    Permission = "users:update"
+	TerminateUsers Permission = "users:terminate"
+	DeleteUsers    Permission = "users:delete"
+	ManageRoles    Permission = "roles:manage"
+	ManageAPIKeys  Permission = "keys:manage"
+	ReadAudit      Permission = "audit:read"
+
+	ManageDepartments Permission = "departments:manage"
+)
+
+// Roles that can be granted to users.
+const (
+	Viewer = "viewer"
+	Editor = "editor"
+	Admin  = "admin"
+)
+
+// rolePermissions is the permission matrix. Each role has the permissions of
+// the roles before it.
+var rolePermissions = map[string][]Permission{
+	Viewer: {ReadUsers},
+	Editor: {ReadUsers, CreateUsers, UpdateUsers},
+	Admin:  {ReadUsers, CreateUsers, UpdateUsers, TerminateUsers, DeleteUsers, ManageRoles, ManageAPIKeys, ReadAudit, ManageDepartments},
+}
+
+// PermissionsOf returns the permissions of role, sorted, or nil for a role
+// unknown to the matrix.
+func PermissionsOf(role string) []Permission {
+	permissions := append([]Permission(nil), rolePermissions[role]...)
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i] < permissions[j] })
+	return permissions
+}
+
+// PermissionError is the message of the 403 returned when the caller lacks
+// Permission.
+type PermissionError struct {
+	Permission Permission
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("missing permission %s", e.Permission)
+}
+
+// RolesFunc returns the grants of the subject of a token. A subject that is
+// not known has none.
+type RolesFunc func(ctx context.Context, subject string) ([]models.Grant, error)
+
+// Authorize looks up the grants of the authenticated subject so that Require,
+// Can and Scope can check its permissions. It must run after Middleware.
+// Requests authenticated with an API key hold its scopes instead.
+func Authorize(roles RolesFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if _, ok := APIKeyScopes(c); ok {
+				return next(c)
+			}
+			granted, err := roles(c.Request().Context(), Subject(c))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+			c.Set(grantsKey, granted)
+			return next(c)
+		}
+	}
+}
+
+// Require rejects requests whose caller lacks permission over every user
+// with a 403 that names it. Grants confined to a department do not count;
+// routes that can be confined to departments check Scope instead.
+func Require(permission Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !Can(c, permission) {
+				return Forbidden(permission)
+			}
+			return next(c)
+		}
+	}
+}
+
+// Forbidden returns the 403 reported when the caller lacks permission.
+func Forbidden(permission Permission) *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusForbidden, &PermissionError{Permission: permission})
+}
+
+// Can reports whether one of the caller's grants gives permission over every
+// user. Without Authorize the caller has no permissions.
+func Can(c echo.Context, permission Permission) bool {
+	_, global := Scope(c, permission)
+	return global
+}
+
+// Scope returns where the caller holds permission: over every user when
+// global is set, otherwise only over the users of departments, which is
+// empty if the caller lacks the permission altogether. The scopes of an API
+// key are held over every user.
+func Scope(c echo.Context, permission Permission) (departments []string, global bool) {
+	if scopes, ok := APIKeyScopes(c); ok {
+		return nil, slices.Contains(scopes, permission)
+	}
+	for _, grant := range Grants(c) {
+		if !grants(grant.Role, permission) {
+			continue
+		}
+		if grant.Department == "" {
+			return nil, true
+		}
+		departments = append(departments, grant.Department)
+	}
+	sort.Strings(departments)
+	return slices.Compact(departments), false
+}
+
+func grants(role string, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Grants returns the grants of the caller found by Authorize.
+func Grants(c echo.Context) []models.Grant {
+	granted, _ := c.Get(grantsKey).([]models.Grant)
+	return granted
+}
@@ -0,0 +1,95 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sedmo/integra-coding-assessment/go-backend/auth"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+var _ = Describe("Authorize", func() {
+	run := func(roles auth.RolesFunc, permission auth.Permission) error {
+		e := echo.New()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/users", nil), httptest.NewRecorder())
+		handler := auth.Authorize(roles)(auth.Require(permission)(func(c echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		}))
+		return handler(c)
+	}
+	granted := func(roles ...string) auth.RolesFunc {
+		var grants []models.Grant
+		for _, role := range roles {
+			grants = append(grants, models.Grant{Role: role})
+		}
+		return func(context.Context, string) ([]models.Grant, error) { return grants, nil }
+	}
+
+	It("should apply the permission matrix", func() {
+		Expect(run(granted(auth.Viewer), auth.ReadUsers)).To(Succeed())
+		Expect(run(granted(auth.Editor), auth.UpdateUsers)).To(Succeed())
+		Expect(run(granted(auth.Admin), auth.ManageRoles)).To(Succeed())
+		Expect(run(granted("owner", auth.Editor), auth.CreateUsers)).To(Succeed())
+	})
+
+	It("should name the missing permission", func() {
+		err := run(granted(auth.Editor), auth.DeleteUsers)
+		var httpErr *echo.HTTPError
+		Expect(errors.As(err, &httpErr)).To(BeTrue())
+		Expect(httpErr.Code).To(Equal(http.StatusForbidden))
+		Expect(httpErr.Message).To(Equal(&auth.PermissionError{Permission: auth.DeleteUsers}))
+
+		Expect(run(granted(), auth.ReadUsers)).To(HaveOccurred())
+	})
+
+	It("should not count department grants as global ones", func() {
+		err := run(func(context.Context, string) ([]models.Grant, error) {
+			return []models.Grant{{Role: auth.Admin, Department: "Sales"}}, nil
+		}, auth.ReadUsers)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should scope permissions to the departments they are granted in", func() {
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/users", nil), httptest.NewRecorder())
+		Expect(auth.Authorize(func(context.Context, string) ([]models.Grant, error) {
+			return []models.Grant{
+				{Role: auth.Viewer},
+				{Role: auth.Admin, Department: "Sales"},
+				{Role: auth.Editor, Department: "HR"},
+				{Role: auth.Viewer, Department: "Sales"},
+			}, nil
+		})(func(echo.Context) error { return nil })(c)).To(Succeed())
+
+		departments, global := auth.Scope(c, auth.ReadUsers)
+		Expect(global).To(BeTrue())
+		Expect(departments).To(BeEmpty())
+
+		departments, global = auth.Scope(c, auth.UpdateUsers)
+		Expect(global).To(BeFalse())
+		Expect(departments).To(Equal([]string{"HR", "Sales"}))
+
+		departments, _ = auth.Scope(c, auth.DeleteUsers)
+		Expect(departments).To(Equal([]string{"Sales"}))
+
+		departments, _ = auth.Scope(c, auth.ManageRoles)
+		Expect(departments).To(Equal([]string{"Sales"}))
+		Expect(auth.Can(c, auth.ManageRoles)).To(BeFalse())
+	})
+
+	It("should fail when the roles cannot be looked up", func() {
+		err := run(func(context.Context, string) ([]models.Grant, error) { return nil, errors.New("down") }, auth.ReadUsers)
+		var httpErr *echo.HTTPError
+		Expect(errors.As(err, &httpErr)).To(BeTrue())
+		Expect(httpErr.Code).To(Equal(http.StatusInternalServerError))
+	})
+
+	It("should list the permissions of a role", func() {
+		Expect(auth.PermissionsOf(auth.Editor)).To(Equal([]auth.Permission{auth.CreateUsers, auth.ReadUsers, auth.UpdateUsers}))
+		Expect(auth.PermissionsOf("owner")).To(BeEmpty())
+	})
+})
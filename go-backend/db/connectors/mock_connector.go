@@ -9,6 +9,16 @@ import (
 type MockConnector struct {
 	DB      *sql.DB
 	Sqlmock sqlmock.Sqlmock
+
+	// Version and Dirty are returned by MigrationVersion, Pending by
+	// MigrationsPending.
+	Version uint
+	Dirty   bool
+	Pending bool
+	// UpCalls and DownCalls count the migration runs so tests can assert
+	// which path InitDB took.
+	UpCalls   int
+	DownCalls int
 }
 
 func (m *MockConnector) Open(connectionString string) (*sql.DB, error) {
@@ -22,9 +32,30 @@ func (m *MockConnector) Ping(db *sql.DB) error {
 }
 
 func (m *MockConnector) RunMigrationsUp(db *sql.DB) error {
+	m.UpCalls++
 	return nil
 }
 
 func (m *MockConnector) RunMigrationsDown(db *sql.DB) error {
+	m.DownCalls++
+	return nil
+}
+
+func (m *MockConnector) MigrateTo(db *sql.DB, version uint) error {
+	m.Version = version
 	return nil
 }
+
+func (m *MockConnector) ForceMigrationVersion(db *sql.DB, version int) error {
+	m.Version = uint(version)
+	m.Dirty = false
+	return nil
+}
+
+func (m *MockConnector) MigrationVersion(db *sql.DB) (uint, bool, error) {
+	return m.Version, m.Dirty, nil
+}
+
+func (m *MockConnector) MigrationsPending(db *sql.DB) (bool, error) {
+	return m.Pending, nil
+}
@@ -1,16 +1,29 @@
 package connectors
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
+	"os"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file" // Import for file source
-	_ "github.com/lib/pq"                                // Import for Postgres driver
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/lib/pq" // Import for Postgres driver
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/db/migrations"
 )
 
-type PostgresConnector struct{}
+type PostgresConnector struct {
+	// MigrationsDir overrides the migrations embedded in the binary with the
+	// .sql files of a directory on disk, which is handy while writing new
+	// migrations.
+	MigrationsDir string
+}
 
 func (p *PostgresConnector) Open(connectionString string) (*sql.DB, error) {
 	return sql.Open("postgres", connectionString)
@@ -21,15 +34,11 @@ func (p *PostgresConnector) Ping(db *sql.DB) error {
 }
 
 func (p *PostgresConnector) RunMigrationsUp(db *sql.DB) error {
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
-	if err != nil {
-		return err
-	}
-
-	m, err := migrate.NewWithDatabaseInstance("file:///app/db/migrations", "postgres", driver)
+	m, err := p.newMigrate(db)
 	if err != nil {
 		return err
 	}
+	defer m.Close()
 
 	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
 		return err
@@ -39,19 +48,136 @@ func (p *PostgresConnector) RunMigrationsUp(db *sql.DB) error {
 }
 
 func (p *PostgresConnector) RunMigrationsDown(db *sql.DB) error {
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	m, err := p.newMigrate(db)
 	if err != nil {
 		return err
 	}
+	defer m.Close()
 
-	m, err := migrate.NewWithDatabaseInstance("file:///app/db/migrations", "postgres", driver)
+	if err := m.Down(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+	log.Println("Database rolled back successfully")
+	return nil
+}
+
+func (p *PostgresConnector) MigrateTo(db *sql.DB, version uint) error {
+	m, err := p.newMigrate(db)
 	if err != nil {
 		return err
 	}
+	defer m.Close()
 
-	if err := m.Down(); err != nil && err != migrate.ErrNoChange {
+	if err := m.Migrate(version); err != nil && err != migrate.ErrNoChange {
 		return err
 	}
-	log.Println("Database rolled back successfully")
+	log.Printf("Database migrated to version %d", version)
 	return nil
 }
+
+func (p *PostgresConnector) ForceMigrationVersion(db *sql.DB, version int) error {
+	m, err := p.newMigrate(db)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return m.Force(version)
+}
+
+// MigrationVersion returns the version the schema is at and whether the last
+// migration failed half way. A database that was never migrated is at 0.
+func (p *PostgresConnector) MigrationVersion(db *sql.DB) (uint, bool, error) {
+	m, err := p.newMigrate(db)
+	if err != nil {
+		return 0, false, err
+	}
+	defer m.Close()
+
+	version, dirty, err := m.Version()
+	if err == migrate.ErrNilVersion {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+func (p *PostgresConnector) MigrationsPending(db *sql.DB) (bool, error) {
+	current, dirty, err := p.MigrationVersion(db)
+	if err != nil {
+		return false, err
+	}
+	if dirty {
+		return false, fmt.Errorf("database is dirty at migration %d", current)
+	}
+
+	src, err := p.source()
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+
+	latest, err := latestMigrationVersion(src)
+	if err != nil {
+		return false, err
+	}
+	return current < latest, nil
+}
+
+// newMigrate returns a migrate instance for db, which the caller must close.
+// It runs on a connection of its own that closing it returns to the pool;
+// unlike postgres.WithInstance, closing it leaves db open.
+func (p *PostgresConnector) newMigrate(db *sql.DB) (*migrate.Migrate, error) {
+	src, err := p.source()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		src.Close()
+		return nil, err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
+	if err != nil {
+		driver.Close()
+		src.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// source returns the embedded migrations, or those in MigrationsDir if set.
+func (p *PostgresConnector) source() (source.Driver, error) {
+	var fsys fs.FS = migrations.FS
+	if p.MigrationsDir != "" {
+		fsys = os.DirFS(p.MigrationsDir)
+	}
+	return iofs.New(fsys, ".")
+}
+
+// latestMigrationVersion returns the highest version found in src.
+func latestMigrationVersion(src source.Driver) (uint, error) {
+	version, err := src.First()
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	for err == nil {
+		var next uint
+		next, err = src.Next(version)
+		if err == nil {
+			version = next
+		}
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	return version, nil
+}
@@ -0,0 +1,190 @@
+package connectors
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "modernc.org/sqlite" // Import for the pure Go SQLite driver
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/db/migrations"
+)
+
+// SQLiteScheme prefixes a DATABASE_URL that names a SQLite database file,
+// e.g. sqlite://users.db or sqlite://:memory:.
+const SQLiteScheme = "sqlite://"
+
+// SQLiteConnector opens SQLite database files and migrates them with the
+// SQLite flavour of the migrations.
+type SQLiteConnector struct {
+	// MigrationsDir overrides the embedded SQLite migrations with the .sql
+	// files of a directory on disk.
+	MigrationsDir string
+}
+
+// Open opens the database file named by connectionString, with or without
+// the sqlite:// prefix. Foreign keys are enforced and a single connection is
+// used, so writers queue up instead of failing with SQLITE_BUSY and an
+// in-memory database is shared by every query. Times are written in the
+// format of SQLite's own date functions, so they sort and compare as text.
+func (s *SQLiteConnector) Open(connectionString string) (*sql.DB, error) {
+	path := strings.TrimPrefix(connectionString, SQLiteScheme)
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+sep+"_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+func (s *SQLiteConnector) Ping(db *sql.DB) error {
+	return db.Ping()
+}
+
+func (s *SQLiteConnector) RunMigrationsUp(db *sql.DB) error {
+	m, err := s.newMigrate(db)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+	log.Println("Database migrated successfully")
+	return nil
+}
+
+func (s *SQLiteConnector) RunMigrationsDown(db *sql.DB) error {
+	m, err := s.newMigrate(db)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Down(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+	log.Println("Database rolled back successfully")
+	return nil
+}
+
+func (s *SQLiteConnector) MigrateTo(db *sql.DB, version uint) error {
+	m, err := s.newMigrate(db)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Migrate(version); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+	log.Printf("Database migrated to version %d", version)
+	return nil
+}
+
+func (s *SQLiteConnector) ForceMigrationVersion(db *sql.DB, version int) error {
+	m, err := s.newMigrate(db)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return m.Force(version)
+}
+
+// MigrationVersion returns the version the schema is at and whether the last
+// migration failed half way. A database that was never migrated is at 0.
+func (s *SQLiteConnector) MigrationVersion(db *sql.DB) (uint, bool, error) {
+	m, err := s.newMigrate(db)
+	if err != nil {
+		return 0, false, err
+	}
+	defer m.Close()
+
+	version, dirty, err := m.Version()
+	if err == migrate.ErrNilVersion {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+func (s *SQLiteConnector) MigrationsPending(db *sql.DB) (bool, error) {
+	current, dirty, err := s.MigrationVersion(db)
+	if err != nil {
+		return false, err
+	}
+	if dirty {
+		return false, fmt.Errorf("database is dirty at migration %d", current)
+	}
+
+	src, err := s.source()
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+
+	latest, err := latestMigrationVersion(src)
+	if err != nil {
+		return false, err
+	}
+	return current < latest, nil
+}
+
+// newMigrate returns a migrate instance for db, which the caller must close.
+func (s *SQLiteConnector) newMigrate(db *sql.DB) (*migrate.Migrate, error) {
+	src, err := s.source()
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "sqlite", sharedDatabase{driver})
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// sharedDatabase keeps closing a migrate instance from closing the database
+// it migrates, which the SQLite driver would otherwise do although the
+// connector's caller goes on using it.
+type sharedDatabase struct {
+	database.Driver
+}
+
+func (sharedDatabase) Close() error {
+	return nil
+}
+
+// source returns the embedded SQLite migrations, or those in MigrationsDir
+// if set.
+func (s *SQLiteConnector) source() (source.Driver, error) {
+	if s.MigrationsDir != "" {
+		return iofs.New(os.DirFS(s.MigrationsDir), ".")
+	}
+	sub, err := fs.Sub(migrations.SQLiteFS, "sqlite")
+	if err != nil {
+		return nil, err
+	}
+	return iofs.New(sub, ".")
+}
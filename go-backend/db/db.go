@@ -3,46 +3,151 @@ package db
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"log"
 	"os"
-
-	"github.com/Masterminds/squirrel"
+	"strconv"
 )
 
-var DB *sql.DB
-var Psql = squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
-
-var connectionString string
-
 type DBConnector interface {
 	Open(connectionString string) (*sql.DB, error)
 	Ping(db *sql.DB) error
 	RunMigrationsUp(db *sql.DB) error
 	RunMigrationsDown(db *sql.DB) error
+	// MigrateTo migrates up or down until the schema is at version.
+	MigrateTo(db *sql.DB, version uint) error
+	// ForceMigrationVersion records version as applied and clears the
+	// dirty flag without running any migration.
+	ForceMigrationVersion(db *sql.DB, version int) error
+	// MigrationVersion returns the current schema version and whether the
+	// last migration was left dirty by a failure.
+	MigrationVersion(db *sql.DB) (version uint, dirty bool, err error)
+	// MigrationsPending reports whether the schema is behind the newest
+	// migration known to the connector.
+	MigrationsPending(db *sql.DB) (bool, error)
 }
 
-func InitDB(connector DBConnector) error {
-	connectionString = os.Getenv("DATABASE_URL")
-	if connectionString == "" {
-		return errors.New("DATABASE_URL environment variable is not set")
+// MigrationPolicy decides what InitDB does with the schema on startup.
+type MigrationPolicy string
+
+const (
+	// MigrateUp applies any pending migrations and never rolls back.
+	MigrateUp MigrationPolicy = "up"
+	// MigrateVerify applies nothing and fails if migrations are pending.
+	MigrateVerify MigrationPolicy = "verify"
+	// MigrateReset rolls back every migration and applies them again,
+	// destroying all data. It is refused unless AllowReset is set.
+	MigrateReset MigrationPolicy = "reset"
+)
+
+// MigrationOptions configures how InitDB treats the schema.
+type MigrationOptions struct {
+	Policy MigrationPolicy
+	// AllowReset must be set explicitly, for development databases only,
+	// before MigrateReset is honoured.
+	AllowReset bool
+}
+
+var (
+	ErrMigrationsPending = errors.New("database has pending migrations")
+	ErrResetNotAllowed   = errors.New("migration policy reset requires DB_ALLOW_RESET=true")
+)
+
+// MigrationOptionsFromEnv reads the migration policy from DB_MIGRATION_POLICY
+// (default "up") and the development reset flag from DB_ALLOW_RESET.
+func MigrationOptionsFromEnv() (MigrationOptions, error) {
+	opts := MigrationOptions{Policy: MigrateUp}
+
+	if v := os.Getenv("DB_MIGRATION_POLICY"); v != "" {
+		opts.Policy = MigrationPolicy(v)
+	}
+	switch opts.Policy {
+	case MigrateUp, MigrateVerify, MigrateReset:
+	default:
+		return opts, fmt.Errorf("unknown DB_MIGRATION_POLICY %q", opts.Policy)
+	}
+
+	if v := os.Getenv("DB_ALLOW_RESET"); v != "" {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid DB_ALLOW_RESET %q", v)
+		}
+		opts.AllowReset = allow
 	}
 
-	var err error
-	DB, err = connector.Open(connectionString)
+	return opts, nil
+}
+
+// InitDB connects to DATABASE_URL and migrates the schema according to the
+// policy configured in the environment.
+func InitDB(connector DBConnector) (*sql.DB, error) {
+	opts, err := MigrationOptionsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return InitDBWithOptions(connector, opts)
+}
+
+// InitDBWithOptions connects to DATABASE_URL and migrates the schema
+// according to opts.
+func InitDBWithOptions(connector DBConnector, opts MigrationOptions) (*sql.DB, error) {
+	db, err := Connect(connector)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := connector.Ping(DB); err != nil {
-		return err
+	if err := Migrate(connector, db, opts); err != nil {
+		db.Close()
+		return nil, err
 	}
+	return db, nil
+}
 
-	if err := connector.RunMigrationsDown(DB); err != nil {
-		return err
+// Connect opens and pings DATABASE_URL without touching the schema.
+func Connect(connector DBConnector) (*sql.DB, error) {
+	connectionString := os.Getenv("DATABASE_URL")
+	if connectionString == "" {
+		return nil, errors.New("DATABASE_URL environment variable is not set")
 	}
 
-	if err := connector.RunMigrationsUp(DB); err != nil {
-		return err
+	db, err := connector.Open(connectionString)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := connector.Ping(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Migrate brings the schema of db in line with opts.
+func Migrate(connector DBConnector, db *sql.DB, opts MigrationOptions) error {
+	switch opts.Policy {
+	case MigrateUp, "":
+		return connector.RunMigrationsUp(db)
+
+	case MigrateVerify:
+		pending, err := connector.MigrationsPending(db)
+		if err != nil {
+			return err
+		}
+		if pending {
+			return ErrMigrationsPending
+		}
+		return nil
+
+	case MigrateReset:
+		if !opts.AllowReset {
+			return ErrResetNotAllowed
+		}
+		log.Println("Resetting database: rolling back all migrations")
+		if err := connector.RunMigrationsDown(db); err != nil {
+			return err
+		}
+		return connector.RunMigrationsUp(db)
 	}
 
-	return nil
+	return fmt.Errorf("unknown migration policy %q", opts.Policy)
 }
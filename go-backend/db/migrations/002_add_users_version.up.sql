@@ -0,0 +1,2 @@
+-- Add a row version used for optimistic concurrency control
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
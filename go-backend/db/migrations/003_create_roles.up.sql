@@ -0,0 +1,18 @@
+-- Roles that can be granted to users. The permissions of each role are
+-- defined by the API.
+CREATE TABLE IF NOT EXISTS roles (
+    role_id SERIAL PRIMARY KEY,
+    name VARCHAR(50) UNIQUE NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('viewer', 'Read users'),
+    ('editor', 'Read, create and update users'),
+    ('admin', 'Manage users and grant roles');
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INTEGER NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles (role_id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_id)
+);
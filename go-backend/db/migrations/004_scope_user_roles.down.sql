@@ -0,0 +1,5 @@
+-- Department grants cannot be expressed without the column; drop them.
+DELETE FROM user_roles WHERE department <> '';
+ALTER TABLE user_roles DROP CONSTRAINT IF EXISTS user_roles_pkey;
+ALTER TABLE user_roles ADD PRIMARY KEY (user_id, role_id);
+ALTER TABLE user_roles DROP COLUMN department;
@@ -0,0 +1,5 @@
+-- Let a role be granted over a single department. An empty department
+-- grants the role over every user.
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS department VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE user_roles DROP CONSTRAINT IF EXISTS user_roles_pkey;
+ALTER TABLE user_roles ADD PRIMARY KEY (user_id, role_id, department);
@@ -0,0 +1,15 @@
+-- Append-only log of changes to users. It has no foreign key so that the
+-- history of a deleted user is kept. Changes holds a JSON object of
+-- {"field": {"old": ..., "new": ...}}.
+CREATE TABLE IF NOT EXISTS user_audit (
+    audit_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    request_id VARCHAR(255) NOT NULL DEFAULT '',
+    operation VARCHAR(10) NOT NULL CHECK (operation IN ('create', 'update', 'delete')),
+    changes TEXT NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS user_audit_user_id ON user_audit (user_id);
+CREATE INDEX IF NOT EXISTS user_audit_occurred_at ON user_audit (occurred_at);
@@ -0,0 +1,17 @@
+-- Chain the audit log: hash covers the contents of an entry and prev_hash,
+-- the hash of the entry before it. Entries recorded before this migration
+-- keep empty hashes and precede the chain.
+ALTER TABLE user_audit ADD COLUMN prev_hash VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE user_audit ADD COLUMN hash VARCHAR(64) NOT NULL DEFAULT '';
+
+-- The single row of user_audit_chain bounds the chain: unsealed_until is the
+-- id of the last entry recorded before it, after which every entry must
+-- carry a hash, and head and entries are the hash of the last chained entry
+-- and the number of chained entries, which deleting entries from the end of
+-- the log does not change.
+CREATE TABLE IF NOT EXISTS user_audit_chain (
+    unsealed_until BIGINT NOT NULL,
+    head VARCHAR(64) NOT NULL DEFAULT '',
+    entries BIGINT NOT NULL DEFAULT 0
+);
+INSERT INTO user_audit_chain (unsealed_until) SELECT COALESCE(MAX(audit_id), 0) FROM user_audit;
@@ -0,0 +1,7 @@
+-- Deleted users cannot be told apart without the column; purge them. Audit
+-- entries of restores are kept, so the operation check is left as is.
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+DROP INDEX users_deleted_at;
+DROP INDEX users_user_name_key;
+ALTER TABLE users ADD CONSTRAINT users_user_name_key UNIQUE (user_name);
+ALTER TABLE users DROP COLUMN deleted_at;
@@ -0,0 +1,12 @@
+-- Soft delete users: a deleted user keeps its row with deleted_at set until
+-- it is purged. User names only have to be unique among the users that are
+-- not deleted, so a name can be reused while its former owner can still be
+-- restored.
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE users DROP CONSTRAINT users_user_name_key;
+CREATE UNIQUE INDEX users_user_name_key ON users (user_name) WHERE deleted_at IS NULL;
+CREATE INDEX users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE user_audit DROP CONSTRAINT user_audit_operation_check;
+ALTER TABLE user_audit ADD CONSTRAINT user_audit_operation_check
+    CHECK (operation IN ('create', 'update', 'delete', 'restore'));
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN status_effective_date;
+ALTER TABLE users DROP COLUMN status_reason;
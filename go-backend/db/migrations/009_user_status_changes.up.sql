@@ -0,0 +1,3 @@
+-- Record why and from which day a user has its status.
+ALTER TABLE users ADD COLUMN status_reason VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN status_effective_date DATE;
@@ -0,0 +1,21 @@
+-- Changes of a user's status or department scheduled to take effect at
+-- effective_at. Pending changes are applied once they are due; resolved ones
+-- are kept with who resolved them, when, and why a failed one failed.
+CREATE TABLE IF NOT EXISTS scheduled_changes (
+    change_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    user_status VARCHAR(1) NOT NULL DEFAULT '' CHECK (user_status IN ('', 'A', 'I', 'T')),
+    department VARCHAR(255) NOT NULL DEFAULT '',
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    effective_at TIMESTAMPTZ NOT NULL,
+    state VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (state IN ('pending', 'applied', 'cancelled', 'failed')),
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    resolved_by VARCHAR(255) NOT NULL DEFAULT '',
+    resolved_at TIMESTAMPTZ,
+    error TEXT NOT NULL DEFAULT '',
+    CHECK (user_status <> '' OR department <> '')
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_changes_user_id ON scheduled_changes (user_id);
+CREATE INDEX IF NOT EXISTS scheduled_changes_due ON scheduled_changes (effective_at) WHERE state = 'pending';
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS users_updated_at;
+DROP INDEX IF EXISTS users_created_at;
+ALTER TABLE users DROP COLUMN updated_by;
+ALTER TABLE users DROP COLUMN updated_at;
+ALTER TABLE users DROP COLUMN created_by;
+ALTER TABLE users DROP COLUMN created_at;
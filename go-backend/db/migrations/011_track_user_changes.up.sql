@@ -0,0 +1,23 @@
+-- Record when and by whom each user was created and last written. Existing
+-- users take both from the audit log where it has them.
+ALTER TABLE users ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE users ADD COLUMN created_by VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE users ADD COLUMN updated_by VARCHAR(255) NOT NULL DEFAULT '';
+
+UPDATE users SET created_at = first.occurred_at, created_by = first.actor
+FROM (
+    SELECT DISTINCT ON (user_id) user_id, occurred_at, actor
+    FROM user_audit WHERE operation = 'create' ORDER BY user_id, audit_id
+) first
+WHERE users.user_id = first.user_id;
+
+UPDATE users SET updated_at = last.occurred_at, updated_by = last.actor
+FROM (
+    SELECT DISTINCT ON (user_id) user_id, occurred_at, actor
+    FROM user_audit ORDER BY user_id, audit_id DESC
+) last
+WHERE users.user_id = last.user_id;
+
+CREATE INDEX IF NOT EXISTS users_created_at ON users (created_at);
+CREATE INDEX IF NOT EXISTS users_updated_at ON users (updated_at);
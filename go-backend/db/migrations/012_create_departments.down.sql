@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS users_department;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_department_fkey;
+DROP TABLE IF EXISTS departments;
@@ -0,0 +1,35 @@
+-- Departments, which users now have to belong to. Every department named by
+-- a user, a role grant or a scheduled change is created, and users.department
+-- becomes a foreign key to the department's name, which follows renames. A
+-- department's code is its name, unless the name is longer than the 50
+-- characters a code holds or contains a ~. Such names are cut to fit and
+-- numbered (~1, ~2, ...) instead: numbered codes all contain a ~ and end in
+-- different numbers, so they cannot equal each other or any other code.
+CREATE TABLE IF NOT EXISTS departments (
+    department_id SERIAL PRIMARY KEY,
+    code VARCHAR(50) NOT NULL UNIQUE CHECK (code <> ''),
+    name VARCHAR(255) NOT NULL UNIQUE CHECK (name <> ''),
+    parent_id INTEGER REFERENCES departments (department_id),
+    cost_center VARCHAR(50) NOT NULL DEFAULT '',
+    CHECK (parent_id <> department_id)
+);
+
+CREATE INDEX IF NOT EXISTS departments_parent_id ON departments (parent_id);
+
+INSERT INTO departments (code, name)
+SELECT CASE WHEN numbered THEN left(name, 50 - length('~' || n)) || '~' || n ELSE name END, name
+FROM (
+    SELECT name, numbered, row_number() OVER (PARTITION BY numbered ORDER BY name) AS n FROM (
+        SELECT name, length(name) > 50 OR name LIKE '%~%' AS numbered FROM (
+            SELECT department AS name FROM users
+            UNION SELECT department FROM user_roles
+            UNION SELECT department FROM scheduled_changes
+        ) named
+        WHERE name <> ''
+    ) flagged
+) numbered_names
+ORDER BY name;
+
+ALTER TABLE users ADD CONSTRAINT users_department_fkey
+    FOREIGN KEY (department) REFERENCES departments (name) ON UPDATE CASCADE;
+CREATE INDEX IF NOT EXISTS users_department ON users (department);
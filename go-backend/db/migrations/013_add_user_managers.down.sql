@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS users_manager_id;
+ALTER TABLE users DROP COLUMN manager_id;
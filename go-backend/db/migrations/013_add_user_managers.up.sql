@@ -0,0 +1,6 @@
+-- Reporting lines: each user may report to a manager, who is another user.
+-- Purging a manager leaves their reports without one.
+ALTER TABLE users ADD COLUMN manager_id INTEGER REFERENCES users (user_id) ON DELETE SET NULL;
+ALTER TABLE users ADD CONSTRAINT users_manager_id_check CHECK (manager_id <> user_id);
+
+CREATE INDEX IF NOT EXISTS users_manager_id ON users (manager_id);
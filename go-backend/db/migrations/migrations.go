@@ -0,0 +1,16 @@
+// Package migrations holds the SQL migrations of the users schema. They are
+// embedded so that the binary can migrate a database from any directory.
+package migrations
+
+import "embed"
+
+// FS holds the Postgres migrations.
+//
+//go:embed *.sql
+var FS embed.FS
+
+// SQLiteFS holds the SQLite migrations under the sqlite directory. They must
+// keep the same version numbers as the Postgres ones.
+//
+//go:embed sqlite/*.sql
+var SQLiteFS embed.FS
@@ -0,0 +1,12 @@
+-- Create users table. SQLite does not enforce VARCHAR lengths, so they are
+-- checked explicitly, and AUTOINCREMENT keeps ids from being reused like a
+-- Postgres SERIAL.
+CREATE TABLE IF NOT EXISTS users (
+    user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_name TEXT UNIQUE NOT NULL CHECK (length(user_name) <= 50),
+    first_name TEXT NOT NULL CHECK (length(first_name) <= 255),
+    last_name TEXT NOT NULL CHECK (length(last_name) <= 255),
+    email TEXT NOT NULL CHECK (length(email) <= 255),
+    user_status TEXT NOT NULL CHECK (user_status IN ('I', 'A', 'T')),
+    department TEXT NOT NULL CHECK (length(department) <= 255)
+);
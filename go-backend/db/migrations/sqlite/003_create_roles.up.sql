@@ -0,0 +1,18 @@
+-- Roles that can be granted to users. The permissions of each role are
+-- defined by the API.
+CREATE TABLE IF NOT EXISTS roles (
+    role_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT UNIQUE NOT NULL CHECK (length(name) <= 50),
+    description TEXT NOT NULL DEFAULT '' CHECK (length(description) <= 255)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('viewer', 'Read users'),
+    ('editor', 'Read, create and update users'),
+    ('admin', 'Manage users and grant roles');
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INTEGER NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles (role_id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_id)
+);
@@ -0,0 +1,10 @@
+-- Department grants cannot be expressed without the column; drop them.
+CREATE TABLE user_roles_global (
+    user_id INTEGER NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles (role_id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_id)
+);
+
+INSERT INTO user_roles_global (user_id, role_id) SELECT user_id, role_id FROM user_roles WHERE department = '';
+DROP TABLE user_roles;
+ALTER TABLE user_roles_global RENAME TO user_roles;
@@ -0,0 +1,13 @@
+-- Let a role be granted over a single department. An empty department
+-- grants the role over every user. SQLite cannot change a primary key, so
+-- the table is rebuilt.
+CREATE TABLE user_roles_scoped (
+    user_id INTEGER NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles (role_id) ON DELETE CASCADE,
+    department TEXT NOT NULL DEFAULT '' CHECK (length(department) <= 255),
+    PRIMARY KEY (user_id, role_id, department)
+);
+
+INSERT INTO user_roles_scoped (user_id, role_id) SELECT user_id, role_id FROM user_roles;
+DROP TABLE user_roles;
+ALTER TABLE user_roles_scoped RENAME TO user_roles;
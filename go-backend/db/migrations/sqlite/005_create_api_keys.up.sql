@@ -0,0 +1,15 @@
+-- API keys let services call the API without a user token. Only a hash of
+-- the secret is stored; the prefix identifies the key. Scopes is a
+-- space-separated list of permissions.
+CREATE TABLE IF NOT EXISTS api_keys (
+    key_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL CHECK (length(name) <= 100),
+    prefix TEXT UNIQUE NOT NULL CHECK (length(prefix) <= 16),
+    secret_hash TEXT NOT NULL CHECK (length(secret_hash) = 64),
+    scopes TEXT NOT NULL CHECK (length(scopes) <= 255),
+    created_by TEXT NOT NULL DEFAULT '' CHECK (length(created_by) <= 255),
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME,
+    last_used_at DATETIME,
+    revoked_at DATETIME
+);
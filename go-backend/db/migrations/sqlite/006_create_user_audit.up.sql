@@ -0,0 +1,15 @@
+-- Append-only log of changes to users. It has no foreign key so that the
+-- history of a deleted user is kept. Changes holds a JSON object of
+-- {"field": {"old": ..., "new": ...}}.
+CREATE TABLE IF NOT EXISTS user_audit (
+    audit_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    actor TEXT NOT NULL CHECK (length(actor) <= 255),
+    request_id TEXT NOT NULL DEFAULT '' CHECK (length(request_id) <= 255),
+    operation TEXT NOT NULL CHECK (operation IN ('create', 'update', 'delete')),
+    changes TEXT NOT NULL,
+    occurred_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS user_audit_user_id ON user_audit (user_id);
+CREATE INDEX IF NOT EXISTS user_audit_occurred_at ON user_audit (occurred_at);
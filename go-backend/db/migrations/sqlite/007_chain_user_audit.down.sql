@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_audit_chain;
+ALTER TABLE user_audit DROP COLUMN hash;
+ALTER TABLE user_audit DROP COLUMN prev_hash;
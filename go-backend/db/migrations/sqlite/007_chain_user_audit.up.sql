@@ -0,0 +1,17 @@
+-- Chain the audit log: hash covers the contents of an entry and prev_hash,
+-- the hash of the entry before it. Entries recorded before this migration
+-- keep empty hashes and precede the chain.
+ALTER TABLE user_audit ADD COLUMN prev_hash TEXT NOT NULL DEFAULT '' CHECK (length(prev_hash) <= 64);
+ALTER TABLE user_audit ADD COLUMN hash TEXT NOT NULL DEFAULT '' CHECK (length(hash) <= 64);
+
+-- The single row of user_audit_chain bounds the chain: unsealed_until is the
+-- id of the last entry recorded before it, after which every entry must
+-- carry a hash, and head and entries are the hash of the last chained entry
+-- and the number of chained entries, which deleting entries from the end of
+-- the log does not change.
+CREATE TABLE IF NOT EXISTS user_audit_chain (
+    unsealed_until INTEGER NOT NULL,
+    head TEXT NOT NULL DEFAULT '' CHECK (length(head) <= 64),
+    entries INTEGER NOT NULL DEFAULT 0
+);
+INSERT INTO user_audit_chain (unsealed_until) SELECT COALESCE(MAX(audit_id), 0) FROM user_audit;
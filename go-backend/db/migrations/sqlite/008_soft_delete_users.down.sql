@@ -0,0 +1,23 @@
+-- Deleted users cannot be told apart without the column; purge them. Audit
+-- entries of restores are kept, so the operation check is left as is.
+CREATE TABLE users_hard_delete (
+    user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_name TEXT UNIQUE NOT NULL CHECK (length(user_name) <= 50),
+    first_name TEXT NOT NULL CHECK (length(first_name) <= 255),
+    last_name TEXT NOT NULL CHECK (length(last_name) <= 255),
+    email TEXT NOT NULL CHECK (length(email) <= 255),
+    user_status TEXT NOT NULL CHECK (user_status IN ('I', 'A', 'T')),
+    department TEXT NOT NULL CHECK (length(department) <= 255),
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+INSERT INTO users_hard_delete (user_id, user_name, first_name, last_name, email, user_status, department, version)
+    SELECT user_id, user_name, first_name, last_name, email, user_status, department, version FROM users WHERE deleted_at IS NULL;
+UPDATE sqlite_sequence SET seq = (SELECT seq FROM sqlite_sequence WHERE name = 'users') WHERE name = 'users_hard_delete';
+
+CREATE TEMP TABLE user_roles_saved AS
+    SELECT user_id, role_id, department FROM user_roles WHERE user_id IN (SELECT user_id FROM users_hard_delete);
+DROP TABLE users;
+ALTER TABLE users_hard_delete RENAME TO users;
+INSERT INTO user_roles (user_id, role_id, department) SELECT user_id, role_id, department FROM user_roles_saved;
+DROP TABLE user_roles_saved;
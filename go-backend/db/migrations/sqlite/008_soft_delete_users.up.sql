@@ -0,0 +1,55 @@
+-- Soft delete users: a deleted user keeps its row with deleted_at set until
+-- it is purged. User names only have to be unique among the users that are
+-- not deleted, so a name can be reused while its former owner can still be
+-- restored.
+--
+-- SQLite cannot drop the UNIQUE constraint of a column, so the table is
+-- rebuilt. Dropping the old table cascades to the role grants, which are
+-- copied aside and put back, and the id sequence is carried over so that
+-- ids are still never reused.
+CREATE TABLE users_soft_delete (
+    user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_name TEXT NOT NULL CHECK (length(user_name) <= 50),
+    first_name TEXT NOT NULL CHECK (length(first_name) <= 255),
+    last_name TEXT NOT NULL CHECK (length(last_name) <= 255),
+    email TEXT NOT NULL CHECK (length(email) <= 255),
+    user_status TEXT NOT NULL CHECK (user_status IN ('I', 'A', 'T')),
+    department TEXT NOT NULL CHECK (length(department) <= 255),
+    version INTEGER NOT NULL DEFAULT 1,
+    deleted_at DATETIME
+);
+
+INSERT INTO users_soft_delete (user_id, user_name, first_name, last_name, email, user_status, department, version)
+    SELECT user_id, user_name, first_name, last_name, email, user_status, department, version FROM users;
+UPDATE sqlite_sequence SET seq = (SELECT seq FROM sqlite_sequence WHERE name = 'users') WHERE name = 'users_soft_delete';
+
+CREATE TEMP TABLE user_roles_saved AS SELECT user_id, role_id, department FROM user_roles;
+DROP TABLE users;
+ALTER TABLE users_soft_delete RENAME TO users;
+INSERT INTO user_roles (user_id, role_id, department) SELECT user_id, role_id, department FROM user_roles_saved;
+DROP TABLE user_roles_saved;
+
+CREATE UNIQUE INDEX users_user_name_key ON users (user_name) WHERE deleted_at IS NULL;
+CREATE INDEX users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Let restores be audited. The table has no foreign keys, so it is simply
+-- rebuilt with the wider check.
+CREATE TABLE user_audit_restore (
+    audit_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    actor TEXT NOT NULL CHECK (length(actor) <= 255),
+    request_id TEXT NOT NULL DEFAULT '' CHECK (length(request_id) <= 255),
+    operation TEXT NOT NULL CHECK (operation IN ('create', 'update', 'delete', 'restore')),
+    changes TEXT NOT NULL,
+    occurred_at DATETIME NOT NULL,
+    prev_hash TEXT NOT NULL DEFAULT '' CHECK (length(prev_hash) <= 64),
+    hash TEXT NOT NULL DEFAULT '' CHECK (length(hash) <= 64)
+);
+
+INSERT INTO user_audit_restore SELECT audit_id, user_id, actor, request_id, operation, changes, occurred_at, prev_hash, hash FROM user_audit;
+UPDATE sqlite_sequence SET seq = (SELECT seq FROM sqlite_sequence WHERE name = 'user_audit') WHERE name = 'user_audit_restore';
+DROP TABLE user_audit;
+ALTER TABLE user_audit_restore RENAME TO user_audit;
+
+CREATE INDEX IF NOT EXISTS user_audit_user_id ON user_audit (user_id);
+CREATE INDEX IF NOT EXISTS user_audit_occurred_at ON user_audit (occurred_at);
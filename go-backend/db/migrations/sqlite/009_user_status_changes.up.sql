@@ -0,0 +1,4 @@
+-- Record why and from which day a user has its status. Declaring the column
+-- as a DATE makes the driver read it back as a time.
+ALTER TABLE users ADD COLUMN status_reason TEXT NOT NULL DEFAULT '' CHECK (length(status_reason) <= 255);
+ALTER TABLE users ADD COLUMN status_effective_date DATE;
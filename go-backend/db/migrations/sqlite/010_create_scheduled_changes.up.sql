@@ -0,0 +1,21 @@
+-- Changes of a user's status or department scheduled to take effect at
+-- effective_at. Pending changes are applied once they are due; resolved ones
+-- are kept with who resolved them, when, and why a failed one failed.
+CREATE TABLE IF NOT EXISTS scheduled_changes (
+    change_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    user_status TEXT NOT NULL DEFAULT '' CHECK (user_status IN ('', 'A', 'I', 'T')),
+    department TEXT NOT NULL DEFAULT '' CHECK (length(department) <= 255),
+    reason TEXT NOT NULL DEFAULT '' CHECK (length(reason) <= 255),
+    effective_at DATETIME NOT NULL,
+    state TEXT NOT NULL DEFAULT 'pending' CHECK (state IN ('pending', 'applied', 'cancelled', 'failed')),
+    created_by TEXT NOT NULL DEFAULT '' CHECK (length(created_by) <= 255),
+    created_at DATETIME NOT NULL,
+    resolved_by TEXT NOT NULL DEFAULT '' CHECK (length(resolved_by) <= 255),
+    resolved_at DATETIME,
+    error TEXT NOT NULL DEFAULT '',
+    CHECK (user_status <> '' OR department <> '')
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_changes_user_id ON scheduled_changes (user_id);
+CREATE INDEX IF NOT EXISTS scheduled_changes_due ON scheduled_changes (effective_at) WHERE state = 'pending';
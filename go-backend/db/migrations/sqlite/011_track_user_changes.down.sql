@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS users_stamp_created;
+DROP INDEX IF EXISTS users_updated_at;
+DROP INDEX IF EXISTS users_created_at;
+ALTER TABLE users DROP COLUMN updated_by;
+ALTER TABLE users DROP COLUMN updated_at;
+ALTER TABLE users DROP COLUMN created_by;
+ALTER TABLE users DROP COLUMN created_at;
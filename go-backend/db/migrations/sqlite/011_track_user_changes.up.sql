@@ -0,0 +1,36 @@
+-- Record when and by whom each user was created and last written. Existing
+-- users take both from the audit log where it has them.
+--
+-- SQLite cannot default a new column to the current time, so the times
+-- default to '' and a trigger stamps the rows inserted without them, such as
+-- the sample users, in the format the driver writes times in.
+ALTER TABLE users ADD COLUMN created_at DATETIME NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN created_by TEXT NOT NULL DEFAULT '' CHECK (length(created_by) <= 255);
+ALTER TABLE users ADD COLUMN updated_at DATETIME NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN updated_by TEXT NOT NULL DEFAULT '' CHECK (length(updated_by) <= 255);
+
+UPDATE users SET
+    created_at = coalesce(
+        (SELECT occurred_at FROM user_audit a WHERE a.user_id = users.user_id AND operation = 'create' ORDER BY audit_id LIMIT 1),
+        strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    created_by = coalesce(
+        (SELECT actor FROM user_audit a WHERE a.user_id = users.user_id AND operation = 'create' ORDER BY audit_id LIMIT 1),
+        ''),
+    updated_at = coalesce(
+        (SELECT occurred_at FROM user_audit a WHERE a.user_id = users.user_id ORDER BY audit_id DESC LIMIT 1),
+        strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    updated_by = coalesce(
+        (SELECT actor FROM user_audit a WHERE a.user_id = users.user_id ORDER BY audit_id DESC LIMIT 1),
+        '');
+
+CREATE TRIGGER IF NOT EXISTS users_stamp_created AFTER INSERT ON users
+WHEN NEW.created_at = '' OR NEW.updated_at = ''
+BEGIN
+    UPDATE users SET
+        created_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'),
+        updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+    WHERE user_id = NEW.user_id;
+END;
+
+CREATE INDEX IF NOT EXISTS users_created_at ON users (created_at);
+CREATE INDEX IF NOT EXISTS users_updated_at ON users (updated_at);
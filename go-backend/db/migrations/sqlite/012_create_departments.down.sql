@@ -0,0 +1,47 @@
+-- Rebuild users without the foreign key, as the up migration did with it.
+CREATE TABLE users_free_departments (
+    user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_name TEXT NOT NULL CHECK (length(user_name) <= 50),
+    first_name TEXT NOT NULL CHECK (length(first_name) <= 255),
+    last_name TEXT NOT NULL CHECK (length(last_name) <= 255),
+    email TEXT NOT NULL CHECK (length(email) <= 255),
+    user_status TEXT NOT NULL CHECK (user_status IN ('I', 'A', 'T')),
+    department TEXT NOT NULL CHECK (length(department) <= 255),
+    version INTEGER NOT NULL DEFAULT 1,
+    deleted_at DATETIME,
+    status_reason TEXT NOT NULL DEFAULT '' CHECK (length(status_reason) <= 255),
+    status_effective_date DATE,
+    created_at DATETIME NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL DEFAULT '' CHECK (length(created_by) <= 255),
+    updated_at DATETIME NOT NULL DEFAULT '',
+    updated_by TEXT NOT NULL DEFAULT '' CHECK (length(updated_by) <= 255)
+);
+
+INSERT INTO users_free_departments SELECT user_id, user_name, first_name, last_name, email, user_status, department, version, deleted_at,
+    status_reason, status_effective_date, created_at, created_by, updated_at, updated_by FROM users;
+UPDATE sqlite_sequence SET seq = (SELECT seq FROM sqlite_sequence WHERE name = 'users') WHERE name = 'users_free_departments';
+
+CREATE TEMP TABLE user_roles_saved AS SELECT user_id, role_id, department FROM user_roles;
+CREATE TEMP TABLE scheduled_changes_saved AS SELECT * FROM scheduled_changes;
+DROP TABLE users;
+ALTER TABLE users_free_departments RENAME TO users;
+INSERT INTO user_roles (user_id, role_id, department) SELECT user_id, role_id, department FROM user_roles_saved;
+INSERT INTO scheduled_changes SELECT * FROM scheduled_changes_saved;
+DROP TABLE user_roles_saved;
+DROP TABLE scheduled_changes_saved;
+
+CREATE UNIQUE INDEX users_user_name_key ON users (user_name) WHERE deleted_at IS NULL;
+CREATE INDEX users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX users_created_at ON users (created_at);
+CREATE INDEX users_updated_at ON users (updated_at);
+
+CREATE TRIGGER users_stamp_created AFTER INSERT ON users
+WHEN NEW.created_at = '' OR NEW.updated_at = ''
+BEGIN
+    UPDATE users SET
+        created_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'),
+        updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+    WHERE user_id = NEW.user_id;
+END;
+
+DROP TABLE IF EXISTS departments;
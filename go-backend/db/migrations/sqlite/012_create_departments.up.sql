@@ -0,0 +1,83 @@
+-- Departments, which users now have to belong to. Every department named by
+-- a user, a role grant or a scheduled change is created, and users.department
+-- becomes a foreign key to the department's name, which follows renames. A
+-- department's code is its name, unless the name is longer than the 50
+-- characters a code holds or contains a ~. Such names are cut to fit and
+-- numbered (~1, ~2, ...) instead: numbered codes all contain a ~ and end in
+-- different numbers, so they cannot equal each other or any other code.
+--
+-- The checks are named so that the repository can tell a department that
+-- breaks one from a user, as SQLite only reports a check by its name.
+CREATE TABLE IF NOT EXISTS departments (
+    department_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL UNIQUE CONSTRAINT departments_code_check CHECK (code <> '' AND length(code) <= 50),
+    name TEXT NOT NULL UNIQUE CONSTRAINT departments_name_check CHECK (name <> '' AND length(name) <= 255),
+    parent_id INTEGER REFERENCES departments (department_id),
+    cost_center TEXT NOT NULL DEFAULT '' CONSTRAINT departments_cost_center_check CHECK (length(cost_center) <= 50),
+    CONSTRAINT departments_parent_id_check CHECK (parent_id <> department_id)
+);
+
+CREATE INDEX IF NOT EXISTS departments_parent_id ON departments (parent_id);
+
+INSERT INTO departments (code, name)
+SELECT CASE WHEN numbered THEN substr(name, 1, 50 - length('~' || n)) || '~' || n ELSE name END, name
+FROM (
+    SELECT name, numbered, row_number() OVER (PARTITION BY numbered ORDER BY name) AS n FROM (
+        SELECT name, length(name) > 50 OR name LIKE '%~%' AS numbered FROM (
+            SELECT department AS name FROM users
+            UNION SELECT department FROM user_roles
+            UNION SELECT department FROM scheduled_changes
+        ) named
+        WHERE name <> ''
+    ) flagged
+) numbered_names
+ORDER BY name;
+
+-- SQLite cannot add a foreign key to a table, so users is rebuilt as in
+-- 008_soft_delete_users, carrying over the grants and scheduled changes that
+-- dropping it cascades to, its sequence, indexes and trigger.
+CREATE TABLE users_departments (
+    user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_name TEXT NOT NULL CHECK (length(user_name) <= 50),
+    first_name TEXT NOT NULL CHECK (length(first_name) <= 255),
+    last_name TEXT NOT NULL CHECK (length(last_name) <= 255),
+    email TEXT NOT NULL CHECK (length(email) <= 255),
+    user_status TEXT NOT NULL CHECK (user_status IN ('I', 'A', 'T')),
+    department TEXT NOT NULL CHECK (length(department) <= 255) REFERENCES departments (name) ON UPDATE CASCADE,
+    version INTEGER NOT NULL DEFAULT 1,
+    deleted_at DATETIME,
+    status_reason TEXT NOT NULL DEFAULT '' CHECK (length(status_reason) <= 255),
+    status_effective_date DATE,
+    created_at DATETIME NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL DEFAULT '' CHECK (length(created_by) <= 255),
+    updated_at DATETIME NOT NULL DEFAULT '',
+    updated_by TEXT NOT NULL DEFAULT '' CHECK (length(updated_by) <= 255)
+);
+
+INSERT INTO users_departments SELECT user_id, user_name, first_name, last_name, email, user_status, department, version, deleted_at,
+    status_reason, status_effective_date, created_at, created_by, updated_at, updated_by FROM users;
+UPDATE sqlite_sequence SET seq = (SELECT seq FROM sqlite_sequence WHERE name = 'users') WHERE name = 'users_departments';
+
+CREATE TEMP TABLE user_roles_saved AS SELECT user_id, role_id, department FROM user_roles;
+CREATE TEMP TABLE scheduled_changes_saved AS SELECT * FROM scheduled_changes;
+DROP TABLE users;
+ALTER TABLE users_departments RENAME TO users;
+INSERT INTO user_roles (user_id, role_id, department) SELECT user_id, role_id, department FROM user_roles_saved;
+INSERT INTO scheduled_changes SELECT * FROM scheduled_changes_saved;
+DROP TABLE user_roles_saved;
+DROP TABLE scheduled_changes_saved;
+
+CREATE UNIQUE INDEX users_user_name_key ON users (user_name) WHERE deleted_at IS NULL;
+CREATE INDEX users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX users_created_at ON users (created_at);
+CREATE INDEX users_updated_at ON users (updated_at);
+CREATE INDEX users_department ON users (department);
+
+CREATE TRIGGER users_stamp_created AFTER INSERT ON users
+WHEN NEW.created_at = '' OR NEW.updated_at = ''
+BEGIN
+    UPDATE users SET
+        created_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'),
+        updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+    WHERE user_id = NEW.user_id;
+END;
@@ -0,0 +1,9 @@
+-- Reporting lines: each user may report to a manager, who is another user.
+-- Purging a manager leaves their reports without one.
+--
+-- SQLite can add a column with a foreign key as long as it defaults to NULL,
+-- so users is not rebuilt this time.
+ALTER TABLE users ADD COLUMN manager_id INTEGER REFERENCES users (user_id) ON DELETE SET NULL
+    CONSTRAINT users_manager_id_check CHECK (manager_id <> user_id);
+
+CREATE INDEX IF NOT EXISTS users_manager_id ON users (manager_id);
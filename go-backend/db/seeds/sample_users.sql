@@ -0,0 +1,26 @@
+-- Sample users for local development, and the departments they belong to.
+-- Safe to run repeatedly.
+INSERT INTO departments (code, name) VALUES
+('DES', 'Design'),
+('DEV', 'Development'),
+('ENG', 'Engineering'),
+('FIN', 'Finance'),
+('HR', 'HR'),
+('MGT', 'Management'),
+('MKT', 'Marketing'),
+('SAL', 'Sales'),
+('SUP', 'Support')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO users (user_name, first_name, last_name, email, user_status, department) VALUES 
+('jdoe', 'John', 'Doe', 'john.doe@example.com', 'A', 'Engineering'),
+('asmith', 'Alice', 'Smith', 'alice.smith@example.com', 'I', 'Marketing'),
+('bwilson', 'Bob', 'Wilson', 'bob.wilson@example.com', 'A', 'Sales'),
+('mjones', 'Mary', 'Jones', 'mary.jones@example.com', 'I', 'HR'),
+('djames', 'David', 'James', 'david.james@example.com', 'T', 'Engineering'),
+('lwhite', 'Linda', 'White', 'linda.white@example.com', 'A', 'Finance'),
+('cgreen', 'Chris', 'Green', 'chris.green@example.com', 'T', 'Support'),
+('rblack', 'Rachel', 'Black', 'rachel.black@example.com', 'I', 'Development'),
+('tjohnson', 'Tom', 'Johnson', 'tom.johnson@example.com', 'A', 'Design'),
+('pclark', 'Peter', 'Clark', 'peter.clark@example.com', 'T', 'Management')
+ON CONFLICT (user_name) WHERE deleted_at IS NULL DO NOTHING;
@@ -0,0 +1,18 @@
+// Package seeds holds optional sample data that can be loaded into a freshly
+// migrated database with the seed command.
+package seeds
+
+import (
+	"database/sql"
+	_ "embed"
+)
+
+//go:embed sample_users.sql
+var sampleUsers string
+
+// Run inserts the sample users and their departments, skipping any whose
+// user name, or department code or name, already exists.
+func Run(db *sql.DB) error {
+	_, err := db.Exec(sampleUsers)
+	return err
+}
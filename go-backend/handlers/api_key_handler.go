@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sedmo/integra-coding-assessment/go-backend/auth"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// APIKeyHandler serves the endpoints that create, list and revoke API keys.
+type APIKeyHandler struct {
+	keys repository.APIKeyRepository
+}
+
+// NewAPIKeyHandler returns a handler that stores API keys in keys.
+func NewAPIKeyHandler(keys repository.APIKeyRepository) *APIKeyHandler {
+	return &APIKeyHandler{keys: keys}
+}
+
+// @Summary Create API key
+// @Description Create an API key carrying the given user permissions. The
+// @Description response holds the key itself, which cannot be retrieved again.
+// @Tags api-keys
+// @Accept  json
+// @Produce  json
+// @Param key body models.NewAPIKey true "New API key"
+// @Param Accept-Language header string false "Language of validation messages (en, fr or es)"
+// @Success 201 {object} models.CreatedAPIKey
+// @Failure 400 {object} handlers.Problem "Validation failed"
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c echo.Context) error {
+	request := new(models.NewAPIKey)
+	if err := c.Bind(request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := request.Validate(); err != nil {
+		return validationFailed(c, err)
+	}
+	now := time.Now().UTC()
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(now) {
+		return echo.NewHTTPError(http.StatusBadRequest, "expires_at must be in the future")
+	}
+
+	secret, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	scopes := slices.Clone(request.Scopes)
+	slices.Sort(scopes)
+	key := models.APIKey{
+		Name:       request.Name,
+		Prefix:     prefix,
+		Scopes:     slices.Compact(scopes),
+		CreatedBy:  auth.Subject(c),
+		CreatedAt:  now.Truncate(time.Microsecond),
+		ExpiresAt:  request.ExpiresAt,
+		SecretHash: hash,
+	}
+	if err := h.keys.CreateAPIKey(c.Request().Context(), &key); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, models.CreatedAPIKey{APIKey: key, Key: secret})
+}
+
+// @Summary Get API keys
+// @Description List every API key, revoked ones included. The keys
+// @Description themselves are never listed.
+// @Tags api-keys
+// @Produce  json
+// @Success 200 {array} models.APIKey
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /api-keys [get]
+func (h *APIKeyHandler) GetAPIKeys(c echo.Context) error {
+	keys, err := h.keys.APIKeys(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, keys)
+}
+
+// @Summary Revoke API key
+// @Description Revoke an API key, which is refused from then on. Revoking a
+// @Description revoked key changes nothing.
+// @Tags api-keys
+// @Produce  json
+// @Param id path int true "API key ID"
+// @Success 200 {object} models.APIKey
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid api key id")
+	}
+	key, err := h.keys.RevokeAPIKey(c.Request().Context(), id, time.Now().UTC().Truncate(time.Microsecond))
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return apiKeyNotFound(id)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, key)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sedmo/integra-coding-assessment/go-backend/auth"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// AuditHandler serves the audit log of changes to users.
+type AuditHandler struct {
+	audit repository.AuditRepository
+}
+
+// NewAuditHandler returns a handler that reads the audit log from audit.
+func NewAuditHandler(audit repository.AuditRepository) *AuditHandler {
+	return &AuditHandler{audit: audit}
+}
+
+// @Summary Get audit log
+// @Description List the changes made to users, newest first
+// @Tags audit
+// @Produce  json
+// @Param user_id query int false "Only changes to this user"
+// @Param actor query string false "Only changes by this actor (repeatable)"
+// @Param operation query string false "Only this operation: create, update, delete or restore (repeatable)"
+// @Param from query string false "Only changes at or after this RFC 3339 time"
+// @Param to query string false "Only changes before this RFC 3339 time"
+// @Param limit query int false "Page size (1-500)" default(50)
+// @Param offset query int false "Number of entries to skip"
+// @Success 200 {object} models.AuditPage
+// @Failure 400 {object} handlers.Problem
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /audit [get]
+func (h *AuditHandler) GetAuditLog(c echo.Context) error {
+	filter, err := parseAuditFilter(c.QueryParams())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if v := c.QueryParam("user_id"); v != "" {
+		if filter.UserID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid user_id")
+		}
+	}
+	return h.respondWithAudit(c, filter)
+}
+
+// @Summary Get user history
+// @Description List the changes made to a user, newest first. The history of
+// @Description a deleted user is kept.
+// @Tags audit
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param actor query string false "Only changes by this actor (repeatable)"
+// @Param operation query string false "Only this operation: create, update, delete or restore (repeatable)"
+// @Param from query string false "Only changes at or after this RFC 3339 time"
+// @Param to query string false "Only changes before this RFC 3339 time"
+// @Param limit query int false "Page size (1-500)" default(50)
+// @Param offset query int false "Number of entries to skip"
+// @Success 200 {object} models.AuditPage
+// @Failure 400 {object} handlers.Problem
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /users/{id}/history [get]
+func (h *AuditHandler) GetUserHistory(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+	filter, err := parseAuditFilter(c.QueryParams())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	filter.UserID = id
+	return h.respondWithAudit(c, filter)
+}
+
+// @Summary Verify audit chain
+// @Description Walk the audit log from its first entry and report the first
+// @Description entry that does not chain onto the ones before it, or the last
+// @Description entry when the chain does not end at its anchored head, which
+// @Description means entries were edited or deleted in the database. Note the
+// @Description returned head to also detect the anchor being rewound.
+// @Tags audit
+// @Produce  json
+// @Success 200 {object} models.AuditVerification
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /audit/verify [get]
+func (h *AuditHandler) VerifyAudit(c echo.Context) error {
+	report, err := repository.VerifyAuditChain(c.Request().Context(), h.audit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+func (h *AuditHandler) respondWithAudit(c echo.Context, filter *repository.AuditFilter) error {
+	entries, total, err := h.audit.AuditLog(c.Request().Context(), *filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, models.AuditPage{Data: entries, Total: total, Limit: filter.Limit, Offset: filter.Offset})
+}
+
+// parseAuditFilter reads the filters and paging options shared by the audit
+// endpoints.
+func parseAuditFilter(q url.Values) (*repository.AuditFilter, error) {
+	filter := &repository.AuditFilter{Limit: defaultPageLimit, Actors: q["actor"]}
+
+	for _, op := range q["operation"] {
+		switch op {
+		case models.AuditCreate, models.AuditUpdate, models.AuditDelete, models.AuditRestore:
+			filter.Operations = append(filter.Operations, op)
+		default:
+			return nil, fmt.Errorf("unknown operation %q", op)
+		}
+	}
+
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &filter.Since}, {"to", &filter.Until}} {
+		if v := q.Get(bound.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an RFC 3339 time", bound.name)
+			}
+			*bound.t = t
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return nil, fmt.Errorf("limit must be an integer between 1 and %d", maxPageLimit)
+		}
+		filter.Limit = limit
+	}
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, errors.New("offset must be a non-negative integer")
+		}
+		filter.Offset = offset
+	}
+	return filter, nil
+}
+
+// auditEntry returns the entry recording that the request in c performed
+// operation on a user, which was before and became after.
+func auditEntry(c echo.Context, operation string, before, after *models.User) *models.AuditEntry {
+	entry := models.NewAuditEntry(operation, before, after)
+	entry.Actor = auth.Subject(c)
+	entry.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+	if entry.RequestID == "" {
+		entry.RequestID = c.Request().Header.Get(echo.HeaderXRequestID)
+	}
+	entry.At = time.Now().UTC().Truncate(time.Microsecond)
+	return entry
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// DepartmentHandler serves the endpoints that manage the departments users
+// belong to.
+type DepartmentHandler struct {
+	users repository.UserRepository
+}
+
+// NewDepartmentHandler returns a handler that stores departments in users,
+// where renaming one is recorded in the audit log of the users it moves.
+func NewDepartmentHandler(users repository.UserRepository) *DepartmentHandler {
+	return &DepartmentHandler{users: users}
+}
+
+// @Summary Get departments
+// @Description List every department, ordered by name
+// @Tags departments
+// @Produce  json
+// @Success 200 {array} models.Department
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /departments [get]
+func (h *DepartmentHandler) GetDepartments(c echo.Context) error {
+	departments, err := h.users.Departments(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, departments)
+}
+
+// @Summary Get department
+// @Description Get a department by id
+// @Tags departments
+// @Produce  json
+// @Param id path int true "Department ID"
+// @Success 200 {object} models.Department
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /departments/{id} [get]
+func (h *DepartmentHandler) GetDepartment(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid department id")
+	}
+	department, err := h.users.Department(c.Request().Context(), id)
+	if err != nil {
+		return departmentWriteError(id, err)
+	}
+	return c.JSON(http.StatusOK, department)
+}
+
+// @Summary Create department
+// @Description Create a department. Its code and name must be unique, and
+// @Description its parent, if any, must exist.
+// @Tags departments
+// @Accept  json
+// @Produce  json
+// @Param department body models.Department true "New department"
+// @Param Accept-Language header string false "Language of validation messages (en, fr or es)"
+// @Success 201 {object} models.Department
+// @Failure 400 {object} handlers.Problem "Validation failed"
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 409 {object} handlers.Problem "The code or name is taken"
+// @Security BearerAuth
+// @Router /departments [post]
+func (h *DepartmentHandler) CreateDepartment(c echo.Context) error {
+	department := new(models.Department)
+	if err := c.Bind(department); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := department.Validate(); err != nil {
+		return validationFailed(c, err)
+	}
+	department.ID = 0
+
+	if err := h.users.CreateDepartment(c.Request().Context(), department); err != nil {
+		return departmentWriteError(0, err)
+	}
+	return c.JSON(http.StatusCreated, department)
+}
+
+// @Summary Update department
+// @Description Replace a department. Renaming it moves its users, whose
+// @Description change is recorded in their history, along with the roles
+// @Description granted and the pending changes scheduled in it. A department
+// @Description cannot be placed under itself or one of its sub-departments.
+// @Tags departments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Department ID"
+// @Param department body models.Department true "Updated department"
+// @Param Accept-Language header string false "Language of validation messages (en, fr or es)"
+// @Success 200 {object} models.Department
+// @Failure 400 {object} handlers.Problem "Validation failed"
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Failure 409 {object} handlers.Problem "The code or name is taken"
+// @Security BearerAuth
+// @Router /departments/{id} [put]
+func (h *DepartmentHandler) UpdateDepartment(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid department id")
+	}
+	department := new(models.Department)
+	if err := c.Bind(department); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if department.ID != 0 && department.ID != id {
+		return echo.NewHTTPError(http.StatusBadRequest, "id in body does not match the id in the path")
+	}
+	department.ID = id
+	if err := department.Validate(); err != nil {
+		return validationFailed(c, err)
+	}
+
+	ctx := c.Request().Context()
+	err = h.users.WithTx(ctx, func(tx repository.UserRepository) error {
+		stored, err := tx.Department(ctx, id)
+		if err != nil {
+			return err
+		}
+		var moved []models.User
+		if stored.Name != department.Name {
+			moved, _, err = tx.List(repository.WithDeleted(ctx), repository.ListOptions{
+				Filters: []repository.Filter{{Column: "department", Values: []string{stored.Name}}},
+				Sort:    []repository.SortField{{Column: "user_id"}},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if err := tx.UpdateDepartment(ctx, department); err != nil {
+			return err
+		}
+
+		for i := range moved {
+			after, err := tx.Get(repository.WithDeleted(ctx), moved[i].UserID)
+			if err != nil {
+				return err
+			}
+			if err := tx.RecordAudit(ctx, auditEntry(c, models.AuditUpdate, &moved[i], after)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return departmentWriteError(id, err)
+	}
+	return c.JSON(http.StatusOK, department)
+}
+
+// @Summary Delete department
+// @Description Delete a department and the roles granted in it. A department
+// @Description that users, deleted ones included, or sub-departments still
+// @Description belong to cannot be deleted.
+// @Tags departments
+// @Produce  json
+// @Param id path int true "Department ID"
+// @Success 200 {object} models.Department
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Failure 409 {object} handlers.Problem "The department is in use"
+// @Security BearerAuth
+// @Router /departments/{id} [delete]
+func (h *DepartmentHandler) DeleteDepartment(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid department id")
+	}
+	ctx := c.Request().Context()
+	department, err := h.users.Department(ctx, id)
+	if err != nil {
+		return departmentWriteError(id, err)
+	}
+	if err := h.users.DeleteDepartment(ctx, id); err != nil {
+		return departmentWriteError(id, err)
+	}
+	return c.JSON(http.StatusOK, department)
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// ErrorResponse is the message of errors that clients are expected to act
+// on, such as a missing resource. HTTPErrorHandler renders its Code and
+// Message as the code and detail of a Problem.
+type ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func userNotFound(field string, value interface{}) *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusNotFound, ErrorResponse{
+		Code:    "user_not_found",
+		Message: fmt.Sprintf("no user with %s %v", field, value),
+	})
+}
+
+func userNameTaken() *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusConflict, ErrorResponse{
+		Code:    "user_name_taken",
+		Message: "username already exists",
+	})
+}
+
+func userNotDeleted() *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusConflict, ErrorResponse{
+		Code:    "user_not_deleted",
+		Message: "user is not deleted",
+	})
+}
+
+func illegalTransition(err *models.TransitionError) *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusConflict, ErrorResponse{
+		Code:    "illegal_status_transition",
+		Message: err.Error(),
+	})
+}
+
+func departmentOutOfScope() *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusForbidden, ErrorResponse{
+		Code:    "department_out_of_scope",
+		Message: "you can only manage users of the departments you were granted",
+	})
+}
+
+func unknownDepartment(message string) *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusBadRequest, ErrorResponse{
+		Code:    "unknown_department",
+		Message: message,
+	})
+}
+
+func unknownManager() *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusBadRequest, ErrorResponse{
+		Code:    "unknown_manager",
+		Message: "manager_id must be the id of a user that is not deleted",
+	})
+}
+
+func managerCycle() *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusBadRequest, ErrorResponse{
+		Code:    "manager_cycle",
+		Message: "a user cannot report to themselves or to one of their reports",
+	})
+}
+
+func preconditionFailed() *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusPreconditionFailed, ErrorResponse{
+		Code:    "precondition_failed",
+		Message: "user has been modified since it was fetched",
+	})
+}
+
+// userWriteError translates a repository error from a write to user id
+// into the HTTP error reported to the client.
+func userWriteError(id int64, err error) error {
+	var transitionErr *models.TransitionError
+	switch {
+	case errors.As(err, &transitionErr):
+		return illegalTransition(transitionErr)
+	case errors.Is(err, repository.ErrNotFound):
+		return userNotFound("id", id)
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return preconditionFailed()
+	case errors.Is(err, repository.ErrUserNameTaken):
+		return userNameTaken()
+	case errors.Is(err, repository.ErrNotDeleted):
+		return userNotDeleted()
+	case errors.Is(err, repository.ErrOutOfScope):
+		return departmentOutOfScope()
+	case errors.Is(err, repository.ErrUnknownDepartment):
+		return unknownDepartment("department does not exist; create it under /departments first")
+	case errors.Is(err, repository.ErrUnknownManager):
+		return unknownManager()
+	case errors.Is(err, repository.ErrManagerCycle):
+		return managerCycle()
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+}
+
+func roleNotFound(name string) *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusNotFound, ErrorResponse{
+		Code:    "role_not_found",
+		Message: fmt.Sprintf("no role named %q", name),
+	})
+}
+
+func apiKeyNotFound(id int64) *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusNotFound, ErrorResponse{
+		Code:    "api_key_not_found",
+		Message: fmt.Sprintf("no api key with id %d", id),
+	})
+}
+
+func scheduledChangeNotFound(id int64) *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusNotFound, ErrorResponse{
+		Code:    "scheduled_change_not_found",
+		Message: fmt.Sprintf("no scheduled change with id %d", id),
+	})
+}
+
+func scheduledChangeNotPending() *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusConflict, ErrorResponse{
+		Code:    "scheduled_change_not_pending",
+		Message: "scheduled change has already been applied, cancelled or failed",
+	})
+}
+
+// scheduledChangeError translates a repository error from reading or
+// resolving scheduled change id into the HTTP error reported to the client.
+func scheduledChangeError(id int64, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrChangeNotFound):
+		return scheduledChangeNotFound(id)
+	case errors.Is(err, repository.ErrChangeNotPending):
+		return scheduledChangeNotPending()
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+}
+
+func departmentNotFound(id int64) *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusNotFound, ErrorResponse{
+		Code:    "department_not_found",
+		Message: fmt.Sprintf("no department with id %d", id),
+	})
+}
+
+// departmentWriteError translates a repository error from a write to
+// department id into the HTTP error reported to the client.
+func departmentWriteError(id int64, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrDepartmentNotFound):
+		return departmentNotFound(id)
+	case errors.Is(err, repository.ErrUnknownDepartment):
+		return unknownDepartment("parent department does not exist")
+	case errors.Is(err, repository.ErrDepartmentCycle):
+		return echo.NewHTTPError(http.StatusBadRequest, ErrorResponse{
+			Code:    "department_cycle",
+			Message: "a department cannot be placed under itself or one of its sub-departments",
+		})
+	case errors.Is(err, repository.ErrDepartmentCodeTaken):
+		return echo.NewHTTPError(http.StatusConflict, ErrorResponse{
+			Code:    "department_code_taken",
+			Message: "department code already exists",
+		})
+	case errors.Is(err, repository.ErrDepartmentNameTaken):
+		return echo.NewHTTPError(http.StatusConflict, ErrorResponse{
+			Code:    "department_name_taken",
+			Message: "department name already exists",
+		})
+	case errors.Is(err, repository.ErrDepartmentInUse):
+		return echo.NewHTTPError(http.StatusConflict, ErrorResponse{
+			Code:    "department_in_use",
+			Message: "department still has users, deleted ones included, or sub-departments",
+		})
+	case errors.Is(err, repository.ErrInvalidDepartment):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+}
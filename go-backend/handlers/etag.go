@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+const (
+	headerETag        = "ETag"
+	headerIfMatch     = "If-Match"
+	headerIfNoneMatch = "If-None-Match"
+)
+
+// userETag returns the strong entity tag of a user. It changes whenever the
+// row's version is bumped by a write.
+func userETag(user *models.User) string {
+	return fmt.Sprintf(`"%d-%d"`, user.UserID, user.Version)
+}
+
+func setUserETag(c echo.Context, user *models.User) {
+	c.Response().Header().Set(headerETag, userETag(user))
+}
+
+// splitETags splits an If-Match or If-None-Match header into its entity tags.
+func splitETags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// notModified reports whether the If-None-Match header of the request matches
+// the user's current entity tag. If-None-Match uses weak comparison.
+func notModified(c echo.Context, user *models.User) bool {
+	header := c.Request().Header.Get(headerIfNoneMatch)
+	if header == "" {
+		return false
+	}
+	current := userETag(user)
+	for _, tag := range splitETags(header) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == current {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersions parses the If-Match header of the request into a
+// precondition on the version of user id. It returns nil when the request
+// carries no If-Match header or uses "*", in which case any existing user
+// matches. If-Match uses strong comparison, so weak tags never match.
+func ifMatchVersions(c echo.Context, id int64) (*repository.Precondition, error) {
+	header := c.Request().Header.Get(headerIfMatch)
+	if header == "" {
+		return nil, nil
+	}
+
+	versions := []int64{}
+	for _, tag := range splitETags(header) {
+		if tag == "*" {
+			return nil, nil
+		}
+		var tagID, version int64
+		if _, err := fmt.Sscanf(tag, `"%d-%d"`, &tagID, &version); err != nil {
+			if strings.HasPrefix(tag, "W/") || strings.HasPrefix(tag, `"`) {
+				continue
+			}
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "malformed If-Match header")
+		}
+		if tagID == id {
+			versions = append(versions, version)
+		}
+	}
+	return &repository.Precondition{Versions: versions}, nil
+}
+
+// userIfMatch checks the If-Match header of the request against a user that
+// has already been loaded.
+func userIfMatch(c echo.Context, user *models.User) error {
+	header := c.Request().Header.Get(headerIfMatch)
+	if header == "" {
+		return nil
+	}
+	current := userETag(user)
+	for _, tag := range splitETags(header) {
+		if tag == "*" || tag == current {
+			return nil
+		}
+	}
+	return preconditionFailed()
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// @Summary Health check
+// @Description Reports that the server is up. It needs no token.
+// @Tags health
+// @Produce  json
+// @Success 200 {object} map[string]string
+// @Router /healthz [get]
+func Health(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/orgchart"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// @Summary Get user reports
+// @Description List the users who report directly to a user, ordered by id
+// @Tags users
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {array} models.User
+// @Failure 400 {object} handlers.Problem
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /users/{id}/reports [get]
+func (h *UserHandler) GetUserReports(c echo.Context) error {
+	return h.respondWithHierarchy(c, func(ctx context.Context, id int64) ([]models.User, error) {
+		users, _, err := h.users.List(ctx, repository.ListOptions{
+			Filters: []repository.Filter{{Column: "manager_id", Values: []string{strconv.FormatInt(id, 10)}}},
+			Sort:    []repository.SortField{{Column: "user_id"}},
+		})
+		return users, err
+	})
+}
+
+// @Summary Get user management chain
+// @Description List the managers of a user, from their own manager up to the
+// @Description top of the hierarchy
+// @Tags users
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {array} models.User
+// @Failure 400 {object} handlers.Problem
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /users/{id}/managers [get]
+func (h *UserHandler) GetUserManagers(c echo.Context) error {
+	return h.respondWithHierarchy(c, h.users.ManagementChain)
+}
+
+// @Summary Get user subordinates
+// @Description List the users who report to a user, directly or through
+// @Description others, ordered by id
+// @Tags users
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {array} models.User
+// @Failure 400 {object} handlers.Problem
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /users/{id}/subordinates [get]
+func (h *UserHandler) GetUserSubordinates(c echo.Context) error {
+	return h.respondWithHierarchy(c, h.users.Subordinates)
+}
+
+// respondWithHierarchy responds with the users list returns for the user in
+// the id path parameter, once it has checked the caller may see that user.
+func (h *UserHandler) respondWithHierarchy(c echo.Context, list func(ctx context.Context, id int64) ([]models.User, error)) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+
+	ctx := c.Request().Context()
+	if _, err := h.users.Get(ctx, id); err != nil {
+		return userWriteError(id, err)
+	}
+	users, err := list(ctx, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, users)
+}
+
+// @Summary Get org chart
+// @Description Arrange users into trees by their reporting lines. Users whose
+// @Description manager is not shown root a tree of their own. The chart is
+// @Description written as a JSON array of trees, a Graphviz DOT digraph or a
+// @Description Mermaid flowchart.
+// @Tags users
+// @Produce  json
+// @Produce  plain
+// @Param format query string false "json, dot or mermaid" default(json)
+// @Param root query int false "Only chart this user and their subordinates"
+// @Success 200 {array} models.OrgChartNode
+// @Failure 400 {object} handlers.Problem
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /org-chart [get]
+func (h *UserHandler) GetOrgChart(c echo.Context) error {
+	format := orgchart.JSON
+	if v := c.QueryParam("format"); v != "" {
+		var err error
+		if format, err = orgchart.ParseFormat(v); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	ctx := c.Request().Context()
+	var users []models.User
+	if v := c.QueryParam("root"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "root must be a user id")
+		}
+		root, err := h.users.Get(ctx, id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return userNotFound("id", id)
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		subordinates, err := h.users.Subordinates(ctx, id)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		// The chart is drawn from the root down even when it has a manager.
+		root.ManagerID = nil
+		users = append([]models.User{*root}, subordinates...)
+	} else {
+		var err error
+		if users, _, err = h.users.List(ctx, repository.ListOptions{}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, format.ContentType())
+	c.Response().WriteHeader(http.StatusOK)
+	return orgchart.Write(c.Response(), format, orgchart.Build(users))
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// sortableColumns whitelists the columns accepted by the sort parameter.
+var sortableColumns = map[string]bool{
+	"user_id":     true,
+	"user_name":   true,
+	"first_name":  true,
+	"last_name":   true,
+	"email":       true,
+	"user_status": true,
+	"department":  true,
+	"created_at":  true,
+	"created_by":  true,
+	"updated_at":  true,
+	"updated_by":  true,
+}
+
+// exactFilterColumns may be filtered on by equality, e.g. ?department=Sales.
+// Repeating the parameter matches any of the given values.
+var exactFilterColumns = []string{"user_status", "department", "user_name", "email", "created_by", "updated_by", "manager_id"}
+
+// prefixFilterColumns may be filtered on by prefix, e.g. ?email_prefix=j.doe.
+var prefixFilterColumns = []string{"department", "user_name", "email"}
+
+// rangeFilterColumns may be filtered on by time range, e.g.
+// ?updated_from=2024-01-01T00:00:00Z for the users written since then. The
+// range includes its start and excludes its end, given as RFC 3339 times.
+var rangeFilterColumns = []string{"created_at", "updated_at"}
+
+// listCursor is the decoded form of the opaque cursor handed out by GetUsers.
+// It records the sort key of the row at the page boundary and whether the
+// client is paging forwards (after the row) or backwards (before it).
+type listCursor struct {
+	Sort   string                 `json:"s"`
+	Before bool                   `json:"b,omitempty"`
+	Keys   map[string]interface{} `json:"k"`
+}
+
+// listParams is the repository query described by the query string of a
+// listing request.
+type listParams struct {
+	repository.ListOptions
+}
+
+func parseListParams(q url.Values) (*listParams, error) {
+	p := &listParams{repository.ListOptions{Limit: defaultPageLimit}}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return nil, fmt.Errorf("limit must be an integer between 1 and %d", maxPageLimit)
+		}
+		p.Limit = limit
+	}
+
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, errors.New("offset must be a non-negative integer")
+		}
+		p.Offset = offset
+	}
+
+	sort, err := parseSort(q["sort"])
+	if err != nil {
+		return nil, err
+	}
+	p.Sort = sort
+
+	for _, column := range exactFilterColumns {
+		if values := q[column]; len(values) > 0 {
+			if column == "manager_id" {
+				// Canonicalise the ids so the memory store, which compares
+				// formatted values, agrees with the databases.
+				values = slices.Clone(values)
+				for i, v := range values {
+					id, err := strconv.ParseInt(v, 10, 64)
+					if err != nil {
+						return nil, errors.New("manager_id must be an integer")
+					}
+					values[i] = strconv.FormatInt(id, 10)
+				}
+			}
+			p.Filters = append(p.Filters, repository.Filter{Column: column, Values: values})
+		}
+	}
+	for _, column := range prefixFilterColumns {
+		if v := q.Get(column + "_prefix"); v != "" {
+			p.Filters = append(p.Filters, repository.Filter{Column: column, Values: []string{v}, Prefix: true})
+		}
+	}
+
+	for _, column := range rangeFilterColumns {
+		param := strings.TrimSuffix(column, "_at")
+		tr := repository.TimeRange{Column: column}
+		for _, bound := range []struct {
+			name string
+			t    *time.Time
+		}{{param + "_from", &tr.Since}, {param + "_to", &tr.Until}} {
+			if v := q.Get(bound.name); v != "" {
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					return nil, fmt.Errorf("%s must be an RFC 3339 time", bound.name)
+				}
+				*bound.t = t
+			}
+		}
+		if !tr.Since.IsZero() || !tr.Until.IsZero() {
+			p.Ranges = append(p.Ranges, tr)
+		}
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		if p.Offset != 0 {
+			return nil, errors.New("offset and cursor cannot be combined")
+		}
+		cursor, err := decodeCursor(v)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != encodeSort(p.Sort) {
+			return nil, errors.New("cursor was issued for a different sort order")
+		}
+		for _, f := range p.Sort {
+			if _, ok := cursor.Keys[f.Column]; !ok {
+				return nil, errors.New("invalid cursor")
+			}
+		}
+		p.Cursor = &repository.Cursor{Keys: cursor.Keys, Before: cursor.Before}
+	}
+
+	return p, nil
+}
+
+// parseSort parses a comma separated list of columns, each optionally
+// prefixed with "-" for descending or "+" for ascending order. user_id is
+// always appended as a final tie-breaker so that pages are stable.
+func parseSort(values []string) ([]repository.SortField, error) {
+	var fields []repository.SortField
+	seen := map[string]bool{}
+	for _, value := range values {
+		for _, token := range strings.Split(value, ",") {
+			token = strings.TrimSpace(token)
+			if token == "" {
+				continue
+			}
+			field := repository.SortField{Column: token}
+			switch token[0] {
+			case '-':
+				field = repository.SortField{Column: token[1:], Desc: true}
+			case '+':
+				field = repository.SortField{Column: token[1:]}
+			}
+			if !sortableColumns[field.Column] {
+				return nil, fmt.Errorf("cannot sort by %q", field.Column)
+			}
+			if seen[field.Column] {
+				return nil, fmt.Errorf("duplicate sort column %q", field.Column)
+			}
+			seen[field.Column] = true
+			fields = append(fields, field)
+		}
+	}
+	if !seen["user_id"] {
+		fields = append(fields, repository.SortField{Column: "user_id"})
+	}
+	return fields, nil
+}
+
+func encodeSort(fields []repository.SortField) string {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		if f.Desc {
+			parts[i] = "-" + f.Column
+		} else {
+			parts[i] = f.Column
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// cursorFor returns the opaque cursor pointing after (or before) the given user.
+func (p *listParams) cursorFor(user models.User, before bool) string {
+	keys := make(map[string]interface{}, len(p.Sort))
+	for _, f := range p.Sort {
+		keys[f.Column] = repository.ColumnValue(user, f.Column)
+	}
+	raw, _ := json.Marshal(listCursor{Sort: encodeSort(p.Sort), Before: before, Keys: keys})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (*listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var cursor listCursor
+	if err := dec.Decode(&cursor); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	if n, ok := cursor.Keys["user_id"].(json.Number); ok {
+		id, err := n.Int64()
+		if err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+		cursor.Keys["user_id"] = id
+	}
+	for _, column := range rangeFilterColumns {
+		if s, ok := cursor.Keys[column].(string); ok {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, errors.New("invalid cursor")
+			}
+			cursor.Keys[column] = t
+		}
+	}
+	return &cursor, nil
+}
+
+// pageURL returns the request URL with the given pagination parameters
+// replaced, keeping every filter and the sort order intact.
+func pageURL(u *url.URL, set map[string]string) string {
+	q := u.Query()
+	q.Del("offset")
+	q.Del("cursor")
+	for k, v := range set {
+		q.Set(k, v)
+	}
+	return u.Path + "?" + q.Encode()
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"strings"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/text/language"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+// translator picks the translator of the request's Accept-Language header,
+// e.g. "fr-CA,fr;q=0.9,en;q=0.8". Regional tags fall back to their base
+// language and anything unsupported to English.
+func translator(c echo.Context) ut.Translator {
+	tags, _, _ := language.ParseAcceptLanguage(c.Request().Header.Get("Accept-Language"))
+
+	preferred := make([]string, 0, 2*len(tags))
+	for _, tag := range tags {
+		preferred = append(preferred, strings.ReplaceAll(tag.String(), "-", "_"))
+		if base, confidence := tag.Base(); confidence != language.No {
+			preferred = append(preferred, base.String())
+		}
+	}
+	return models.Translator(preferred...)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+const (
+	// MIMEMergePatch is the media type of an RFC 7396 JSON Merge Patch.
+	MIMEMergePatch = "application/merge-patch+json"
+	// MIMEJSONPatch is the media type of an RFC 6902 JSON Patch.
+	MIMEJSONPatch = "application/json-patch+json"
+)
+
+// applyUserPatch applies the patch document in body to user and returns the
+// patched copy. The content type selects between merge patch and JSON patch.
+func applyUserPatch(user *models.User, contentType string, body []byte) (*models.User, error) {
+	original, err := json.Marshal(user)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	var patched []byte
+	switch contentType {
+	case MIMEMergePatch:
+		patched, err = jsonpatch.MergePatch(original, body)
+	case MIMEJSONPatch:
+		var patch jsonpatch.Patch
+		patch, err = jsonpatch.DecodePatch(body)
+		if err == nil {
+			patched, err = patch.Apply(original)
+		}
+	default:
+		return nil, echo.NewHTTPError(http.StatusUnsupportedMediaType,
+			"content type must be "+MIMEMergePatch+" or "+MIMEJSONPatch)
+	}
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid patch: "+err.Error())
+	}
+
+	// Decode strictly so that patches touching unknown members or changing
+	// a member's type are rejected rather than silently dropped.
+	result := new(models.User)
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(result); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "patched user is invalid: "+err.Error())
+	}
+	if result.UserID != user.UserID {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "user_id cannot be changed")
+	}
+	return result, nil
+}
+
+// changedUserColumns returns the writable columns whose values differ
+// between before and after.
+func changedUserColumns(before, after *models.User) []string {
+	var changed []string
+	for _, column := range repository.WritableColumns {
+		if repository.ColumnValue(*after, column) != repository.ColumnValue(*before, column) {
+			changed = append(changed, column)
+		}
+	}
+	return changed
+}
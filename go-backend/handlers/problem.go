@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/auth"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+// MIMEProblemJSON is the media type of an RFC 7807 problem details body.
+const MIMEProblemJSON = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body. Every error response of the
+// API uses it. Code is a stable, machine readable identifier of the problem,
+// Errors lists the failing fields of a validation problem and Permission
+// names the permission a forbidden caller lacks.
+type Problem struct {
+	Type       string       `json:"type"`
+	Title      string       `json:"title"`
+	Status     int          `json:"status"`
+	Detail     string       `json:"detail,omitempty"`
+	Instance   string       `json:"instance,omitempty"`
+	Code       string       `json:"code,omitempty"`
+	Errors     []FieldError `json:"errors,omitempty"`
+	Permission string       `json:"permission,omitempty"`
+}
+
+// FieldError describes one field that failed validation. Field is the JSON
+// name of the member, Rule the validation rule it broke (such as required,
+// max, email or oneof) and Param the rule's parameter, if any. Message
+// describes the failure in the language asked for by Accept-Language.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// validationFailed turns the error returned by a model's Validate method into
+// a 400 listing every failing field, described in the request's language.
+// Anything other than a validation failure, such as
+// validator.InvalidValidationError, is a server error.
+func validationFailed(c echo.Context, err error) error {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	trans := translator(c)
+	detail, _ := trans.T(models.ValidationFailedKey)
+	problem := &Problem{
+		Status: http.StatusBadRequest,
+		Code:   "validation_failed",
+		Detail: detail,
+	}
+	for _, fe := range validationErrors {
+		problem.Errors = append(problem.Errors, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: fe.Translate(trans),
+		})
+	}
+	c.Response().Header().Set("Content-Language", trans.Locale())
+	return echo.NewHTTPError(http.StatusBadRequest, problem)
+}
+
+// HTTPErrorHandler writes every error returned by a handler as an
+// application/problem+json body. Errors other than *echo.HTTPError are
+// reported as a bare 500.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	problem := problemFor(err)
+	problem.Instance = c.Request().URL.Path
+	// Server errors carry driver and query details that are logged for
+	// operators rather than shown to clients.
+	if problem.Status >= http.StatusInternalServerError {
+		c.Logger().Error(err)
+		problem.Detail = ""
+		problem.Errors = nil
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(problem.Status)
+	} else {
+		c.Response().Header().Set(echo.HeaderContentType, MIMEProblemJSON)
+		err = c.JSON(problem.Status, problem)
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+func problemFor(err error) *Problem {
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) {
+		httpErr = echo.NewHTTPError(http.StatusInternalServerError)
+	}
+
+	problem := &Problem{Status: httpErr.Code}
+	switch msg := httpErr.Message.(type) {
+	case *Problem:
+		*problem = *msg
+	case ErrorResponse:
+		problem.Code = msg.Code
+		problem.Detail = msg.Message
+	case *auth.PermissionError:
+		problem.Code = "permission_denied"
+		problem.Detail = msg.Error()
+		problem.Permission = string(msg.Permission)
+	case string:
+		if msg != http.StatusText(httpErr.Code) {
+			problem.Detail = msg
+		}
+	case error:
+		problem.Detail = msg.Error()
+	}
+
+	problem.Type = "about:blank"
+	problem.Status = httpErr.Code
+	problem.Title = http.StatusText(httpErr.Code)
+	return problem
+}
@@ -0,0 +1,67 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sedmo/integra-coding-assessment/go-backend/handlers"
+)
+
+var _ = Describe("HTTPErrorHandler", func() {
+	var (
+		e   *echo.Echo
+		rec *httptest.ResponseRecorder
+	)
+
+	handle := func(method string, err error) handlers.Problem {
+		e = echo.New()
+		rec = httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(method, "/users/7", nil), rec)
+		handlers.HTTPErrorHandler(err, c)
+
+		var problem handlers.Problem
+		if rec.Body.Len() > 0 {
+			Expect(json.Unmarshal(rec.Body.Bytes(), &problem)).To(Succeed())
+		}
+		return problem
+	}
+
+	It("should turn a string message into the detail", func() {
+		problem := handle(http.MethodGet, echo.NewHTTPError(http.StatusBadRequest, "invalid user id"))
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Header().Get(echo.HeaderContentType)).To(Equal(handlers.MIMEProblemJSON))
+		Expect(problem.Type).To(Equal("about:blank"))
+		Expect(problem.Title).To(Equal("Bad Request"))
+		Expect(problem.Detail).To(Equal("invalid user id"))
+	})
+
+	It("should not repeat the status text as the detail", func() {
+		problem := handle(http.MethodGet, echo.ErrMethodNotAllowed)
+		Expect(problem.Status).To(Equal(http.StatusMethodNotAllowed))
+		Expect(problem.Detail).To(BeEmpty())
+	})
+
+	It("should hide the details of server errors", func() {
+		problem := handle(http.MethodGet, echo.NewHTTPError(http.StatusInternalServerError, "pq: relation \"users\" does not exist"))
+		Expect(rec.Code).To(Equal(http.StatusInternalServerError))
+		Expect(problem.Title).To(Equal("Internal Server Error"))
+		Expect(problem.Detail).To(BeEmpty())
+	})
+
+	It("should report plain errors as server errors", func() {
+		problem := handle(http.MethodGet, errors.New("boom"))
+		Expect(rec.Code).To(Equal(http.StatusInternalServerError))
+		Expect(problem.Detail).To(BeEmpty())
+	})
+
+	It("should not write a body for HEAD requests", func() {
+		handle(http.MethodHead, echo.NewHTTPError(http.StatusNotFound, "missing"))
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+		Expect(rec.Body.Len()).To(BeZero())
+	})
+})
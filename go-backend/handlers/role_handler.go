@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sedmo/integra-coding-assessment/go-backend/auth"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// RoleHandler serves the role listing and the endpoints that grant and
+// revoke the roles of a user.
+type RoleHandler struct {
+	roles repository.RoleRepository
+}
+
+// NewRoleHandler returns a handler that reads and writes grants through roles.
+func NewRoleHandler(roles repository.RoleRepository) *RoleHandler {
+	return &RoleHandler{roles: roles}
+}
+
+// @Summary Get roles
+// @Description List the roles that can be granted and their permissions
+// @Tags roles
+// @Produce  json
+// @Success 200 {array} models.Role
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /roles [get]
+func (h *RoleHandler) GetRoles(c echo.Context) error {
+	roles, err := h.roles.Roles(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	for i := range roles {
+		for _, p := range auth.PermissionsOf(roles[i].Name) {
+			roles[i].Permissions = append(roles[i].Permissions, string(p))
+		}
+	}
+	return c.JSON(http.StatusOK, roles)
+}
+
+// @Summary Get user roles
+// @Description List the roles granted to a user
+// @Tags roles
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.UserRoles
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /users/{id}/roles [get]
+func (h *RoleHandler) GetUserRoles(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+	return h.respondWithRoles(c, id)
+}
+
+// @Summary Grant role
+// @Description Grant a role to a user, over every user or only over the users
+// @Description of one department. Granting a role the user already holds in
+// @Description that department changes nothing.
+// @Tags roles
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param role path string true "Role name"
+// @Param department query string false "Department the role is confined to"
+// @Success 200 {object} models.UserRoles
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /users/{id}/roles/{role} [put]
+func (h *RoleHandler) GrantRole(c echo.Context) error {
+	return h.changeRole(c, h.roles.GrantRole)
+}
+
+// @Summary Revoke role
+// @Description Revoke a role from a user. Revoking a role the user does not
+// @Description hold in that department changes nothing.
+// @Tags roles
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param role path string true "Role name"
+// @Param department query string false "Department the role is confined to"
+// @Success 200 {object} models.UserRoles
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /users/{id}/roles/{role} [delete]
+func (h *RoleHandler) RevokeRole(c echo.Context) error {
+	return h.changeRole(c, h.roles.RevokeRole)
+}
+
+func (h *RoleHandler) changeRole(c echo.Context, change func(ctx context.Context, userID int64, grant models.Grant) error) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+	grant := models.Grant{Role: c.Param("role"), Department: c.QueryParam("department")}
+
+	err = change(c.Request().Context(), id, grant)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return userNotFound("id", id)
+	case errors.Is(err, repository.ErrUnknownRole):
+		return roleNotFound(grant.Role)
+	case errors.Is(err, repository.ErrInvalidUser):
+		return echo.NewHTTPError(http.StatusBadRequest, "department is too long")
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return h.respondWithRoles(c, id)
+}
+
+func (h *RoleHandler) respondWithRoles(c echo.Context, id int64) error {
+	roles, err := h.roles.UserRoles(c.Request().Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return userNotFound("id", id)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, models.UserRoles{UserID: id, Roles: roles})
+}
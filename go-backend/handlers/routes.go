@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/sedmo/integra-coding-assessment/go-backend/auth"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// RegisterRoutes adds the API routes to g, each guarded by the permission it
+// needs, and records the caller as the actor of the writes they make. g must
+// authorize callers with auth.Authorize. The user routes also serve callers
+// whose grants are confined to departments; granting roles and managing
+// departments need a global grant, so a department head cannot widen their
+// own scope.
+func RegisterRoutes(g *echo.Group, users *UserHandler, roles *RoleHandler, keys *APIKeyHandler, audit *AuditHandler, departments *DepartmentHandler) {
+	g.Use(recordActor)
+
+	g.GET("/users", users.GetUsers, scoped(auth.ReadUsers))
+	g.GET("/users/:id", users.GetUser, scoped(auth.ReadUsers))
+	g.GET("/users/by-username/:user_name", users.GetUserByUsername, scoped(auth.ReadUsers))
+	g.POST("/users", users.CreateUser, scoped(auth.CreateUsers))
+	g.PUT("/users/:id", users.UpdateUser, scoped(auth.UpdateUsers))
+	g.PATCH("/users/:id", users.PatchUser, scoped(auth.UpdateUsers))
+	g.DELETE("/users/:id", users.DeleteUser, scoped(auth.DeleteUsers))
+	g.POST("/users/:id/restore", users.RestoreUser, scoped(auth.DeleteUsers))
+	g.POST("/users/:id/activate", users.ActivateUser, scoped(auth.UpdateUsers))
+	g.POST("/users/:id/deactivate", users.DeactivateUser, scoped(auth.UpdateUsers))
+	g.POST("/users/:id/terminate", users.TerminateUser, scoped(auth.TerminateUsers))
+	g.GET("/users/:id/reports", users.GetUserReports, scoped(auth.ReadUsers))
+	g.GET("/users/:id/managers", users.GetUserManagers, scoped(auth.ReadUsers))
+	g.GET("/users/:id/subordinates", users.GetUserSubordinates, scoped(auth.ReadUsers))
+	g.GET("/org-chart", users.GetOrgChart, scoped(auth.ReadUsers))
+	g.GET("/users/:id/scheduled-changes", users.GetUserScheduledChanges, scoped(auth.ReadUsers))
+	g.POST("/users/:id/scheduled-changes", users.ScheduleChange, scoped(auth.UpdateUsers))
+	g.GET("/scheduled-changes", users.GetScheduledChanges, scoped(auth.ReadUsers))
+	g.GET("/scheduled-changes/:id", users.GetScheduledChange, scoped(auth.ReadUsers))
+	g.DELETE("/scheduled-changes/:id", users.CancelScheduledChange, scoped(auth.UpdateUsers))
+
+	g.GET("/roles", roles.GetRoles, scoped(auth.ReadUsers))
+	g.GET("/users/:id/roles", roles.GetUserRoles, scoped(auth.ReadUsers))
+	g.PUT("/users/:id/roles/:role", roles.GrantRole, auth.Require(auth.ManageRoles))
+	g.DELETE("/users/:id/roles/:role", roles.RevokeRole, auth.Require(auth.ManageRoles))
+
+	g.GET("/departments", departments.GetDepartments, scoped(auth.ReadUsers))
+	g.GET("/departments/:id", departments.GetDepartment, scoped(auth.ReadUsers))
+	g.POST("/departments", departments.CreateDepartment, auth.Require(auth.ManageDepartments))
+	g.PUT("/departments/:id", departments.UpdateDepartment, auth.Require(auth.ManageDepartments))
+	g.DELETE("/departments/:id", departments.DeleteDepartment, auth.Require(auth.ManageDepartments))
+
+	g.GET("/audit", audit.GetAuditLog, auth.Require(auth.ReadAudit))
+	g.GET("/audit/verify", audit.VerifyAudit, auth.Require(auth.ReadAudit))
+	g.GET("/users/:id/history", audit.GetUserHistory, auth.Require(auth.ReadAudit))
+
+	g.POST("/api-keys", keys.CreateAPIKey, auth.Require(auth.ManageAPIKeys))
+	g.GET("/api-keys", keys.GetAPIKeys, auth.Require(auth.ManageAPIKeys))
+	g.DELETE("/api-keys/:id", keys.RevokeAPIKey, auth.Require(auth.ManageAPIKeys))
+}
+
+// recordActor attributes the writes made while serving a request to the
+// caller, as the audit log does.
+func recordActor(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := repository.WithActor(c.Request().Context(), auth.Subject(c))
+		c.SetRequest(c.Request().WithContext(ctx))
+		return next(c)
+	}
+}
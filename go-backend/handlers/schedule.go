@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sedmo/integra-coding-assessment/go-backend/auth"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// @Summary Schedule user change
+// @Description Schedule a change of a user's status, department or both to
+// @Description take effect at a future time. A status change is checked
+// @Description against the status state machine when it takes effect; if the
+// @Description user cannot make it then, the change fails.
+// @Tags scheduled-changes
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param change body models.NewScheduledChange true "Change to schedule"
+// @Param Accept-Language header string false "Language of validation messages (en, fr or es)"
+// @Success 201 {object} models.ScheduledChange
+// @Failure 400 {object} handlers.Problem "Validation failed"
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Failure 409 {object} handlers.Problem "The user is terminated"
+// @Security BearerAuth
+// @Router /users/{id}/scheduled-changes [post]
+func (h *UserHandler) ScheduleChange(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+
+	request := new(models.NewScheduledChange)
+	if err := c.Bind(request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := request.Validate(); err != nil {
+		return validationFailed(c, err)
+	}
+	return h.schedule(c, id, request, http.StatusCreated)
+}
+
+// @Summary Get user scheduled changes
+// @Description List the changes scheduled for a user in the order they take
+// @Description effect
+// @Tags scheduled-changes
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param state query string false "Only changes in this state: pending, applied, cancelled or failed (repeatable)"
+// @Success 200 {array} models.ScheduledChange
+// @Failure 400 {object} handlers.Problem
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /users/{id}/scheduled-changes [get]
+func (h *UserHandler) GetUserScheduledChanges(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+	filter, err := parseScheduleFilter(c.QueryParams())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if _, err := h.users.Get(c.Request().Context(), id); err != nil {
+		return userWriteError(id, err)
+	}
+	filter.UserID = id
+	return h.respondWithChanges(c, filter)
+}
+
+// @Summary Get scheduled changes
+// @Description List the changes scheduled for users in the order they take
+// @Description effect
+// @Tags scheduled-changes
+// @Produce  json
+// @Param user_id query int false "Only changes to this user"
+// @Param state query string false "Only changes in this state: pending, applied, cancelled or failed (repeatable)"
+// @Success 200 {array} models.ScheduledChange
+// @Failure 400 {object} handlers.Problem
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /scheduled-changes [get]
+func (h *UserHandler) GetScheduledChanges(c echo.Context) error {
+	filter, err := parseScheduleFilter(c.QueryParams())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if v := c.QueryParam("user_id"); v != "" {
+		if filter.UserID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid user_id")
+		}
+	}
+	return h.respondWithChanges(c, filter)
+}
+
+// @Summary Get scheduled change
+// @Description Get a scheduled change, whatever its state
+// @Tags scheduled-changes
+// @Produce  json
+// @Param id path int true "Scheduled change ID"
+// @Success 200 {object} models.ScheduledChange
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Security BearerAuth
+// @Router /scheduled-changes/{id} [get]
+func (h *UserHandler) GetScheduledChange(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid scheduled change id")
+	}
+	change, err := h.users.ScheduledChange(c.Request().Context(), id)
+	if err != nil {
+		return scheduledChangeError(id, err)
+	}
+	return c.JSON(http.StatusOK, change)
+}
+
+// @Summary Cancel scheduled change
+// @Description Cancel a pending scheduled change, which is kept as cancelled
+// @Tags scheduled-changes
+// @Produce  json
+// @Param id path int true "Scheduled change ID"
+// @Success 200 {object} models.ScheduledChange
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Failure 409 {object} handlers.Problem "The change is no longer pending"
+// @Security BearerAuth
+// @Router /scheduled-changes/{id} [delete]
+func (h *UserHandler) CancelScheduledChange(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid scheduled change id")
+	}
+
+	ctx := c.Request().Context()
+	change, err := h.users.ScheduledChange(ctx, id)
+	if err != nil {
+		return scheduledChangeError(id, err)
+	}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	change.State = models.ChangeCancelled
+	change.ResolvedBy = auth.Subject(c)
+	change.ResolvedAt = &now
+	if err := h.users.ResolveScheduledChange(ctx, change); err != nil {
+		return scheduledChangeError(id, err)
+	}
+	return c.JSON(http.StatusOK, change)
+}
+
+// schedule schedules request for user id on behalf of the caller and
+// responds with the scheduled change and status. Changing the department
+// needs the caller's grant to cover the new one, and terminating the user
+// needs the users:terminate permission, as when the change is made now.
+func (h *UserHandler) schedule(c echo.Context, id int64, request *models.NewScheduledChange, status int) error {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	if !request.EffectiveAt.After(now) {
+		return echo.NewHTTPError(http.StatusBadRequest, "effective_at must be in the future")
+	}
+
+	ctx := c.Request().Context()
+	user, err := h.users.Get(ctx, id)
+	if err != nil {
+		return userWriteError(id, err)
+	}
+	target := *user
+	if request.UserStatus != "" {
+		// Terminated is final, so the change could only fail.
+		if user.UserStatus == models.StatusTerminated {
+			return illegalTransition(&models.TransitionError{From: user.UserStatus, To: request.UserStatus})
+		}
+		target.UserStatus = request.UserStatus
+	}
+	if request.Department != "" {
+		departments, global := auth.Scope(c, auth.UpdateUsers)
+		if !global && !slices.Contains(departments, request.Department) {
+			return departmentOutOfScope()
+		}
+		// The change would only fail if the department did not exist by then.
+		if _, err := h.users.DepartmentByName(ctx, request.Department); err != nil {
+			if errors.Is(err, repository.ErrDepartmentNotFound) {
+				return userWriteError(id, repository.ErrUnknownDepartment)
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		target.Department = request.Department
+	}
+	if err := h.checkTerminate(c, user, &target); err != nil {
+		return err
+	}
+
+	change := models.ScheduledChange{
+		UserID:      id,
+		UserStatus:  request.UserStatus,
+		Department:  request.Department,
+		Reason:      request.Reason,
+		EffectiveAt: request.EffectiveAt.UTC().Truncate(time.Microsecond),
+		State:       models.ChangePending,
+		CreatedBy:   auth.Subject(c),
+		CreatedAt:   now,
+	}
+	if err := h.users.ScheduleChange(ctx, &change); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(status, change)
+}
+
+func (h *UserHandler) respondWithChanges(c echo.Context, filter *repository.ScheduleFilter) error {
+	changes, err := h.users.ScheduledChanges(c.Request().Context(), *filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, changes)
+}
+
+// parseScheduleFilter reads the filters shared by the scheduled change
+// listings.
+func parseScheduleFilter(q url.Values) (*repository.ScheduleFilter, error) {
+	filter := &repository.ScheduleFilter{}
+	for _, state := range q["state"] {
+		switch state {
+		case models.ChangePending, models.ChangeApplied, models.ChangeCancelled, models.ChangeFailed:
+			filter.States = append(filter.States, state)
+		default:
+			return nil, fmt.Errorf("unknown state %q", state)
+		}
+	}
+	return filter, nil
+}
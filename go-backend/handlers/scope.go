@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sedmo/integra-coding-assessment/go-backend/auth"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// scoped guards a route with permission. Callers that hold it only in some
+// departments are let through with the repository confined to those
+// departments, so the handler neither sees nor writes users outside them.
+func scoped(permission auth.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			departments, global := auth.Scope(c, permission)
+			if global {
+				return next(c)
+			}
+			if len(departments) == 0 {
+				return auth.Forbidden(permission)
+			}
+			ctx := repository.WithDepartments(c.Request().Context(), departments)
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// includeDeleted returns the context of the request, which includes soft
+// deleted users when the include_deleted query parameter is set. Seeing them
+// needs the users:delete permission, and callers holding it only in some
+// departments only see the deleted users of those departments; the users
+// that are not deleted stay confined to the departments the caller may read.
+func includeDeleted(c echo.Context) (context.Context, error) {
+	ctx := c.Request().Context()
+	v := c.QueryParam("include_deleted")
+	if v == "" {
+		return ctx, nil
+	}
+	include, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "include_deleted must be true or false")
+	}
+	if !include {
+		return ctx, nil
+	}
+
+	departments, global := auth.Scope(c, auth.DeleteUsers)
+	if global {
+		return repository.WithDeleted(ctx), nil
+	}
+	if len(departments) == 0 {
+		return nil, auth.Forbidden(auth.DeleteUsers)
+	}
+	return repository.WithDeletedIn(ctx, departments), nil
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// @Summary Activate user
+// @Description Move an inactive user to active, recording the reason and the
+// @Description day the change took effect. A future day schedules the change.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param If-Match header string false "ETag the change is based on"
+// @Param change body models.StatusChange false "Reason and effective date"
+// @Success 200 {object} models.User
+// @Success 202 {object} models.ScheduledChange "The change takes effect on a later day and was scheduled"
+// @Failure 400 {object} handlers.Problem "Validation failed"
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Failure 409 {object} handlers.Problem "The user cannot move to this status"
+// @Failure 412 {object} handlers.Problem
+// @Header 200 {string} ETag "Current version of the user"
+// @Security BearerAuth
+// @Router /users/{id}/activate [post]
+func (h *UserHandler) ActivateUser(c echo.Context) error {
+	return h.changeStatus(c, models.StatusActive)
+}
+
+// @Summary Deactivate user
+// @Description Move an active user to inactive, recording the reason and the
+// @Description day the change took effect. A future day schedules the change.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param If-Match header string false "ETag the change is based on"
+// @Param change body models.StatusChange false "Reason and effective date"
+// @Success 200 {object} models.User
+// @Success 202 {object} models.ScheduledChange "The change takes effect on a later day and was scheduled"
+// @Failure 400 {object} handlers.Problem "Validation failed"
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Failure 409 {object} handlers.Problem "The user cannot move to this status"
+// @Failure 412 {object} handlers.Problem
+// @Header 200 {string} ETag "Current version of the user"
+// @Security BearerAuth
+// @Router /users/{id}/deactivate [post]
+func (h *UserHandler) DeactivateUser(c echo.Context) error {
+	return h.changeStatus(c, models.StatusInactive)
+}
+
+// @Summary Terminate user
+// @Description Terminate an active or inactive user, recording the reason and
+// @Description the day the change took effect. Termination is final. A future day
+// @Description schedules the change.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param If-Match header string false "ETag the change is based on"
+// @Param change body models.StatusChange false "Reason and effective date"
+// @Success 200 {object} models.User
+// @Success 202 {object} models.ScheduledChange "The change takes effect on a later day and was scheduled"
+// @Failure 400 {object} handlers.Problem "Validation failed"
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Failure 409 {object} handlers.Problem "The user is already terminated"
+// @Failure 412 {object} handlers.Problem
+// @Header 200 {string} ETag "Current version of the user"
+// @Security BearerAuth
+// @Router /users/{id}/terminate [post]
+func (h *UserHandler) TerminateUser(c echo.Context) error {
+	return h.changeStatus(c, models.StatusTerminated)
+}
+
+// changeStatus moves user id to status, as allowed by the status state
+// machine, or schedules the move when it takes effect on a later day.
+func (h *UserHandler) changeStatus(c echo.Context, status string) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+
+	change := new(models.StatusChange)
+	if err := c.Bind(change); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := change.Validate(); err != nil {
+		return validationFailed(c, err)
+	}
+	if change.EffectiveDate == "" {
+		change.EffectiveDate = today()
+	}
+	if change.EffectiveDate > today() {
+		effectiveAt, _ := time.Parse(models.DateLayout, change.EffectiveDate)
+		return h.schedule(c, id, &models.NewScheduledChange{UserStatus: status, Reason: change.Reason, EffectiveAt: effectiveAt}, http.StatusAccepted)
+	}
+
+	ifMatch, err := ifMatchVersions(c, id)
+	if err != nil {
+		return err
+	}
+
+	var user models.User
+	err = h.audited(c, models.AuditUpdate, func(tx repository.UserRepository) (*models.User, *models.User, error) {
+		before, err := tx.Get(c.Request().Context(), id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := models.CheckTransition(before.UserStatus, status); err != nil {
+			return nil, nil, err
+		}
+		user = *before
+		user.UserStatus = status
+		user.StatusReason = change.Reason
+		user.StatusEffectiveDate = change.EffectiveDate
+		return before, &user, tx.Update(c.Request().Context(), &user, repository.StatusColumns, ifMatch)
+	})
+	if err != nil {
+		return userWriteError(id, err)
+	}
+
+	return respondWithUser(c, http.StatusOK, &user)
+}
+
+// statusColumns carries the fields of before that clients cannot write over
+// to user, which was decoded from a request, and checks a change of
+// user_status between them against the status state machine. A status
+// changed by editing the user has no reason and takes effect today; the
+// columns recording that are returned.
+func statusColumns(before, user *models.User) ([]string, error) {
+	keepReadOnlyFields(before, user)
+	if user.UserStatus == before.UserStatus {
+		return nil, nil
+	}
+	if err := models.CheckTransition(before.UserStatus, user.UserStatus); err != nil {
+		return nil, err
+	}
+	user.StatusReason = ""
+	user.StatusEffectiveDate = today()
+	return []string{"status_reason", "status_effective_date"}, nil
+}
+
+// keepReadOnlyFields copies the fields of stored that clients cannot write
+// over to user.
+func keepReadOnlyFields(stored, user *models.User) {
+	user.DeletedAt = stored.DeletedAt
+	user.CreatedAt, user.CreatedBy = stored.CreatedAt, stored.CreatedBy
+	user.UpdatedAt, user.UpdatedBy = stored.UpdatedAt, stored.UpdatedBy
+	user.StatusReason = stored.StatusReason
+	user.StatusEffectiveDate = stored.StatusEffectiveDate
+}
+
+// withColumns returns columns, or every writable column when it is empty,
+// followed by extra.
+func withColumns(columns []string, extra ...string) []string {
+	if len(extra) == 0 {
+		return columns
+	}
+	if len(columns) == 0 {
+		columns = repository.WritableColumns
+	}
+	return append(slices.Clip(columns), extra...)
+}
+
+// today is the current date in UTC.
+func today() string {
+	return time.Now().UTC().Format(models.DateLayout)
+}
@@ -1,45 +1,198 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
+	"io"
+	"mime"
 	"net/http"
+	"slices"
+	"strconv"
 
-	"github.com/Masterminds/squirrel"
-	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
-	"github.com/sedmo/integra-coding-assessment/go-backend/db"
+	"github.com/sedmo/integra-coding-assessment/go-backend/auth"
 	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
 )
 
+// UserHandler serves the /users endpoints from a UserRepository.
+type UserHandler struct {
+	users repository.UserRepository
+}
+
+// NewUserHandler returns a handler that reads and writes users through users.
+func NewUserHandler(users repository.UserRepository) *UserHandler {
+	return &UserHandler{users: users}
+}
+
 // @Summary Get users
-// @Description Get all users
+// @Description List users one page at a time. Results can be filtered by exact
+// @Description value or by prefix, sorted on several columns and paged either by
+// @Description limit/offset or by the opaque cursors returned in the response.
 // @Tags users
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} models.User
+// @Param limit query int false "Page size (1-500)" default(50)
+// @Param offset query int false "Number of rows to skip"
+// @Param cursor query string false "Opaque cursor from next_cursor or prev_cursor"
+// @Param sort query string false "Comma separated columns, prefix with - for descending (e.g. department,-user_name)"
+// @Param user_status query string false "Exact user status"
+// @Param department query string false "Exact department"
+// @Param department_prefix query string false "Department prefix"
+// @Param user_name query string false "Exact user name"
+// @Param user_name_prefix query string false "User name prefix"
+// @Param email query string false "Exact email"
+// @Param email_prefix query string false "Email prefix"
+// @Param created_by query string false "Exact creator"
+// @Param updated_by query string false "Exact last writer"
+// @Param manager_id query int false "Only direct reports of this user"
+// @Param created_from query string false "Only users created at or after this RFC 3339 time"
+// @Param created_to query string false "Only users created before this RFC 3339 time"
+// @Param updated_from query string false "Only users last written at or after this RFC 3339 time"
+// @Param updated_to query string false "Only users last written before this RFC 3339 time"
+// @Param include_deleted query bool false "Also list soft deleted users (needs users:delete)"
+// @Success 200 {object} models.UserPage
+// @Failure 400 {object} handlers.Problem
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Security BearerAuth
 // @Router /users [get]
-func GetUsers(c echo.Context) error {
-	query := db.Psql.Select("*").From("users")
-	sqlQuery, args, err := query.ToSql()
+func (h *UserHandler) GetUsers(c echo.Context) error {
+	params, err := parseListParams(c.QueryParams())
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	ctx, err := includeDeleted(c)
+	if err != nil {
+		return err
 	}
 
-	rows, err := db.DB.Query(sqlQuery, args...)
+	// Fetch one extra row to learn whether another page follows.
+	opts := params.ListOptions
+	opts.Limit++
+	users, total, err := h.users.List(ctx, opts)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
-	defer rows.Close()
 
-	var users []models.User
-	for rows.Next() {
-		var user models.User
-		if err := rows.Scan(&user.UserID, &user.UserName, &user.FirstName, &user.LastName, &user.Email, &user.UserStatus, &user.Department); err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	hasMore := uint64(len(users)) > params.Limit
+	if hasMore {
+		if params.Cursor != nil && params.Cursor.Before {
+			users = users[1:]
+		} else {
+			users = users[:params.Limit]
 		}
-		users = append(users, user)
 	}
-	return c.JSON(http.StatusOK, users)
+
+	return c.JSON(http.StatusOK, buildUserPage(c, params, users, total, hasMore))
+}
+
+func buildUserPage(c echo.Context, params *listParams, users []models.User, total int64, hasMore bool) models.UserPage {
+	u := c.Request().URL
+	page := models.UserPage{
+		Data:   users,
+		Total:  total,
+		Limit:  params.Limit,
+		Offset: params.Offset,
+		Links:  models.PageLinks{Self: u.RequestURI()},
+	}
+	if len(users) == 0 {
+		return page
+	}
+
+	first, last := users[0], users[len(users)-1]
+	if params.Cursor == nil {
+		if hasMore {
+			page.NextCursor = params.cursorFor(last, false)
+			page.Links.Next = pageURL(u, map[string]string{"offset": strconv.FormatUint(params.Offset+params.Limit, 10)})
+		}
+		if params.Offset > 0 {
+			page.PrevCursor = params.cursorFor(first, true)
+			prev := uint64(0)
+			if params.Offset > params.Limit {
+				prev = params.Offset - params.Limit
+			}
+			page.Links.Prev = pageURL(u, map[string]string{"offset": strconv.FormatUint(prev, 10)})
+		}
+		return page
+	}
+
+	// Paging backwards always leaves a page after this one, and paging
+	// forwards from a cursor always leaves one before it.
+	if hasMore || params.Cursor.Before {
+		page.NextCursor = params.cursorFor(last, false)
+		page.Links.Next = pageURL(u, map[string]string{"cursor": page.NextCursor})
+	}
+	if hasMore || !params.Cursor.Before {
+		page.PrevCursor = params.cursorFor(first, true)
+		page.Links.Prev = pageURL(u, map[string]string{"cursor": page.PrevCursor})
+	}
+	return page
+}
+
+// @Summary Get user
+// @Description Get a single user by ID
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param include_deleted query bool false "Also find a soft deleted user (needs users:delete)"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Success 200 {object} models.User
+// @Success 304 "Not Modified"
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Header 200 {string} ETag "Current version of the user"
+// @Security BearerAuth
+// @Router /users/{id} [get]
+func (h *UserHandler) GetUser(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+
+	ctx, err := includeDeleted(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.users.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return userNotFound("id", id)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return respondWithUser(c, http.StatusOK, user)
+}
+
+// @Summary Get user by username
+// @Description Get a single user by user name
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param user_name path string true "User name"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Success 200 {object} models.User
+// @Success 304 "Not Modified"
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Header 200 {string} ETag "Current version of the user"
+// @Security BearerAuth
+// @Router /users/by-username/{user_name} [get]
+func (h *UserHandler) GetUserByUsername(c echo.Context) error {
+	username := c.Param("user_name")
+
+	user, err := h.users.GetByUsername(c.Request().Context(), username)
+	if errors.Is(err, repository.ErrNotFound) {
+		return userNotFound("user_name", username)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return respondWithUser(c, http.StatusOK, user)
 }
 
 // @Summary Create user
@@ -48,138 +201,323 @@ func GetUsers(c echo.Context) error {
 // @Accept  json
 // @Produce  json
 // @Param user body models.User true "New User"
+// @Param Accept-Language header string false "Language of validation messages (en, fr or es)"
 // @Success 201 {object} models.User
+// @Failure 400 {object} handlers.Problem "Validation failed"
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 409 {object} handlers.Problem
+// @Header 201 {string} ETag "Current version of the user"
+// @Security BearerAuth
 // @Router /users [post]
-func CreateUser(c echo.Context) error {
+func (h *UserHandler) CreateUser(c echo.Context) error {
 	user := new(models.User)
 	if err := c.Bind(user); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
 	if err := user.Validate(); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		return echo.NewHTTPError(http.StatusBadRequest, validationErrors.Error())
-	}
-
-	// Check if username already exists
-	existingUser, err := getUserByUsername(user.UserName)
-	if err != nil && err != sql.ErrNoRows {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-	}
-	if existingUser != nil {
-		return echo.NewHTTPError(http.StatusConflict, "username already exists")
-	}
-
-	query := db.Psql.Insert("users").
-		Columns("user_name", "first_name", "last_name", "email", "user_status", "department").
-		Values(user.UserName, user.FirstName, user.LastName, user.Email, user.UserStatus, user.Department).
-		Suffix("RETURNING user_id")
-
-	sqlQuery, args, err := query.ToSql()
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return validationFailed(c, err)
 	}
 
-	err = db.DB.QueryRow(sqlQuery, args...).Scan(&user.UserID)
+	err := h.audited(c, models.AuditCreate, func(tx repository.UserRepository) (*models.User, *models.User, error) {
+		return nil, user, tx.Create(c.Request().Context(), user)
+	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return userWriteError(user.UserID, err)
 	}
 
-	return c.JSON(http.StatusCreated, user)
+	return respondWithUser(c, http.StatusCreated, user)
 }
 
 // @Summary Update user
-// @Description Update an existing user
+// @Description Update an existing user. The user_id in the body may be omitted;
+// @Description if present it must match the id in the path. Send the ETag of the
+// @Description user in If-Match to avoid overwriting someone else's changes.
+// @Description Terminating a user (user_status T) needs the users:terminate permission.
+// @Description Changes of user_status must follow the status state machine: active
+// @Description and inactive users can move to each other or to terminated, and
+// @Description terminated users cannot be brought back.
 // @Tags users
 // @Accept  json
 // @Produce  json
 // @Param id path int true "User ID"
+// @Param If-Match header string false "ETag the update is based on"
 // @Param user body models.User true "Updated User"
+// @Param Accept-Language header string false "Language of validation messages (en, fr or es)"
 // @Success 200 {object} models.User
+// @Failure 400 {object} handlers.Problem "Validation failed"
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Failure 409 {object} handlers.Problem
+// @Failure 412 {object} handlers.Problem
+// @Header 200 {string} ETag "Current version of the user"
+// @Security BearerAuth
 // @Router /users/{id} [put]
-func UpdateUser(c echo.Context) error {
-	id := c.Param("id")
+func (h *UserHandler) UpdateUser(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+
 	user := new(models.User)
 	if err := c.Bind(user); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
+	if user.UserID != 0 && user.UserID != id {
+		return echo.NewHTTPError(http.StatusBadRequest, "user_id in body does not match the id in the path")
+	}
+	user.UserID = id
 
 	if err := user.Validate(); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		return echo.NewHTTPError(http.StatusBadRequest, validationErrors.Error())
+		return validationFailed(c, err)
+	}
+	if err := h.checkTerminate(c, nil, user); err != nil {
+		return err
 	}
 
-	// Check if username already exists for a different user
-	existingUser, err := getUserByUsername(user.UserName)
+	ifMatch, err := ifMatchVersions(c, id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
 	}
-	if existingUser != nil && existingUser.UserID != user.UserID {
-		return echo.NewHTTPError(http.StatusConflict, "username already exists")
+
+	err = h.audited(c, models.AuditUpdate, func(tx repository.UserRepository) (*models.User, *models.User, error) {
+		before, err := tx.Get(c.Request().Context(), id)
+		if err != nil {
+			return nil, nil, err
+		}
+		extra, err := statusColumns(before, user)
+		if err != nil {
+			return nil, nil, err
+		}
+		return before, user, tx.Update(c.Request().Context(), user, withColumns(nil, extra...), ifMatch)
+	})
+	if err != nil {
+		return userWriteError(id, err)
 	}
 
-	query := db.Psql.Update("users").
-		Set("user_name", user.UserName).
-		Set("first_name", user.FirstName).
-		Set("last_name", user.LastName).
-		Set("email", user.Email).
-		Set("user_status", user.UserStatus).
-		Set("department", user.Department).
-		Where(squirrel.Eq{"user_id": id})
+	return respondWithUser(c, http.StatusOK, user)
+}
+
+// @Summary Patch user
+// @Description Partially update a user with a JSON Merge Patch (RFC 7396) or a
+// @Description JSON Patch (RFC 6902). Only the columns that change are written.
+// @Description Terminating a user (user_status T) needs the users:terminate permission.
+// @Description Changes of user_status must follow the status state machine.
+// @Tags users
+// @Accept  application/merge-patch+json
+// @Accept  application/json-patch+json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param If-Match header string false "ETag the patch is based on"
+// @Param patch body object true "Merge patch object or JSON patch operations"
+// @Param Accept-Language header string false "Language of validation messages (en, fr or es)"
+// @Success 200 {object} models.User
+// @Failure 400 {object} handlers.Problem "Validation failed"
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Failure 409 {object} handlers.Problem
+// @Failure 412 {object} handlers.Problem
+// @Header 200 {string} ETag "Current version of the user"
+// @Security BearerAuth
+// @Router /users/{id} [patch]
+func (h *UserHandler) PatchUser(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
 
-	sqlQuery, args, err := query.ToSql()
+	contentType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	body, err := io.ReadAll(c.Request().Body)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	_, err = db.DB.Exec(sqlQuery, args...)
+	existing, err := h.users.Get(c.Request().Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return userNotFound("id", id)
+	}
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
+	if err := userIfMatch(c, existing); err != nil {
+		return err
+	}
+
+	user, err := applyUserPatch(existing, contentType, body)
+	if err != nil {
+		return err
+	}
+
+	if err := user.Validate(); err != nil {
+		return validationFailed(c, err)
+	}
+
+	if err := h.checkTerminate(c, existing, user); err != nil {
+		return err
+	}
+
+	user.Version = existing.Version
+	keepReadOnlyFields(existing, user)
+	changed := changedUserColumns(existing, user)
+	if len(changed) == 0 {
+		return respondWithUser(c, http.StatusOK, user)
+	}
+
+	// The user may have changed since it was read above; only apply the
+	// patch to the version the client asked for.
+	var pre *repository.Precondition
+	if c.Request().Header.Get(headerIfMatch) != "" {
+		pre = &repository.Precondition{Versions: []int64{existing.Version}}
+	}
+
+	err = h.audited(c, models.AuditUpdate, func(tx repository.UserRepository) (*models.User, *models.User, error) {
+		before, err := tx.Get(c.Request().Context(), id)
+		if err != nil {
+			return nil, nil, err
+		}
+		extra, err := statusColumns(before, user)
+		if err != nil {
+			return nil, nil, err
+		}
+		return before, user, tx.Update(c.Request().Context(), user, withColumns(changed, extra...), pre)
+	})
+	if err != nil {
+		return userWriteError(id, err)
+	}
 
-	return c.JSON(http.StatusOK, user)
+	return respondWithUser(c, http.StatusOK, user)
 }
 
 // @Summary Delete user
-// @Description Delete a user
+// @Description Soft delete a user. The user is hidden from every other endpoint
+// @Description and its user name can be reused, but it can be restored until it
+// @Description is purged.
 // @Tags users
 // @Accept  json
 // @Produce  json
 // @Param id path int true "User ID"
+// @Param If-Match header string false "ETag the deletion is based on"
 // @Success 200 {string} string "User deleted"
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Failure 412 {object} handlers.Problem
+// @Security BearerAuth
 // @Router /users/{id} [delete]
-func DeleteUser(c echo.Context) error {
-	id := c.Param("id")
-
-	query := db.Psql.Delete("users").Where(squirrel.Eq{"user_id": id})
+func (h *UserHandler) DeleteUser(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
 
-	sqlQuery, args, err := query.ToSql()
+	ifMatch, err := ifMatchVersions(c, id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
 	}
 
-	_, err = db.DB.Exec(sqlQuery, args...)
+	err = h.audited(c, models.AuditDelete, func(tx repository.UserRepository) (*models.User, *models.User, error) {
+		before, err := tx.Get(c.Request().Context(), id)
+		if err != nil {
+			return nil, nil, err
+		}
+		return before, nil, tx.Delete(c.Request().Context(), id, ifMatch)
+	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return userWriteError(id, err)
 	}
 
 	return c.JSON(http.StatusOK, "User deleted")
 }
 
-func getUserByUsername(username string) (*models.User, error) {
-	query := db.Psql.Select("*").From("users").Where(squirrel.Eq{"user_name": username})
-	sqlQuery, args, err := query.ToSql()
+// @Summary Restore user
+// @Description Restore a soft deleted user
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param If-Match header string false "ETag the restore is based on"
+// @Success 200 {object} models.User
+// @Failure 401 {object} handlers.Problem
+// @Failure 403 {object} handlers.Problem
+// @Failure 404 {object} handlers.Problem
+// @Failure 409 {object} handlers.Problem "The user is not deleted or its user name has been reused"
+// @Failure 412 {object} handlers.Problem
+// @Header 200 {string} ETag "Current version of the user"
+// @Security BearerAuth
+// @Router /users/{id}/restore [post]
+func (h *UserHandler) RestoreUser(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+
+	ifMatch, err := ifMatchVersions(c, id)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var user models.User
-	err = db.DB.QueryRow(sqlQuery, args...).Scan(&user.UserID, &user.UserName, &user.FirstName, &user.LastName, &user.Email, &user.UserStatus, &user.Department)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	} else if err != nil {
-		return nil, err
+	var user *models.User
+	err = h.audited(c, models.AuditRestore, func(tx repository.UserRepository) (*models.User, *models.User, error) {
+		var err error
+		user, err = tx.Restore(c.Request().Context(), id, ifMatch)
+		return nil, user, err
+	})
+	if err != nil {
+		return userWriteError(id, err)
+	}
+
+	return respondWithUser(c, http.StatusOK, user)
+}
+
+// audited runs write in a transaction that also records its audit entry.
+// write returns the user before and after the change, nil for a user that
+// did not or no longer exists.
+func (h *UserHandler) audited(c echo.Context, operation string, write func(tx repository.UserRepository) (before, after *models.User, err error)) error {
+	ctx := c.Request().Context()
+	return h.users.WithTx(ctx, func(tx repository.UserRepository) error {
+		before, after, err := write(tx)
+		if err != nil {
+			return err
+		}
+		return tx.RecordAudit(ctx, auditEntry(c, operation, before, after))
+	})
+}
+
+func respondWithUser(c echo.Context, status int, user *models.User) error {
+	setUserETag(c, user)
+	if status == http.StatusOK && c.Request().Method == http.MethodGet && notModified(c, user) {
+		return c.NoContent(http.StatusNotModified)
 	}
+	return c.JSON(status, user)
+}
 
-	return &user, nil
+// checkTerminate requires the users:terminate permission, over both the
+// stored and the updated department, to change the status of a user to
+// terminated. existing is the stored user, or nil to look it up.
+func (h *UserHandler) checkTerminate(c echo.Context, existing, user *models.User) error {
+	if user.UserStatus != "T" {
+		return nil
+	}
+	departments, global := auth.Scope(c, auth.TerminateUsers)
+	if global {
+		return nil
+	}
+	if existing == nil {
+		var err error
+		existing, err = h.users.Get(c.Request().Context(), user.UserID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return userNotFound("id", user.UserID)
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+	if existing.UserStatus != "T" &&
+		(!slices.Contains(departments, existing.Department) || !slices.Contains(departments, user.Department)) {
+		return auth.Forbidden(auth.TerminateUsers)
+	}
+	return nil
 }
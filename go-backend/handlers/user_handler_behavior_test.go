@@ -0,0 +1,776 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sedmo/integra-coding-assessment/go-backend/auth"
+	"github.com/sedmo/integra-coding-assessment/go-backend/db/connectors"
+	"github.com/sedmo/integra-coding-assessment/go-backend/handlers"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// userHandlerBehavior drives the handlers through a real router backed by the
+// repository returned by open, so it checks behavior rather than SQL text.
+func userHandlerBehavior(open func() repository.UserRepository) {
+	var (
+		e    *echo.Echo
+		repo repository.UserRepository
+		// callerGrants are the grants of whoever sends the requests.
+		callerGrants []models.Grant
+	)
+
+	do := func(method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, bytes.NewReader([]byte(body)))
+		if body != "" {
+			request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		}
+		for k, v := range headers {
+			request.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, request)
+		return rec
+	}
+
+	create := func(name, department string) models.User {
+		body, _ := json.Marshal(models.User{
+			UserName:   name,
+			FirstName:  "First",
+			LastName:   "Last",
+			Email:      name + "@example.com",
+			UserStatus: "A",
+			Department: department,
+		})
+		rec := do(http.MethodPost, "/users", string(body), nil)
+		Expect(rec.Code).To(Equal(http.StatusCreated))
+		var user models.User
+		Expect(json.Unmarshal(rec.Body.Bytes(), &user)).To(Succeed())
+		return user
+	}
+
+	BeforeEach(func() {
+		repo = open()
+		for _, name := range []string{"Engineering", "HR", "Marketing", "Sales"} {
+			Expect(repo.CreateDepartment(context.Background(), &models.Department{Code: name, Name: name})).To(Succeed())
+		}
+		callerGrants = []models.Grant{{Role: auth.Admin}}
+		e = echo.New()
+		e.HTTPErrorHandler = handlers.HTTPErrorHandler
+		api := e.Group("", auth.Authorize(func(context.Context, string) ([]models.Grant, error) {
+			return callerGrants, nil
+		}))
+		handlers.RegisterRoutes(api, handlers.NewUserHandler(repo), handlers.NewRoleHandler(repo), handlers.NewAPIKeyHandler(repo), handlers.NewAuditHandler(repo), handlers.NewDepartmentHandler(repo))
+	})
+
+	It("should create, read, update and delete a user", func() {
+		user := create("jdoe", "Engineering")
+		path := "/users/" + strconv.FormatInt(user.UserID, 10)
+
+		rec := do(http.MethodGet, "/users/by-username/jdoe", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		etag := rec.Header().Get("ETag")
+
+		rec = do(http.MethodPatch, path, `{"department":"Sales"}`, map[string]string{
+			echo.HeaderContentType: handlers.MIMEMergePatch,
+			"If-Match":             etag,
+		})
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("ETag")).NotTo(Equal(etag))
+
+		// The original ETag is now stale.
+		rec = do(http.MethodDelete, path, "", map[string]string{"If-Match": etag})
+		Expect(rec.Code).To(Equal(http.StatusPreconditionFailed))
+
+		rec = do(http.MethodDelete, path, "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		rec = do(http.MethodGet, path, "", nil)
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should soft delete and restore a user", func() {
+		user := create("jdoe", "Engineering")
+		path := "/users/" + strconv.FormatInt(user.UserID, 10)
+		Expect(do(http.MethodDelete, path, "", nil).Code).To(Equal(http.StatusOK))
+
+		list := func(target string) models.UserPage {
+			rec := do(http.MethodGet, target, "", nil)
+			Expect(rec.Code).To(Equal(http.StatusOK), rec.Body.String())
+			var page models.UserPage
+			Expect(json.Unmarshal(rec.Body.Bytes(), &page)).To(Succeed())
+			return page
+		}
+		Expect(list("/users").Total).To(BeZero())
+		page := list("/users?include_deleted=true")
+		Expect(page.Total).To(Equal(int64(1)))
+		Expect(page.Data[0].DeletedAt).NotTo(BeNil())
+		Expect(do(http.MethodGet, path, "", nil).Code).To(Equal(http.StatusNotFound))
+		rec := do(http.MethodGet, path+"?include_deleted=true", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring("deleted_at"))
+		Expect(do(http.MethodGet, "/users?include_deleted=maybe", "", nil).Code).To(Equal(http.StatusBadRequest))
+
+		// The user name is free while its owner is deleted.
+		other := create("jdoe", "Sales")
+		rec = do(http.MethodPost, path+"/restore", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusConflict))
+		Expect(rec.Body.String()).To(ContainSubstring("user_name_taken"))
+		Expect(do(http.MethodDelete, "/users/"+strconv.FormatInt(other.UserID, 10), "", nil).Code).To(Equal(http.StatusOK))
+
+		callerGrants = []models.Grant{{Role: auth.Editor}}
+		Expect(do(http.MethodGet, "/users?include_deleted=true", "", nil).Code).To(Equal(http.StatusForbidden))
+		Expect(do(http.MethodPost, path+"/restore", "", nil).Code).To(Equal(http.StatusForbidden))
+
+		callerGrants = []models.Grant{{Role: auth.Admin}}
+		rec = do(http.MethodPost, path+"/restore", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).NotTo(ContainSubstring("deleted_at"))
+		Expect(rec.Header().Get("ETag")).NotTo(BeEmpty())
+		Expect(do(http.MethodGet, path, "", nil).Code).To(Equal(http.StatusOK))
+
+		rec = do(http.MethodPost, path+"/restore", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusConflict))
+		Expect(rec.Body.String()).To(ContainSubstring("user_not_deleted"))
+		Expect(do(http.MethodPost, "/users/42/restore", "", nil).Code).To(Equal(http.StatusNotFound))
+
+		rec = do(http.MethodGet, path+"/history?operation=restore", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var history models.AuditPage
+		Expect(json.Unmarshal(rec.Body.Bytes(), &history)).To(Succeed())
+		Expect(history.Total).To(Equal(int64(1)))
+		Expect(history.Data[0].Changes).To(HaveKeyWithValue("user_name", models.FieldChange{New: "jdoe"}))
+	})
+
+	It("should move users through the status state machine", func() {
+		user := create("jdoe", "Engineering")
+		path := "/users/" + strconv.FormatInt(user.UserID, 10)
+		status := func(rec *httptest.ResponseRecorder) models.User {
+			Expect(rec.Code).To(Equal(http.StatusOK), rec.Body.String())
+			var user models.User
+			Expect(json.Unmarshal(rec.Body.Bytes(), &user)).To(Succeed())
+			return user
+		}
+		conflict := func(rec *httptest.ResponseRecorder, detail string) {
+			Expect(rec.Code).To(Equal(http.StatusConflict))
+			var problem handlers.Problem
+			Expect(json.Unmarshal(rec.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem.Code).To(Equal("illegal_status_transition"))
+			Expect(problem.Detail).To(Equal(detail))
+		}
+
+		updated := status(do(http.MethodPost, path+"/deactivate", `{"reason":"Parental leave","effective_date":"2024-06-01"}`, nil))
+		Expect(updated.UserStatus).To(Equal(models.StatusInactive))
+		Expect(updated.StatusReason).To(Equal("Parental leave"))
+		Expect(updated.StatusEffectiveDate).To(Equal("2024-06-01"))
+		conflict(do(http.MethodPost, path+"/deactivate", "", nil), "user is already inactive")
+
+		updated = status(do(http.MethodPost, path+"/activate", "", nil))
+		Expect(updated.UserStatus).To(Equal(models.StatusActive))
+		Expect(updated.StatusReason).To(BeEmpty())
+		Expect(updated.StatusEffectiveDate).To(Equal(time.Now().UTC().Format(models.DateLayout)))
+
+		Expect(do(http.MethodPost, path+"/terminate", `{"effective_date":"30/06/2024"}`, nil).Code).To(Equal(http.StatusBadRequest))
+		callerGrants = []models.Grant{{Role: auth.Editor}}
+		Expect(do(http.MethodPost, path+"/deactivate", "", nil).Code).To(Equal(http.StatusOK))
+		Expect(do(http.MethodPost, path+"/terminate", "", nil).Code).To(Equal(http.StatusForbidden))
+
+		callerGrants = []models.Grant{{Role: auth.Admin}}
+		updated = status(do(http.MethodPost, path+"/terminate", `{"reason":"Resigned"}`, nil))
+		Expect(updated.UserStatus).To(Equal(models.StatusTerminated))
+		conflict(do(http.MethodPost, path+"/activate", "", nil), "user_status cannot change from terminated (T) to active (A)")
+
+		// Editing the user cannot bring a terminated user back either, nor
+		// overwrite the recorded reason.
+		conflict(do(http.MethodPatch, path, `{"user_status":"A"}`, map[string]string{echo.HeaderContentType: handlers.MIMEMergePatch}),
+			"user_status cannot change from terminated (T) to active (A)")
+		body, _ := json.Marshal(models.User{UserName: "jdoe", FirstName: "First", LastName: "Last", Email: "jdoe@example.com", UserStatus: "I", Department: "Sales", StatusReason: "Typo"})
+		conflict(do(http.MethodPut, path, string(body), nil), "user_status cannot change from terminated (T) to inactive (I)")
+		body, _ = json.Marshal(models.User{UserName: "jdoe", FirstName: "First", LastName: "Last", Email: "jdoe@example.com", UserStatus: "T", Department: "Sales", StatusReason: "Typo"})
+		updated = status(do(http.MethodPut, path, string(body), nil))
+		Expect(updated.StatusReason).To(Equal("Resigned"))
+
+		rec := do(http.MethodGet, path+"/history?limit=1&offset=1", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var history models.AuditPage
+		Expect(json.Unmarshal(rec.Body.Bytes(), &history)).To(Succeed())
+		Expect(history.Data[0].Changes).To(Equal(map[string]models.FieldChange{
+			"user_status":   {Old: "I", New: "T"},
+			"status_reason": {Old: "", New: "Resigned"},
+		}))
+	})
+
+	It("should schedule and cancel changes that take effect later", func() {
+		user := create("jdoe", "Engineering")
+		path := "/users/" + strconv.FormatInt(user.UserID, 10)
+		scheduled := func(rec *httptest.ResponseRecorder, code int) models.ScheduledChange {
+			Expect(rec.Code).To(Equal(code), rec.Body.String())
+			var change models.ScheduledChange
+			Expect(json.Unmarshal(rec.Body.Bytes(), &change)).To(Succeed())
+			return change
+		}
+		inAnHour := time.Now().UTC().Add(time.Hour).Format(time.RFC3339)
+
+		Expect(do(http.MethodPost, path+"/scheduled-changes", `{"effective_at":"`+inAnHour+`"}`, nil).Code).To(Equal(http.StatusBadRequest))
+		Expect(do(http.MethodPost, path+"/scheduled-changes", `{"department":"Sales","effective_at":"2000-01-01T00:00:00Z"}`, nil).Code).To(Equal(http.StatusBadRequest))
+		Expect(do(http.MethodPost, path+"/scheduled-changes", `{"department":"Legal","effective_at":"`+inAnHour+`"}`, nil).Body.String()).To(ContainSubstring("unknown_department"))
+
+		move := scheduled(do(http.MethodPost, path+"/scheduled-changes", `{"department":"Sales","effective_at":"`+inAnHour+`"}`, nil), http.StatusCreated)
+		Expect(move.State).To(Equal(models.ChangePending))
+		Expect(move.Department).To(Equal("Sales"))
+
+		// A termination dated on a later day is scheduled for the start of
+		// that day rather than made now.
+		termination := scheduled(do(http.MethodPost, path+"/terminate", `{"reason":"Retiring","effective_date":"2999-01-01"}`, nil), http.StatusAccepted)
+		Expect(termination.UserStatus).To(Equal(models.StatusTerminated))
+		Expect(termination.EffectiveAt).To(Equal(time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC)))
+		rec := do(http.MethodGet, path, "", nil)
+		Expect(rec.Body.String()).To(ContainSubstring(`"user_status":"A"`))
+
+		rec = do(http.MethodGet, path+"/scheduled-changes?state=pending", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var pending []models.ScheduledChange
+		Expect(json.Unmarshal(rec.Body.Bytes(), &pending)).To(Succeed())
+		Expect(pending).To(HaveLen(2))
+		Expect(pending[0].ID).To(Equal(move.ID))
+		Expect(do(http.MethodGet, "/scheduled-changes?state=later", "", nil).Code).To(Equal(http.StatusBadRequest))
+
+		// Scheduling a termination needs the same permission as
+		// terminating now, and moving a user the same scope.
+		callerGrants = []models.Grant{{Role: auth.Editor}}
+		Expect(do(http.MethodPost, path+"/scheduled-changes", `{"user_status":"T","effective_at":"`+inAnHour+`"}`, nil).Code).To(Equal(http.StatusForbidden))
+		callerGrants = []models.Grant{{Role: auth.Editor, Department: "Engineering"}}
+		Expect(do(http.MethodPost, path+"/scheduled-changes", `{"department":"HR","effective_at":"`+inAnHour+`"}`, nil).Code).To(Equal(http.StatusForbidden))
+		callerGrants = []models.Grant{{Role: auth.Editor, Department: "HR"}}
+		changePath := "/scheduled-changes/" + strconv.FormatInt(termination.ID, 10)
+		Expect(do(http.MethodGet, changePath, "", nil).Code).To(Equal(http.StatusNotFound))
+
+		callerGrants = []models.Grant{{Role: auth.Admin}}
+		cancelled := scheduled(do(http.MethodDelete, changePath, "", nil), http.StatusOK)
+		Expect(cancelled.State).To(Equal(models.ChangeCancelled))
+		Expect(cancelled.ResolvedAt).NotTo(BeNil())
+		Expect(scheduled(do(http.MethodGet, changePath, "", nil), http.StatusOK).State).To(Equal(models.ChangeCancelled))
+		Expect(do(http.MethodDelete, changePath, "", nil).Code).To(Equal(http.StatusConflict))
+		Expect(do(http.MethodDelete, "/scheduled-changes/999", "", nil).Code).To(Equal(http.StatusNotFound))
+
+		// Terminated is final, so nothing can be scheduled to undo it.
+		Expect(do(http.MethodPost, path+"/terminate", "", nil).Code).To(Equal(http.StatusOK))
+		Expect(do(http.MethodPost, path+"/scheduled-changes", `{"user_status":"A","effective_at":"`+inAnHour+`"}`, nil).Code).To(Equal(http.StatusConflict))
+	})
+
+	It("should reject a duplicate user name on create and update", func() {
+		create("jdoe", "Engineering")
+		other := create("asmith", "Marketing")
+
+		body, _ := json.Marshal(models.User{UserName: "jdoe", FirstName: "A", LastName: "B", Email: "x@example.com", UserStatus: "A", Department: "HR"})
+		rec := do(http.MethodPost, "/users", string(body), nil)
+		Expect(rec.Code).To(Equal(http.StatusConflict))
+
+		rec = do(http.MethodPut, "/users/"+strconv.FormatInt(other.UserID, 10), string(body), nil)
+		Expect(rec.Code).To(Equal(http.StatusConflict))
+	})
+
+	It("should report every invalid field as a problem", func() {
+		rec := do(http.MethodPost, "/users", `{"user_name":"jdoe","email":"not-an-email","user_status":"X","department":"HR"}`, nil)
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Header().Get(echo.HeaderContentType)).To(Equal(handlers.MIMEProblemJSON))
+
+		var problem handlers.Problem
+		Expect(json.Unmarshal(rec.Body.Bytes(), &problem)).To(Succeed())
+		Expect(problem.Status).To(Equal(http.StatusBadRequest))
+		Expect(problem.Code).To(Equal("validation_failed"))
+		Expect(problem.Instance).To(Equal("/users"))
+		Expect(problem.Detail).To(Equal("one or more fields are invalid"))
+		Expect(problem.Errors).To(ConsistOf(
+			handlers.FieldError{Field: "first_name", Rule: "required", Message: "first_name is a required field"},
+			handlers.FieldError{Field: "last_name", Rule: "required", Message: "last_name is a required field"},
+			handlers.FieldError{Field: "email", Rule: "email", Message: "email must be a valid email address"},
+			handlers.FieldError{Field: "user_status", Rule: "oneof", Param: "A I T", Message: "user_status must be one of [A I T]"},
+		))
+	})
+
+	It("should describe invalid fields in the language asked for", func() {
+		invalid := func(acceptLanguage string) (*httptest.ResponseRecorder, handlers.Problem) {
+			rec := do(http.MethodPost, "/users", `{"user_name":"jdoe","first_name":"J","last_name":"D","email":"jdoe@example.com","user_status":"A"}`,
+				map[string]string{"Accept-Language": acceptLanguage})
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+			var problem handlers.Problem
+			Expect(json.Unmarshal(rec.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem.Errors).To(HaveLen(1))
+			Expect(problem.Errors[0].Rule).To(Equal("required"))
+			return rec, problem
+		}
+
+		rec, problem := invalid("fr-CA,fr;q=0.9,en;q=0.8")
+		Expect(rec.Header().Get("Content-Language")).To(Equal("fr"))
+		Expect(problem.Detail).To(Equal("un ou plusieurs champs sont invalides"))
+		Expect(problem.Errors[0].Message).To(Equal("department est un champ obligatoire"))
+
+		rec, problem = invalid("de;q=1, es;q=0.5")
+		Expect(rec.Header().Get("Content-Language")).To(Equal("es"))
+		Expect(problem.Errors[0].Message).To(Equal("department es un campo requerido"))
+
+		rec, problem = invalid("de")
+		Expect(rec.Header().Get("Content-Language")).To(Equal("en"))
+		Expect(problem.Errors[0].Message).To(Equal("department is a required field"))
+	})
+
+	It("should report client errors as problems with a code", func() {
+		rec := do(http.MethodGet, "/users/42", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+		Expect(rec.Header().Get(echo.HeaderContentType)).To(Equal(handlers.MIMEProblemJSON))
+
+		var problem handlers.Problem
+		Expect(json.Unmarshal(rec.Body.Bytes(), &problem)).To(Succeed())
+		Expect(problem).To(Equal(handlers.Problem{
+			Type:     "about:blank",
+			Title:    "Not Found",
+			Status:   http.StatusNotFound,
+			Detail:   "no user with id 42",
+			Instance: "/users/42",
+			Code:     "user_not_found",
+		}))
+	})
+
+	It("should only allow what the caller's roles permit", func() {
+		user := create("jdoe", "Engineering")
+		path := "/users/" + strconv.FormatInt(user.UserID, 10)
+		forbidden := func(rec *httptest.ResponseRecorder, permission auth.Permission) {
+			Expect(rec.Code).To(Equal(http.StatusForbidden))
+			var problem handlers.Problem
+			Expect(json.Unmarshal(rec.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem.Code).To(Equal("permission_denied"))
+			Expect(problem.Permission).To(Equal(string(permission)))
+			Expect(problem.Detail).To(Equal("missing permission " + string(permission)))
+		}
+
+		callerGrants = nil
+		forbidden(do(http.MethodGet, "/users", "", nil), auth.ReadUsers)
+
+		callerGrants = []models.Grant{{Role: auth.Viewer}}
+		Expect(do(http.MethodGet, path, "", nil).Code).To(Equal(http.StatusOK))
+		forbidden(do(http.MethodPatch, path, `{"department":"Sales"}`, map[string]string{echo.HeaderContentType: handlers.MIMEMergePatch}), auth.UpdateUsers)
+
+		callerGrants = []models.Grant{{Role: auth.Editor}}
+		Expect(do(http.MethodPatch, path, `{"department":"Sales"}`, map[string]string{echo.HeaderContentType: handlers.MIMEMergePatch}).Code).To(Equal(http.StatusOK))
+		forbidden(do(http.MethodPatch, path, `{"user_status":"T"}`, map[string]string{echo.HeaderContentType: handlers.MIMEMergePatch}), auth.TerminateUsers)
+		body, _ := json.Marshal(models.User{UserName: "jdoe", FirstName: "First", LastName: "Last", Email: "jdoe@example.com", UserStatus: "T", Department: "Sales"})
+		forbidden(do(http.MethodPut, path, string(body), nil), auth.TerminateUsers)
+		forbidden(do(http.MethodDelete, path, "", nil), auth.DeleteUsers)
+		forbidden(do(http.MethodPut, path+"/roles/admin", "", nil), auth.ManageRoles)
+
+		callerGrants = []models.Grant{{Role: auth.Viewer}, {Role: auth.Admin}}
+		Expect(do(http.MethodPut, path, string(body), nil).Code).To(Equal(http.StatusOK))
+		Expect(do(http.MethodDelete, path, "", nil).Code).To(Equal(http.StatusOK))
+	})
+
+	It("should confine department heads to their departments", func() {
+		create("hr1", "HR")
+		create("hr2", "HR")
+		sales := create("sales1", "Sales")
+		callerGrants = []models.Grant{{Role: auth.Admin, Department: "HR"}}
+
+		rec := do(http.MethodGet, "/users?sort=user_name", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var page models.UserPage
+		Expect(json.Unmarshal(rec.Body.Bytes(), &page)).To(Succeed())
+		Expect(page.Total).To(Equal(int64(2)))
+		Expect(page.Data).To(HaveEach(HaveField("Department", "HR")))
+
+		salesPath := "/users/" + strconv.FormatInt(sales.UserID, 10)
+		Expect(do(http.MethodGet, salesPath, "", nil).Code).To(Equal(http.StatusNotFound))
+		Expect(do(http.MethodPatch, salesPath, `{"first_name":"X"}`, map[string]string{echo.HeaderContentType: handlers.MIMEMergePatch}).Code).To(Equal(http.StatusNotFound))
+		Expect(do(http.MethodDelete, salesPath, "", nil).Code).To(Equal(http.StatusNotFound))
+
+		hr := create("hr3", "HR")
+		hrPath := "/users/" + strconv.FormatInt(hr.UserID, 10)
+		rec = do(http.MethodPatch, hrPath, `{"department":"Sales"}`, map[string]string{echo.HeaderContentType: handlers.MIMEMergePatch})
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+		Expect(rec.Body.String()).To(ContainSubstring("department_out_of_scope"))
+
+		body, _ := json.Marshal(models.User{UserName: "new", FirstName: "A", LastName: "B", Email: "new@example.com", UserStatus: "A", Department: "Sales"})
+		rec = do(http.MethodPost, "/users", string(body), nil)
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+		Expect(rec.Body.String()).To(ContainSubstring("department_out_of_scope"))
+
+		Expect(do(http.MethodPatch, hrPath, `{"user_status":"T"}`, map[string]string{echo.HeaderContentType: handlers.MIMEMergePatch}).Code).To(Equal(http.StatusOK))
+		Expect(do(http.MethodDelete, hrPath, "", nil).Code).To(Equal(http.StatusOK))
+
+		// A department head cannot widen their own grants.
+		rec = do(http.MethodPut, salesPath+"/roles/admin", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+		Expect(rec.Body.String()).To(ContainSubstring(string(auth.ManageRoles)))
+	})
+
+	It("should only include the deleted users of the departments a caller may delete in", func() {
+		create("eng1", "Engineering")
+		create("hr1", "HR")
+		engineer := create("eng2", "Engineering")
+		hr := create("hr2", "HR")
+		for _, user := range []models.User{engineer, hr} {
+			Expect(do(http.MethodDelete, "/users/"+strconv.FormatInt(user.UserID, 10), "", nil).Code).To(Equal(http.StatusOK))
+		}
+		callerGrants = []models.Grant{{Role: auth.Viewer, Department: "Engineering"}, {Role: auth.Admin, Department: "HR"}}
+
+		rec := do(http.MethodGet, "/users?include_deleted=true&sort=user_name", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var page models.UserPage
+		Expect(json.Unmarshal(rec.Body.Bytes(), &page)).To(Succeed())
+		names := []string{}
+		for _, user := range page.Data {
+			names = append(names, user.UserName)
+		}
+		Expect(names).To(Equal([]string{"eng1", "hr1", "hr2"}))
+
+		Expect(do(http.MethodGet, "/users/"+strconv.FormatInt(engineer.UserID, 10)+"?include_deleted=true", "", nil).Code).To(Equal(http.StatusNotFound))
+		Expect(do(http.MethodGet, "/users/"+strconv.FormatInt(hr.UserID, 10)+"?include_deleted=true", "", nil).Code).To(Equal(http.StatusOK))
+	})
+
+	It("should only let department editors terminate with the terminate permission", func() {
+		user := create("hr1", "HR")
+		path := "/users/" + strconv.FormatInt(user.UserID, 10)
+		callerGrants = []models.Grant{{Role: auth.Editor, Department: "HR"}, {Role: auth.Admin, Department: "Sales"}}
+
+		rec := do(http.MethodPatch, path, `{"user_status":"T"}`, map[string]string{echo.HeaderContentType: handlers.MIMEMergePatch})
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+		Expect(rec.Body.String()).To(ContainSubstring(string(auth.TerminateUsers)))
+	})
+
+	It("should grant and revoke roles", func() {
+		user := create("jdoe", "Engineering")
+		path := "/users/" + strconv.FormatInt(user.UserID, 10) + "/roles"
+		roles := func(rec *httptest.ResponseRecorder) []models.Grant {
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			var body models.UserRoles
+			Expect(json.Unmarshal(rec.Body.Bytes(), &body)).To(Succeed())
+			Expect(body.UserID).To(Equal(user.UserID))
+			return body.Roles
+		}
+
+		Expect(roles(do(http.MethodGet, path, "", nil))).To(BeEmpty())
+		Expect(roles(do(http.MethodPut, path+"/editor", "", nil))).To(Equal([]models.Grant{{Role: "editor"}}))
+		Expect(roles(do(http.MethodPut, path+"/viewer?department=Sales", "", nil))).To(Equal([]models.Grant{{Role: "editor"}, {Role: "viewer", Department: "Sales"}}))
+		Expect(roles(do(http.MethodDelete, path+"/editor", "", nil))).To(Equal([]models.Grant{{Role: "viewer", Department: "Sales"}}))
+
+		rec := do(http.MethodPut, path+"/owner", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+		Expect(rec.Body.String()).To(ContainSubstring("role_not_found"))
+		Expect(do(http.MethodPut, "/users/42/roles/admin", "", nil).Code).To(Equal(http.StatusNotFound))
+
+		rec = do(http.MethodGet, "/roles", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var all []models.Role
+		Expect(json.Unmarshal(rec.Body.Bytes(), &all)).To(Succeed())
+		Expect(all).To(ContainElement(models.Role{Name: "viewer", Description: "Read users", Permissions: []string{"users:read"}}))
+	})
+
+	It("should keep the history of a user's changes", func() {
+		user := create("jdoe", "Engineering")
+		path := "/users/" + strconv.FormatInt(user.UserID, 10)
+		create("asmith", "Marketing")
+
+		rec := do(http.MethodPatch, path, `{"department":"Sales"}`, map[string]string{
+			echo.HeaderContentType: handlers.MIMEMergePatch,
+			echo.HeaderXRequestID:  "req-1",
+		})
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		// A failed write leaves no entry.
+		Expect(do(http.MethodPatch, path, `{"user_status":"X"}`, map[string]string{echo.HeaderContentType: handlers.MIMEMergePatch}).Code).To(Equal(http.StatusBadRequest))
+		Expect(do(http.MethodDelete, path, "", nil).Code).To(Equal(http.StatusOK))
+
+		history := func(target string) models.AuditPage {
+			rec := do(http.MethodGet, target, "", nil)
+			Expect(rec.Code).To(Equal(http.StatusOK), rec.Body.String())
+			var page models.AuditPage
+			Expect(json.Unmarshal(rec.Body.Bytes(), &page)).To(Succeed())
+			return page
+		}
+
+		page := history(path + "/history")
+		Expect(page.Total).To(Equal(int64(3)))
+		Expect(page.Data[0].Operation).To(Equal(models.AuditDelete))
+		Expect(page.Data[0].Changes).To(HaveKeyWithValue("department", models.FieldChange{Old: "Sales"}))
+		Expect(page.Data[1].Operation).To(Equal(models.AuditUpdate))
+		Expect(page.Data[1].RequestID).To(Equal("req-1"))
+		Expect(page.Data[1].Changes).To(Equal(map[string]models.FieldChange{"department": {Old: "Engineering", New: "Sales"}}))
+		Expect(page.Data[2].Operation).To(Equal(models.AuditCreate))
+		Expect(page.Data[2].Changes).To(HaveKeyWithValue("user_name", models.FieldChange{New: "jdoe"}))
+
+		Expect(history("/audit").Total).To(Equal(int64(4)))
+		Expect(history("/audit?operation=create&limit=1").Data).To(HaveLen(1))
+		Expect(history("/audit?operation=create").Total).To(Equal(int64(2)))
+		Expect(history("/audit?to=2000-01-01T00:00:00Z").Total).To(BeZero())
+		Expect(do(http.MethodGet, "/audit?operation=rename", "", nil).Code).To(Equal(http.StatusBadRequest))
+		Expect(do(http.MethodGet, "/audit?from=yesterday", "", nil).Code).To(Equal(http.StatusBadRequest))
+
+		rec = do(http.MethodGet, "/audit/verify", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var report models.AuditVerification
+		Expect(json.Unmarshal(rec.Body.Bytes(), &report)).To(Succeed())
+		Expect(report.Valid).To(BeTrue())
+		Expect(report.Entries).To(Equal(int64(4)))
+		Expect(report.Head).To(Equal(history("/audit?limit=1").Data[0].Hash))
+
+		callerGrants = []models.Grant{{Role: auth.Editor}}
+		Expect(do(http.MethodGet, path+"/history", "", nil).Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("should create, list and revoke API keys", func() {
+		rec := do(http.MethodPost, "/api-keys", `{"name":"HR sync","scopes":["users:update","users:read","users:read"]}`, nil)
+		Expect(rec.Code).To(Equal(http.StatusCreated))
+		var created models.CreatedAPIKey
+		Expect(json.Unmarshal(rec.Body.Bytes(), &created)).To(Succeed())
+		Expect(created.Key).To(HavePrefix(auth.APIKeyPrefix + created.Prefix + "_"))
+		Expect(created.Scopes).To(Equal([]string{"users:read", "users:update"}))
+		Expect(rec.Body.String()).NotTo(ContainSubstring("hash"))
+
+		rec = do(http.MethodGet, "/api-keys", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring(created.Prefix))
+		Expect(rec.Body.String()).NotTo(ContainSubstring(created.Key))
+
+		path := "/api-keys/" + strconv.FormatInt(created.ID, 10)
+		rec = do(http.MethodDelete, path, "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var revoked models.APIKey
+		Expect(json.Unmarshal(rec.Body.Bytes(), &revoked)).To(Succeed())
+		Expect(revoked.RevokedAt).NotTo(BeNil())
+
+		rec = do(http.MethodDelete, "/api-keys/42", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+		Expect(rec.Body.String()).To(ContainSubstring("api_key_not_found"))
+
+		Expect(do(http.MethodPost, "/api-keys", `{"name":"x","scopes":["roles:manage"]}`, nil).Code).To(Equal(http.StatusBadRequest))
+		Expect(do(http.MethodPost, "/api-keys", `{"name":"x","scopes":[]}`, nil).Code).To(Equal(http.StatusBadRequest))
+		Expect(do(http.MethodPost, "/api-keys", `{"name":"x","scopes":["users:read"],"expires_at":"2001-01-01T00:00:00Z"}`, nil).Code).To(Equal(http.StatusBadRequest))
+
+		callerGrants = []models.Grant{{Role: auth.Editor}}
+		Expect(do(http.MethodGet, "/api-keys", "", nil).Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("should track when users were created and last changed", func() {
+		user := create("jdoe", "Engineering")
+		Expect(user.CreatedAt).To(BeTemporally("~", time.Now(), time.Minute))
+		Expect(user.UpdatedAt).To(Equal(user.CreatedAt))
+		create("asmith", "Engineering")
+		path := "/users/" + strconv.FormatInt(user.UserID, 10)
+
+		// The tracked fields are read-only.
+		time.Sleep(time.Millisecond)
+		rec := do(http.MethodPatch, path, `{"department":"Sales","created_at":"2000-01-01T00:00:00Z","updated_by":"mallory"}`, map[string]string{
+			echo.HeaderContentType: handlers.MIMEMergePatch,
+		})
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var changed models.User
+		Expect(json.Unmarshal(rec.Body.Bytes(), &changed)).To(Succeed())
+		Expect(changed.CreatedAt).To(Equal(user.CreatedAt))
+		Expect(changed.UpdatedAt).To(BeTemporally(">", user.UpdatedAt))
+		Expect(changed.UpdatedBy).To(BeEmpty())
+
+		list := func(query string) models.UserPage {
+			rec := do(http.MethodGet, "/users?"+query, "", nil)
+			Expect(rec.Code).To(Equal(http.StatusOK), rec.Body.String())
+			var page models.UserPage
+			Expect(json.Unmarshal(rec.Body.Bytes(), &page)).To(Succeed())
+			return page
+		}
+
+		page := list("sort=-updated_at&limit=1")
+		Expect(page.Data[0].UserName).To(Equal("jdoe"))
+		page = list("sort=-updated_at&limit=1&cursor=" + page.NextCursor)
+		Expect(page.Data[0].UserName).To(Equal("asmith"))
+
+		since := url.QueryEscape(changed.UpdatedAt.Format(time.RFC3339Nano))
+		Expect(list("updated_from=" + since).Total).To(Equal(int64(1)))
+		Expect(list("updated_to=" + since).Total).To(Equal(int64(1)))
+		Expect(list("created_to=2000-01-01T00:00:00Z").Total).To(BeZero())
+		Expect(do(http.MethodGet, "/users?created_from=yesterday", "", nil).Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should manage departments and move users with a renamed one", func() {
+		rec := do(http.MethodPost, "/departments", `{"code":"PLT","name":"Platform","parent_id":1,"cost_center":"CC-100"}`, nil)
+		Expect(rec.Code).To(Equal(http.StatusCreated), rec.Body.String())
+		var platform models.Department
+		Expect(json.Unmarshal(rec.Body.Bytes(), &platform)).To(Succeed())
+		Expect(platform.ParentID).To(HaveValue(Equal(int64(1))))
+		path := "/departments/" + strconv.FormatInt(platform.ID, 10)
+
+		rec = do(http.MethodGet, "/departments", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var departments []models.Department
+		Expect(json.Unmarshal(rec.Body.Bytes(), &departments)).To(Succeed())
+		Expect(departments).To(HaveLen(5))
+
+		Expect(do(http.MethodPost, "/departments", `{"code":"PLT","name":"Other"}`, nil).Code).To(Equal(http.StatusConflict))
+		Expect(do(http.MethodPost, "/departments", `{"code":"X","name":"Other","parent_id":42}`, nil).Code).To(Equal(http.StatusBadRequest))
+		Expect(do(http.MethodPost, "/departments", `{"name":"Other"}`, nil).Code).To(Equal(http.StatusBadRequest))
+		Expect(do(http.MethodPut, "/departments/1", `{"code":"Engineering","name":"Engineering","parent_id":`+strconv.FormatInt(platform.ID, 10)+`}`, nil).Body.String()).To(ContainSubstring("department_cycle"))
+		Expect(do(http.MethodGet, "/departments/42", "", nil).Body.String()).To(ContainSubstring("department_not_found"))
+
+		rec = do(http.MethodPost, "/users", `{"user_name":"x","first_name":"X","last_name":"X","email":"x@example.com","user_status":"A","department":"Legal"}`, nil)
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Body.String()).To(ContainSubstring("unknown_department"))
+
+		user := create("jdoe", "Platform")
+		rec = do(http.MethodPut, path, `{"code":"PLT","name":"Infrastructure"}`, nil)
+		Expect(rec.Code).To(Equal(http.StatusOK), rec.Body.String())
+		Expect(do(http.MethodGet, "/users/by-username/jdoe", "", nil).Body.String()).To(ContainSubstring(`"department":"Infrastructure"`))
+
+		rec = do(http.MethodGet, "/users/"+strconv.FormatInt(user.UserID, 10)+"/history?operation=update", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var history models.AuditPage
+		Expect(json.Unmarshal(rec.Body.Bytes(), &history)).To(Succeed())
+		Expect(history.Total).To(Equal(int64(1)))
+		Expect(history.Data[0].Changes).To(HaveKeyWithValue("department", models.FieldChange{Old: "Platform", New: "Infrastructure"}))
+
+		rec = do(http.MethodDelete, path, "", nil)
+		Expect(rec.Code).To(Equal(http.StatusConflict))
+		Expect(rec.Body.String()).To(ContainSubstring("department_in_use"))
+		Expect(do(http.MethodDelete, "/users/"+strconv.FormatInt(user.UserID, 10), "", nil).Code).To(Equal(http.StatusOK))
+		_, err := repo.Purge(context.Background(), time.Now().Add(time.Second))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(do(http.MethodDelete, path, "", nil).Code).To(Equal(http.StatusOK))
+		Expect(do(http.MethodGet, path, "", nil).Code).To(Equal(http.StatusNotFound))
+
+		// Names may be as long as the department column of users.
+		long := strings.Repeat("x", 255)
+		Expect(do(http.MethodPost, "/departments", `{"code":"LNG","name":"`+long+`"}`, nil).Code).To(Equal(http.StatusCreated))
+		create("long", long)
+		Expect(do(http.MethodPost, "/departments", `{"code":"LNGR","name":"`+long+`x"}`, nil).Code).To(Equal(http.StatusBadRequest))
+
+		callerGrants = []models.Grant{{Role: auth.Editor}}
+		Expect(do(http.MethodGet, "/departments", "", nil).Code).To(Equal(http.StatusOK))
+		Expect(do(http.MethodPost, "/departments", `{"code":"LGL","name":"Legal"}`, nil).Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("should keep reporting lines and chart them", func() {
+		ceo := create("ceo", "HR")
+		vp := create("vp", "Engineering")
+		dev := create("dev", "Sales")
+		merge := map[string]string{echo.HeaderContentType: handlers.MIMEMergePatch}
+		pathOf := func(user models.User) string {
+			return "/users/" + strconv.FormatInt(user.UserID, 10)
+		}
+		reportTo := func(user, manager models.User) *httptest.ResponseRecorder {
+			return do(http.MethodPatch, pathOf(user), `{"manager_id":`+strconv.FormatInt(manager.UserID, 10)+`}`, merge)
+		}
+		names := func(rec *httptest.ResponseRecorder) []string {
+			Expect(rec.Code).To(Equal(http.StatusOK), rec.Body.String())
+			var users []models.User
+			Expect(json.Unmarshal(rec.Body.Bytes(), &users)).To(Succeed())
+			names := []string{}
+			for _, u := range users {
+				names = append(names, u.UserName)
+			}
+			return names
+		}
+
+		Expect(reportTo(vp, ceo).Code).To(Equal(http.StatusOK))
+		Expect(reportTo(dev, vp).Code).To(Equal(http.StatusOK))
+		rec := reportTo(ceo, dev)
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Body.String()).To(ContainSubstring("manager_cycle"))
+		rec = do(http.MethodPatch, pathOf(ceo), `{"manager_id":42}`, merge)
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Body.String()).To(ContainSubstring("unknown_manager"))
+
+		Expect(names(do(http.MethodGet, pathOf(ceo)+"/reports", "", nil))).To(Equal([]string{"vp"}))
+		Expect(names(do(http.MethodGet, pathOf(dev)+"/managers", "", nil))).To(Equal([]string{"vp", "ceo"}))
+		Expect(names(do(http.MethodGet, pathOf(ceo)+"/subordinates", "", nil))).To(Equal([]string{"vp", "dev"}))
+		Expect(do(http.MethodGet, "/users/42/managers", "", nil).Code).To(Equal(http.StatusNotFound))
+
+		rec = do(http.MethodGet, "/users?manager_id="+strconv.FormatInt(vp.UserID, 10), "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var page models.UserPage
+		Expect(json.Unmarshal(rec.Body.Bytes(), &page)).To(Succeed())
+		Expect(page.Data).To(ConsistOf(HaveField("UserName", "dev")))
+		Expect(do(http.MethodGet, "/users?manager_id=vp", "", nil).Code).To(Equal(http.StatusBadRequest))
+
+		rec = do(http.MethodGet, "/org-chart", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var chart []models.OrgChartNode
+		Expect(json.Unmarshal(rec.Body.Bytes(), &chart)).To(Succeed())
+		Expect(chart).To(HaveLen(1))
+		Expect(chart[0].UserName).To(Equal("ceo"))
+		Expect(chart[0].Reports[0].Reports[0].UserName).To(Equal("dev"))
+
+		rec = do(http.MethodGet, "/org-chart?format=dot&root="+strconv.FormatInt(vp.UserID, 10), "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get(echo.HeaderContentType)).To(HavePrefix("text/vnd.graphviz"))
+		Expect(rec.Body.String()).To(ContainSubstring(fmt.Sprintf("u%d -> u%d;", vp.UserID, dev.UserID)))
+		Expect(rec.Body.String()).NotTo(ContainSubstring("(ceo)"))
+
+		rec = do(http.MethodGet, "/org-chart?format=mermaid", "", nil)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(HavePrefix("flowchart TD\n"))
+		Expect(do(http.MethodGet, "/org-chart?format=svg", "", nil).Code).To(Equal(http.StatusBadRequest))
+		Expect(do(http.MethodGet, "/org-chart?root=42", "", nil).Code).To(Equal(http.StatusNotFound))
+
+		// A department head sees the reports of their department, with the
+		// lines through the others still followed.
+		callerGrants = []models.Grant{{Role: auth.Admin, Department: "HR"}, {Role: auth.Admin, Department: "Sales"}}
+		Expect(names(do(http.MethodGet, pathOf(ceo)+"/subordinates", "", nil))).To(Equal([]string{"dev"}))
+		Expect(do(http.MethodGet, pathOf(vp)+"/reports", "", nil).Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should page through filtered users with cursors", func() {
+		for _, name := range []string{"a", "b", "c", "d", "e"} {
+			create(name, "Sales")
+		}
+		create("z", "HR")
+
+		var seen []string
+		next := "/users?department=Sales&sort=-user_name&limit=2"
+		for next != "" {
+			rec := do(http.MethodGet, next, "", nil)
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			var page models.UserPage
+			Expect(json.Unmarshal(rec.Body.Bytes(), &page)).To(Succeed())
+			Expect(page.Total).To(Equal(int64(5)))
+			for _, u := range page.Data {
+				seen = append(seen, u.UserName)
+			}
+			next = ""
+			if page.NextCursor != "" {
+				next = "/users?department=Sales&sort=-user_name&limit=2&cursor=" + page.NextCursor
+			}
+		}
+		Expect(seen).To(Equal([]string{"e", "d", "c", "b", "a"}))
+	})
+}
+
+var _ = Describe("User Handlers (in memory)", func() {
+	userHandlerBehavior(func() repository.UserRepository {
+		return repository.NewMemoryUserRepository()
+	})
+})
+
+var _ = Describe("User Handlers (SQLite)", func() {
+	var database *sql.DB
+
+	AfterEach(func() {
+		database.Close()
+	})
+
+	userHandlerBehavior(func() repository.UserRepository {
+		connector := &connectors.SQLiteConnector{}
+		var err error
+		database, err = connector.Open("sqlite://:memory:")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(connector.RunMigrationsUp(database)).To(Succeed())
+		return repository.NewSQLiteUserRepository(database)
+	})
+})
@@ -2,21 +2,26 @@ package handlers_test
 
 import (
 	"bytes"
+	"database/sql/driver"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/sedmo/integra-coding-assessment/go-backend/db"
 	"github.com/sedmo/integra-coding-assessment/go-backend/db/connectors"
 	"github.com/sedmo/integra-coding-assessment/go-backend/handlers"
 	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
 )
 
 func TestHandlers(t *testing.T) {
@@ -31,6 +36,7 @@ var _ = Describe("User Handlers", func() {
 		c             echo.Context
 		request       *http.Request
 		mockConnector *connectors.MockConnector
+		h             *handlers.UserHandler
 	)
 
 	BeforeEach(func() {
@@ -54,8 +60,9 @@ var _ = Describe("User Handlers", func() {
 		mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM information_schema.tables WHERE table_schema = 'public'").WillReturnRows(sqlmock.NewRows([]string{}))
 
 		// Initialize database
-		err = db.InitDB(mockConnector)
+		database, err := db.InitDB(mockConnector)
 		Expect(err).NotTo(HaveOccurred())
+		h = handlers.NewUserHandler(repository.NewPostgresUserRepository(database))
 
 		// Clear expectations set by InitDB
 		mockConnector.Sqlmock.ExpectationsWereMet()
@@ -67,6 +74,48 @@ var _ = Describe("User Handlers", func() {
 		os.Unsetenv("DATABASE_URL")
 	})
 
+	// expectAudited expects the queries of write in a transaction that also
+	// chains an audit entry with args, if any are given, onto the last one.
+	expectAudited := func(write func(), args ...driver.Value) {
+		mockConnector.Sqlmock.ExpectBegin()
+		write()
+		mockConnector.Sqlmock.ExpectExec("LOCK TABLE user_audit IN SHARE ROW EXCLUSIVE MODE").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mockConnector.Sqlmock.ExpectQuery("SELECT unsealed_until, head, entries FROM user_audit_chain").
+			WillReturnRows(sqlmock.NewRows([]string{"unsealed_until", "head", "entries"}).AddRow(0, strings.Repeat("0", 64), 1))
+		audit := mockConnector.Sqlmock.ExpectQuery("INSERT INTO user_audit \\(user_id,actor,request_id,operation,changes,occurred_at,prev_hash,hash\\) VALUES \\(\\$1,\\$2,\\$3,\\$4,\\$5,\\$6,\\$7,\\$8\\) RETURNING audit_id")
+		if len(args) > 0 {
+			audit.WithArgs(args...)
+		}
+		audit.WillReturnRows(sqlmock.NewRows([]string{"audit_id"}).AddRow(1))
+		mockConnector.Sqlmock.ExpectExec("UPDATE user_audit_chain SET head = \\$1, entries = entries \\+ 1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mockConnector.Sqlmock.ExpectCommit()
+	}
+
+	// expectRolledBack expects the queries of write in a transaction that
+	// is rolled back.
+	expectRolledBack := func(write func()) {
+		mockConnector.Sqlmock.ExpectBegin()
+		write()
+		mockConnector.Sqlmock.ExpectRollback()
+	}
+
+	// expectStoredUser expects user id to be read, at version.
+	expectStoredUser := func(id int64, version int) {
+		mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "user_name", "first_name", "last_name", "email", "user_status", "department", "version", "deleted_at", "status_reason", "status_effective_date", "created_at", "created_by", "updated_at", "updated_by", "manager_id"}).
+				AddRow(id, "newuser", "New", "User", "newuser@example.com", "A", "Engineering", version, nil, "", nil, time.Time{}, "", time.Time{}, "", nil))
+	}
+
+	// expectNoStoredUser expects user id to be missing.
+	expectNoStoredUser := func(id int64) {
+		mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{}))
+	}
+
 	Describe("CreateUser", func() {
 		It("should return error for invalid user data", func() {
 			// Simulate sending invalid user data (empty body)
@@ -75,7 +124,7 @@ var _ = Describe("User Handlers", func() {
 			c = e.NewContext(request, rec)
 
 			// Call CreateUser handler
-			err := handlers.CreateUser(c)
+			err := h.CreateUser(c)
 
 			// Expect BadRequest due to validation failure
 			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
@@ -83,7 +132,7 @@ var _ = Describe("User Handlers", func() {
 			Expect(httpErr.Code).To(Equal(http.StatusBadRequest))
 		})
 
-		It("should check for existing username and return conflict if exists", func() {
+		It("should return conflict when the username is already taken", func() {
 			user := models.User{
 				UserName:   "existinguser",
 				FirstName:  "Existing",
@@ -97,13 +146,14 @@ var _ = Describe("User Handlers", func() {
 			request = httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
 			request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 			c = e.NewContext(request, rec)
-			// Expect a query to check if username exists
-			mockConnector.Sqlmock.ExpectQuery("SELECT \\* FROM users WHERE user_name = \\$1").
-				WithArgs(user.UserName).
-				WillReturnRows(sqlmock.NewRows([]string{"user_id", "user_name", "first_name", "last_name", "email", "user_status", "department"}).
-					AddRow(1, user.UserName, user.FirstName, user.LastName, user.Email, user.UserStatus, user.Department))
+			// The unique constraint on user_name rejects the insert
+			expectRolledBack(func() {
+				mockConnector.Sqlmock.ExpectQuery("INSERT INTO users").
+					WithArgs(user.UserName, user.FirstName, user.LastName, user.Email, user.UserStatus, user.Department, nil, sqlmock.AnyArg(), "", sqlmock.AnyArg(), "").
+					WillReturnError(&pq.Error{Code: "23505", Constraint: "users_user_name_key"})
+			})
 
-			err := handlers.CreateUser(c)
+			err := h.CreateUser(c)
 
 			// Expect StatusConflict due to existing username
 			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
@@ -125,18 +175,15 @@ var _ = Describe("User Handlers", func() {
 			request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 			c = e.NewContext(request, rec)
 
-			// Expect a query to check if the username already exists
-			mockConnector.Sqlmock.ExpectQuery("SELECT \\* FROM users WHERE user_name = \\$1").
-				WithArgs(user.UserName).
-				WillReturnRows(sqlmock.NewRows([]string{})) // No existing user
-
-			// Expect the insert query
-			mockConnector.Sqlmock.ExpectQuery("INSERT INTO users \\(user_name,first_name,last_name,email,user_status,department\\) VALUES \\(\\$1,\\$2,\\$3,\\$4,\\$5,\\$6\\) RETURNING user_id").
-				WithArgs(user.UserName, user.FirstName, user.LastName, user.Email, user.UserStatus, user.Department).
-				WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+			// Expect the insert query and its audit entry
+			expectAudited(func() {
+				mockConnector.Sqlmock.ExpectQuery("INSERT INTO users \\(user_name,first_name,last_name,email,user_status,department,manager_id,created_at,created_by,updated_at,updated_by\\) VALUES \\(\\$1,\\$2,\\$3,\\$4,\\$5,\\$6,\\$7,\\$8,\\$9,\\$10,\\$11\\) RETURNING user_id, version").
+					WithArgs(user.UserName, user.FirstName, user.LastName, user.Email, user.UserStatus, user.Department, nil, sqlmock.AnyArg(), "", sqlmock.AnyArg(), "").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "version"}).AddRow(1, 1))
+			})
 
 			// Call CreateUser handler
-			err := handlers.CreateUser(c)
+			err := h.CreateUser(c)
 
 			// Expect no error and a StatusCreated
 			Expect(err).ToNot(HaveOccurred())
@@ -151,12 +198,18 @@ var _ = Describe("User Handlers", func() {
 	})
 
 	Describe("GetUsers", func() {
-		It("should return all users", func() {
+		var userRows = func() *sqlmock.Rows {
+			return sqlmock.NewRows([]string{"user_id", "user_name", "first_name", "last_name", "email", "user_status", "department", "version", "deleted_at", "status_reason", "status_effective_date", "created_at", "created_by", "updated_at", "updated_by", "manager_id"})
+		}
+
+		It("should return the first page of users", func() {
 			// Mock the database response
-			mockConnector.Sqlmock.ExpectQuery("SELECT \\* FROM users").WillReturnRows(
-				sqlmock.NewRows([]string{"user_id", "user_name", "first_name", "last_name", "email", "user_status", "department"}).
-					AddRow(1, "user1", "User", "One", "user1@example.com", "A", "Engineering").
-					AddRow(2, "user2", "User", "Two", "user2@example.com", "I", "Marketing"),
+			mockConnector.Sqlmock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+			mockConnector.Sqlmock.ExpectQuery("SELECT user_id, user_name, first_name, last_name, email, user_status, department, version, deleted_at, status_reason, status_effective_date, created_at, created_by, updated_at, updated_by, manager_id FROM users WHERE deleted_at IS NULL ORDER BY user_id ASC LIMIT 51").WillReturnRows(
+				userRows().
+					AddRow(1, "user1", "User", "One", "user1@example.com", "A", "Engineering", 1, nil, "", nil, time.Time{}, "", time.Time{}, "", nil).
+					AddRow(2, "user2", "User", "Two", "user2@example.com", "I", "Marketing", 1, nil, "", nil, time.Time{}, "", time.Time{}, "", nil),
 			)
 
 			// Make the request
@@ -164,19 +217,205 @@ var _ = Describe("User Handlers", func() {
 			c = e.NewContext(request, rec)
 
 			// Call the handler
-			err := handlers.GetUsers(c)
+			err := h.GetUsers(c)
 
 			// Expect no error
 			Expect(err).To(BeNil())
 			Expect(rec.Code).To(Equal(http.StatusOK))
 
 			// Verify the response
-			var users []models.User
-			err = json.Unmarshal(rec.Body.Bytes(), &users)
+			var page models.UserPage
+			err = json.Unmarshal(rec.Body.Bytes(), &page)
+			Expect(err).To(BeNil())
+			Expect(page.Total).To(Equal(int64(2)))
+			Expect(page.Limit).To(Equal(uint64(50)))
+			Expect(page.Data).To(HaveLen(2))
+			Expect(page.Data[0].UserName).To(Equal("user1"))
+			Expect(page.Data[1].UserName).To(Equal("user2"))
+			Expect(page.Links.Next).To(BeEmpty())
+			Expect(page.Links.Prev).To(BeEmpty())
+			Expect(page.NextCursor).To(BeEmpty())
+		})
+
+		It("should apply filters, sorting and offset", func() {
+			mockConnector.Sqlmock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users WHERE deleted_at IS NULL AND user_status = \\$1 AND department IN \\(\\$2,\\$3\\) AND email LIKE \\$4").
+				WithArgs("A", "Sales", "HR", "j\\_doe%").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+			mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE deleted_at IS NULL AND user_status = \\$1 AND department IN \\(\\$2,\\$3\\) AND email LIKE \\$4 ORDER BY department ASC, user_name DESC, user_id ASC LIMIT 3 OFFSET 2").
+				WithArgs("A", "Sales", "HR", "j\\_doe%").
+				WillReturnRows(userRows().
+					AddRow(3, "jdoe", "John", "Doe", "j_doe@example.com", "A", "HR", 1, nil, "", nil, time.Time{}, "", time.Time{}, "", nil).
+					AddRow(4, "jdoe2", "Jane", "Doe", "j_doe2@example.com", "A", "Sales", 1, nil, "", nil, time.Time{}, "", time.Time{}, "", nil).
+					AddRow(5, "jdoe3", "Jim", "Doe", "j_doe3@example.com", "A", "Sales", 1, nil, "", nil, time.Time{}, "", time.Time{}, "", nil))
+
+			request = httptest.NewRequest(http.MethodGet, "/users?user_status=A&department=Sales&department=HR&email_prefix=j_doe&sort=department,-user_name&limit=2&offset=2", nil)
+			c = e.NewContext(request, rec)
+
+			err := h.GetUsers(c)
 			Expect(err).To(BeNil())
-			Expect(users).To(HaveLen(2))
-			Expect(users[0].UserName).To(Equal("user1"))
-			Expect(users[1].UserName).To(Equal("user2"))
+
+			var page models.UserPage
+			Expect(json.Unmarshal(rec.Body.Bytes(), &page)).To(Succeed())
+			Expect(page.Total).To(Equal(int64(5)))
+			Expect(page.Data).To(HaveLen(2))
+			Expect(page.Links.Next).To(ContainSubstring("offset=4"))
+			Expect(page.Links.Prev).To(ContainSubstring("offset=0"))
+			Expect(page.Links.Next).To(ContainSubstring("sort=department%2C-user_name"))
+			Expect(page.NextCursor).NotTo(BeEmpty())
+			Expect(mockConnector.Sqlmock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("should continue from a cursor using a keyset predicate", func() {
+			mockConnector.Sqlmock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+			mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE deleted_at IS NULL ORDER BY user_name ASC, user_id ASC LIMIT 2").
+				WillReturnRows(userRows().
+					AddRow(1, "adam", "Adam", "One", "adam@example.com", "A", "HR", 1, nil, "", nil, time.Time{}, "", time.Time{}, "", nil).
+					AddRow(2, "beth", "Beth", "Two", "beth@example.com", "A", "HR", 1, nil, "", nil, time.Time{}, "", time.Time{}, "", nil))
+
+			request = httptest.NewRequest(http.MethodGet, "/users?sort=user_name&limit=1", nil)
+			c = e.NewContext(request, rec)
+			Expect(h.GetUsers(c)).To(Succeed())
+
+			var page models.UserPage
+			Expect(json.Unmarshal(rec.Body.Bytes(), &page)).To(Succeed())
+			Expect(page.NextCursor).NotTo(BeEmpty())
+
+			rec = httptest.NewRecorder()
+			mockConnector.Sqlmock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+			mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE deleted_at IS NULL AND \\(\\(user_name > \\$1\\) OR \\(user_name = \\$2 AND user_id > \\$3\\)\\) ORDER BY user_name ASC, user_id ASC LIMIT 2").
+				WithArgs("adam", "adam", int64(1)).
+				WillReturnRows(userRows().
+					AddRow(2, "beth", "Beth", "Two", "beth@example.com", "A", "HR", 1, nil, "", nil, time.Time{}, "", time.Time{}, "", nil))
+
+			request = httptest.NewRequest(http.MethodGet, "/users?sort=user_name&limit=1&cursor="+page.NextCursor, nil)
+			c = e.NewContext(request, rec)
+			Expect(h.GetUsers(c)).To(Succeed())
+
+			var next models.UserPage
+			Expect(json.Unmarshal(rec.Body.Bytes(), &next)).To(Succeed())
+			Expect(next.Data).To(HaveLen(1))
+			Expect(next.Data[0].UserName).To(Equal("beth"))
+			Expect(next.Links.Next).To(BeEmpty())
+			Expect(next.Links.Prev).NotTo(BeEmpty())
+			Expect(mockConnector.Sqlmock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("should reject sorting on a column that is not whitelisted", func() {
+			request = httptest.NewRequest(http.MethodGet, "/users?sort=password", nil)
+			c = e.NewContext(request, rec)
+
+			err := h.GetUsers(c)
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should reject a cursor issued for another sort order", func() {
+			mockConnector.Sqlmock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+			mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE deleted_at IS NULL ORDER BY user_id ASC LIMIT 2").
+				WillReturnRows(userRows().
+					AddRow(1, "adam", "Adam", "One", "adam@example.com", "A", "HR", 1, nil, "", nil, time.Time{}, "", time.Time{}, "", nil).
+					AddRow(2, "beth", "Beth", "Two", "beth@example.com", "A", "HR", 1, nil, "", nil, time.Time{}, "", time.Time{}, "", nil))
+
+			request = httptest.NewRequest(http.MethodGet, "/users?limit=1", nil)
+			c = e.NewContext(request, rec)
+			Expect(h.GetUsers(c)).To(Succeed())
+
+			var page models.UserPage
+			Expect(json.Unmarshal(rec.Body.Bytes(), &page)).To(Succeed())
+
+			request = httptest.NewRequest(http.MethodGet, "/users?sort=email&cursor="+page.NextCursor, nil)
+			c = e.NewContext(request, httptest.NewRecorder())
+			err := h.GetUsers(c)
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Describe("GetUser", func() {
+		It("should return the user with the given id", func() {
+			mockConnector.Sqlmock.ExpectQuery("SELECT user_id, user_name, first_name, last_name, email, user_status, department, version, deleted_at, status_reason, status_effective_date, created_at, created_by, updated_at, updated_by, manager_id FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").
+				WithArgs(int64(7)).
+				WillReturnRows(sqlmock.NewRows([]string{"user_id", "user_name", "first_name", "last_name", "email", "user_status", "department", "version", "deleted_at", "status_reason", "status_effective_date", "created_at", "created_by", "updated_at", "updated_by", "manager_id"}).
+					AddRow(7, "jdoe", "John", "Doe", "john.doe@example.com", "A", "Engineering", 1, nil, "", nil, time.Time{}, "", time.Time{}, "", nil))
+
+			request = httptest.NewRequest(http.MethodGet, "/users/7", nil)
+			c = e.NewContext(request, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("7")
+
+			Expect(h.GetUser(c)).To(Succeed())
+			Expect(rec.Code).To(Equal(http.StatusOK))
+
+			var user models.User
+			Expect(json.Unmarshal(rec.Body.Bytes(), &user)).To(Succeed())
+			Expect(user.UserID).To(Equal(int64(7)))
+			Expect(user.UserName).To(Equal("jdoe"))
+		})
+
+		It("should return not found for a missing user", func() {
+			mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").
+				WithArgs(int64(42)).
+				WillReturnRows(sqlmock.NewRows([]string{}))
+
+			request = httptest.NewRequest(http.MethodGet, "/users/42", nil)
+			c = e.NewContext(request, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("42")
+
+			err := h.GetUser(c)
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			httpErr := err.(*echo.HTTPError)
+			Expect(httpErr.Code).To(Equal(http.StatusNotFound))
+			Expect(httpErr.Message).To(Equal(handlers.ErrorResponse{Code: "user_not_found", Message: "no user with id 42"}))
+		})
+
+		It("should reject a non-numeric id", func() {
+			request = httptest.NewRequest(http.MethodGet, "/users/abc", nil)
+			c = e.NewContext(request, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("abc")
+
+			err := h.GetUser(c)
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Describe("GetUserByUsername", func() {
+		It("should return the user with the given user name", func() {
+			mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_name = \\$1 AND deleted_at IS NULL\\)").
+				WithArgs("jdoe").
+				WillReturnRows(sqlmock.NewRows([]string{"user_id", "user_name", "first_name", "last_name", "email", "user_status", "department", "version", "deleted_at", "status_reason", "status_effective_date", "created_at", "created_by", "updated_at", "updated_by", "manager_id"}).
+					AddRow(7, "jdoe", "John", "Doe", "john.doe@example.com", "A", "Engineering", 1, nil, "", nil, time.Time{}, "", time.Time{}, "", nil))
+
+			request = httptest.NewRequest(http.MethodGet, "/users/by-username/jdoe", nil)
+			c = e.NewContext(request, rec)
+			c.SetParamNames("user_name")
+			c.SetParamValues("jdoe")
+
+			Expect(h.GetUserByUsername(c)).To(Succeed())
+
+			var user models.User
+			Expect(json.Unmarshal(rec.Body.Bytes(), &user)).To(Succeed())
+			Expect(user.UserID).To(Equal(int64(7)))
+		})
+
+		It("should return not found for an unknown user name", func() {
+			mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_name = \\$1 AND deleted_at IS NULL\\)").
+				WithArgs("nobody").
+				WillReturnRows(sqlmock.NewRows([]string{}))
+
+			request = httptest.NewRequest(http.MethodGet, "/users/by-username/nobody", nil)
+			c = e.NewContext(request, rec)
+			c.SetParamNames("user_name")
+			c.SetParamValues("nobody")
+
+			err := h.GetUserByUsername(c)
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusNotFound))
 		})
 	})
 
@@ -195,18 +434,15 @@ var _ = Describe("User Handlers", func() {
 			request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 			c = e.NewContext(request, rec)
 
-			// Expect a query to check if the username already exists
-			mockConnector.Sqlmock.ExpectQuery("SELECT \\* FROM users WHERE user_name = \\$1").
-				WithArgs(user.UserName).
-				WillReturnRows(sqlmock.NewRows([]string{})) // No existing user
-
-			// Expect the insert query
-			mockConnector.Sqlmock.ExpectQuery("INSERT INTO users \\(user_name,first_name,last_name,email,user_status,department\\) VALUES \\(\\$1,\\$2,\\$3,\\$4,\\$5,\\$6\\) RETURNING user_id").
-				WithArgs(user.UserName, user.FirstName, user.LastName, user.Email, user.UserStatus, user.Department).
-				WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(int64(1)))
+			// Expect the insert query and its audit entry
+			expectAudited(func() {
+				mockConnector.Sqlmock.ExpectQuery("INSERT INTO users \\(user_name,first_name,last_name,email,user_status,department,manager_id,created_at,created_by,updated_at,updated_by\\) VALUES \\(\\$1,\\$2,\\$3,\\$4,\\$5,\\$6,\\$7,\\$8,\\$9,\\$10,\\$11\\) RETURNING user_id, version").
+					WithArgs(user.UserName, user.FirstName, user.LastName, user.Email, user.UserStatus, user.Department, nil, sqlmock.AnyArg(), "", sqlmock.AnyArg(), "").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "version"}).AddRow(int64(1), 1))
+			})
 
 			// Call CreateUser handler
-			err := handlers.CreateUser(c)
+			err := h.CreateUser(c)
 
 			// Expect no error and a StatusCreated
 			Expect(err).ToNot(HaveOccurred())
@@ -233,23 +469,208 @@ var _ = Describe("User Handlers", func() {
 			c.SetParamNames("id")
 			c.SetParamValues(strconv.FormatInt(createdUser.UserID, 10))
 
-			// Expect a query to check if the username already exists
-			mockConnector.Sqlmock.ExpectQuery("SELECT \\* FROM users WHERE user_name = \\$1").
-				WithArgs(updatedUser.UserName).
-				WillReturnRows(sqlmock.NewRows([]string{"user_id", "user_name", "first_name", "last_name", "email", "user_status", "department"}).
-					AddRow(int64(1), updatedUser.UserName, updatedUser.FirstName, updatedUser.LastName, updatedUser.Email, updatedUser.UserStatus, updatedUser.Department))
-
-			mockConnector.Sqlmock.ExpectExec("UPDATE users SET user_name = \\$1, first_name = \\$2, last_name = \\$3, email = \\$4, user_status = \\$5, department = \\$6 WHERE user_id = \\$7").
-				WithArgs(updatedUser.UserName, updatedUser.FirstName, updatedUser.LastName, updatedUser.Email, updatedUser.UserStatus, updatedUser.Department, strconv.FormatInt(createdUser.UserID, 10)).
-				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectAudited(func() {
+				expectStoredUser(createdUser.UserID, 1)
+				mockConnector.Sqlmock.ExpectQuery("UPDATE users SET user_name = \\$1, first_name = \\$2, last_name = \\$3, email = \\$4, user_status = \\$5, department = \\$6, manager_id = \\$7, updated_at = \\$8, updated_by = \\$9, version = version \\+ 1 WHERE user_id = \\$10 AND deleted_at IS NULL RETURNING version").
+					WithArgs(updatedUser.UserName, updatedUser.FirstName, updatedUser.LastName, updatedUser.Email, updatedUser.UserStatus, updatedUser.Department, nil, sqlmock.AnyArg(), "", createdUser.UserID).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+			}, createdUser.UserID, "", "", "update", `{"first_name":{"old":"New","new":"Updated"}}`, sqlmock.AnyArg(), strings.Repeat("0", 64), sqlmock.AnyArg())
 
-			err = handlers.UpdateUser(c)
+			err = h.UpdateUser(c)
 			Expect(err).To(BeNil())
 			Expect(rec.Code).To(Equal(http.StatusOK))
 
 			var userAfterUpdate models.User
 			json.Unmarshal(rec.Body.Bytes(), &userAfterUpdate)
 			Expect(userAfterUpdate.FirstName).To(Equal("Updated"))
+			Expect(rec.Header().Get("ETag")).To(Equal(`"1-2"`))
+		})
+
+		Context("with an existing user payload", func() {
+			var updatedUser models.User
+
+			BeforeEach(func() {
+				updatedUser = models.User{
+					UserName:   "renamed",
+					FirstName:  "Updated",
+					LastName:   "User",
+					Email:      "renamed@example.com",
+					UserStatus: "A",
+					Department: "Engineering",
+				}
+			})
+
+			newUpdateContext := func(id string, user models.User) echo.Context {
+				body, _ := json.Marshal(user)
+				request = httptest.NewRequest(http.MethodPut, "/users/"+id, bytes.NewReader(body))
+				request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				ctx := e.NewContext(request, rec)
+				ctx.SetParamNames("id")
+				ctx.SetParamValues(id)
+				return ctx
+			}
+
+			It("should take the id from the path when the body omits it", func() {
+				expectAudited(func() {
+					expectStoredUser(3, 1)
+					mockConnector.Sqlmock.ExpectQuery("UPDATE users SET (.+) WHERE user_id = \\$10").
+						WithArgs(updatedUser.UserName, updatedUser.FirstName, updatedUser.LastName, updatedUser.Email, updatedUser.UserStatus, updatedUser.Department, nil, sqlmock.AnyArg(), "", int64(3)).
+						WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+				})
+
+				Expect(h.UpdateUser(newUpdateContext("3", updatedUser))).To(Succeed())
+
+				var userAfterUpdate models.User
+				Expect(json.Unmarshal(rec.Body.Bytes(), &userAfterUpdate)).To(Succeed())
+				Expect(userAfterUpdate.UserID).To(Equal(int64(3)))
+			})
+
+			It("should reject a body id that differs from the path id", func() {
+				updatedUser.UserID = 4
+
+				err := h.UpdateUser(newUpdateContext("3", updatedUser))
+				Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+				Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusBadRequest))
+			})
+
+			It("should return not found when no row was updated", func() {
+				expectRolledBack(func() {
+					expectNoStoredUser(99)
+				})
+
+				err := h.UpdateUser(newUpdateContext("99", updatedUser))
+				Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+				Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusNotFound))
+			})
+
+			It("should return conflict when renaming onto a taken username", func() {
+				expectRolledBack(func() {
+					expectStoredUser(3, 1)
+					mockConnector.Sqlmock.ExpectQuery("UPDATE users SET (.+) WHERE user_id = \\$10").
+						WithArgs(updatedUser.UserName, updatedUser.FirstName, updatedUser.LastName, updatedUser.Email, updatedUser.UserStatus, updatedUser.Department, nil, sqlmock.AnyArg(), "", int64(3)).
+						WillReturnError(&pq.Error{Code: "23505", Constraint: "users_user_name_key"})
+				})
+
+				err := h.UpdateUser(newUpdateContext("3", updatedUser))
+				Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+				httpErr := err.(*echo.HTTPError)
+				Expect(httpErr.Code).To(Equal(http.StatusConflict))
+				Expect(httpErr.Message).To(Equal(handlers.ErrorResponse{Code: "user_name_taken", Message: "username already exists"}))
+			})
+
+			It("should not treat other database errors as conflicts", func() {
+				expectRolledBack(func() {
+					expectStoredUser(3, 1)
+					mockConnector.Sqlmock.ExpectQuery("UPDATE users SET (.+) WHERE user_id = \\$9").
+						WillReturnError(&pq.Error{Code: "23514"})
+				})
+
+				err := h.UpdateUser(newUpdateContext("3", updatedUser))
+				Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+				Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusInternalServerError))
+			})
+		})
+	})
+
+	Describe("PatchUser", func() {
+		expectExistingUser := func() {
+			mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").
+				WithArgs(int64(5)).
+				WillReturnRows(sqlmock.NewRows([]string{"user_id", "user_name", "first_name", "last_name", "email", "user_status", "department", "version", "deleted_at", "status_reason", "status_effective_date", "created_at", "created_by", "updated_at", "updated_by", "manager_id"}).
+					AddRow(5, "jdoe", "John", "Doe", "john.doe@example.com", "A", "Engineering", 1, nil, "", nil, time.Time{}, "", time.Time{}, "", nil))
+		}
+
+		newPatchContext := func(contentType, body string) echo.Context {
+			request = httptest.NewRequest(http.MethodPatch, "/users/5", bytes.NewReader([]byte(body)))
+			request.Header.Set(echo.HeaderContentType, contentType)
+			ctx := e.NewContext(request, rec)
+			ctx.SetParamNames("id")
+			ctx.SetParamValues("5")
+			return ctx
+		}
+
+		It("should only update the columns changed by a merge patch", func() {
+			expectExistingUser()
+			expectAudited(func() {
+				expectExistingUser()
+				mockConnector.Sqlmock.ExpectQuery("UPDATE users SET department = \\$1, updated_at = \\$2, updated_by = \\$3, version = version \\+ 1 WHERE user_id = \\$4 AND deleted_at IS NULL RETURNING version").
+					WithArgs("Sales", sqlmock.AnyArg(), "", int64(5)).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+			}, int64(5), "", "", "update", `{"department":{"old":"Engineering","new":"Sales"}}`, sqlmock.AnyArg(), strings.Repeat("0", 64), sqlmock.AnyArg())
+
+			err := h.PatchUser(newPatchContext(handlers.MIMEMergePatch, `{"department":"Sales"}`))
+			Expect(err).To(BeNil())
+			Expect(rec.Code).To(Equal(http.StatusOK))
+
+			var user models.User
+			Expect(json.Unmarshal(rec.Body.Bytes(), &user)).To(Succeed())
+			Expect(user.Department).To(Equal("Sales"))
+			Expect(user.UserName).To(Equal("jdoe"))
+			Expect(mockConnector.Sqlmock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("should apply a JSON patch", func() {
+			expectExistingUser()
+			expectAudited(func() {
+				expectExistingUser()
+				mockConnector.Sqlmock.ExpectQuery("UPDATE users SET first_name = \\$1, user_status = \\$2, status_reason = \\$3, status_effective_date = \\$4, updated_at = \\$5, updated_by = \\$6, version = version \\+ 1 WHERE user_id = \\$7 AND deleted_at IS NULL RETURNING version").
+					WithArgs("Johnny", "I", "", sqlmock.AnyArg(), sqlmock.AnyArg(), "", int64(5)).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+			})
+
+			body := `[{"op":"test","path":"/user_status","value":"A"},{"op":"replace","path":"/user_status","value":"I"},{"op":"replace","path":"/first_name","value":"Johnny"}]`
+			Expect(h.PatchUser(newPatchContext(handlers.MIMEJSONPatch, body))).To(Succeed())
+			Expect(mockConnector.Sqlmock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("should skip the update when nothing changes", func() {
+			expectExistingUser()
+
+			Expect(h.PatchUser(newPatchContext(handlers.MIMEMergePatch, `{"department":"Engineering"}`))).To(Succeed())
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(mockConnector.Sqlmock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("should validate the patched user", func() {
+			expectExistingUser()
+
+			err := h.PatchUser(newPatchContext(handlers.MIMEMergePatch, `{"user_status":"X"}`))
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should reject a failing JSON patch test operation", func() {
+			expectExistingUser()
+
+			err := h.PatchUser(newPatchContext(handlers.MIMEJSONPatch, `[{"op":"test","path":"/user_status","value":"T"}]`))
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should reject changing the user id", func() {
+			expectExistingUser()
+
+			err := h.PatchUser(newPatchContext(handlers.MIMEMergePatch, `{"user_id":6}`))
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should reject unsupported content types", func() {
+			expectExistingUser()
+
+			err := h.PatchUser(newPatchContext(echo.MIMEApplicationJSON, `{"department":"Sales"}`))
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusUnsupportedMediaType))
+		})
+
+		It("should return not found for a missing user", func() {
+			mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").
+				WithArgs(int64(5)).
+				WillReturnRows(sqlmock.NewRows([]string{}))
+
+			err := h.PatchUser(newPatchContext(handlers.MIMEMergePatch, `{"department":"Sales"}`))
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusNotFound))
 		})
 	})
 
@@ -268,18 +689,15 @@ var _ = Describe("User Handlers", func() {
 			request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 			c = e.NewContext(request, rec)
 
-			// Expect a query to check if the username already exists
-			mockConnector.Sqlmock.ExpectQuery("SELECT \\* FROM users WHERE user_name = \\$1").
-				WithArgs(user.UserName).
-				WillReturnRows(sqlmock.NewRows([]string{})) // No existing user
-
-			// Expect the insert query
-			mockConnector.Sqlmock.ExpectQuery("INSERT INTO users \\(user_name,first_name,last_name,email,user_status,department\\) VALUES \\(\\$1,\\$2,\\$3,\\$4,\\$5,\\$6\\) RETURNING user_id").
-				WithArgs(user.UserName, user.FirstName, user.LastName, user.Email, user.UserStatus, user.Department).
-				WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(int64(1)))
+			// Expect the insert query and its audit entry
+			expectAudited(func() {
+				mockConnector.Sqlmock.ExpectQuery("INSERT INTO users \\(user_name,first_name,last_name,email,user_status,department,manager_id,created_at,created_by,updated_at,updated_by\\) VALUES \\(\\$1,\\$2,\\$3,\\$4,\\$5,\\$6,\\$7,\\$8,\\$9,\\$10,\\$11\\) RETURNING user_id, version").
+					WithArgs(user.UserName, user.FirstName, user.LastName, user.Email, user.UserStatus, user.Department, nil, sqlmock.AnyArg(), "", sqlmock.AnyArg(), "").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "version"}).AddRow(int64(1), 1))
+			})
 
 			// Call CreateUser handler
-			err := handlers.CreateUser(c)
+			err := h.CreateUser(c)
 
 			// Expect no error and a StatusCreated
 			Expect(err).ToNot(HaveOccurred())
@@ -300,14 +718,174 @@ var _ = Describe("User Handlers", func() {
 			c.SetParamNames("id")
 			c.SetParamValues(strconv.FormatInt(createdUser.UserID, 10))
 
-			mockConnector.Sqlmock.ExpectExec("DELETE FROM users WHERE user_id = \\$1").
-				WithArgs(strconv.FormatInt(createdUser.UserID, 10)).
-				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectAudited(func() {
+				expectStoredUser(createdUser.UserID, 1)
+				mockConnector.Sqlmock.ExpectExec("UPDATE users SET deleted_at = \\$1, updated_at = \\$2, updated_by = \\$3, version = version \\+ 1 WHERE user_id = \\$4 AND deleted_at IS NULL").
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "", createdUser.UserID).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			}, createdUser.UserID, "", "", "delete", sqlmock.AnyArg(), sqlmock.AnyArg(), strings.Repeat("0", 64), sqlmock.AnyArg())
 
-			err = handlers.DeleteUser(c)
+			err = h.DeleteUser(c)
 			Expect(err).To(BeNil())
 			Expect(rec.Code).To(Equal(http.StatusOK))
 		})
+
+		It("should return not found when no row was deleted", func() {
+			request = httptest.NewRequest(http.MethodDelete, "/users/99", nil)
+			c = e.NewContext(request, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("99")
+
+			expectRolledBack(func() {
+				expectNoStoredUser(99)
+			})
+
+			err := h.DeleteUser(c)
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should reject a non-numeric id", func() {
+			request = httptest.NewRequest(http.MethodDelete, "/users/abc", nil)
+			c = e.NewContext(request, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("abc")
+
+			err := h.DeleteUser(c)
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusBadRequest))
+		})
 	})
 
+	Describe("Optimistic concurrency", func() {
+		userRow := func(version int) *sqlmock.Rows {
+			return sqlmock.NewRows([]string{"user_id", "user_name", "first_name", "last_name", "email", "user_status", "department", "version", "deleted_at", "status_reason", "status_effective_date", "created_at", "created_by", "updated_at", "updated_by", "manager_id"}).
+				AddRow(5, "jdoe", "John", "Doe", "john.doe@example.com", "A", "Engineering", version, nil, "", nil, time.Time{}, "", time.Time{}, "", nil)
+		}
+
+		newContext := func(method, body string, headers map[string]string) echo.Context {
+			request = httptest.NewRequest(method, "/users/5", bytes.NewReader([]byte(body)))
+			for k, v := range headers {
+				request.Header.Set(k, v)
+			}
+			ctx := e.NewContext(request, rec)
+			ctx.SetParamNames("id")
+			ctx.SetParamValues("5")
+			return ctx
+		}
+
+		putBody := `{"user_name":"jdoe","first_name":"John","last_name":"Doe","email":"john.doe@example.com","user_status":"I","department":"Engineering"}`
+
+		It("should emit an ETag when getting a user", func() {
+			mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").WillReturnRows(userRow(3))
+
+			Expect(h.GetUser(newContext(http.MethodGet, "", nil))).To(Succeed())
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Header().Get("ETag")).To(Equal(`"5-3"`))
+		})
+
+		It("should return not modified when If-None-Match matches", func() {
+			mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").WillReturnRows(userRow(3))
+
+			Expect(h.GetUser(newContext(http.MethodGet, "", map[string]string{"If-None-Match": `W/"5-3"`}))).To(Succeed())
+			Expect(rec.Code).To(Equal(http.StatusNotModified))
+			Expect(rec.Body.Len()).To(BeZero())
+		})
+
+		It("should return the user when If-None-Match is stale", func() {
+			mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").WillReturnRows(userRow(4))
+
+			Expect(h.GetUser(newContext(http.MethodGet, "", map[string]string{"If-None-Match": `"5-3"`}))).To(Succeed())
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Header().Get("ETag")).To(Equal(`"5-4"`))
+		})
+
+		It("should update when If-Match matches the current version", func() {
+			expectAudited(func() {
+				mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").WillReturnRows(userRow(3))
+				mockConnector.Sqlmock.ExpectQuery("UPDATE users SET (.+), version = version \\+ 1 WHERE user_id = \\$12 AND deleted_at IS NULL AND version IN \\(\\$13\\) RETURNING version").
+					WithArgs("jdoe", "John", "Doe", "john.doe@example.com", "I", "Engineering", nil, "", sqlmock.AnyArg(), sqlmock.AnyArg(), "", int64(5), int64(3)).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+			})
+
+			ctx := newContext(http.MethodPut, putBody, map[string]string{echo.HeaderContentType: echo.MIMEApplicationJSON, "If-Match": `"5-3"`})
+			Expect(h.UpdateUser(ctx)).To(Succeed())
+			Expect(rec.Header().Get("ETag")).To(Equal(`"5-4"`))
+		})
+
+		It("should return precondition failed when If-Match is stale", func() {
+			expectRolledBack(func() {
+				mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").WillReturnRows(userRow(3))
+				mockConnector.Sqlmock.ExpectQuery("UPDATE users SET (.+) WHERE user_id = \\$12 AND deleted_at IS NULL AND version IN \\(\\$13\\) RETURNING version").
+					WithArgs("jdoe", "John", "Doe", "john.doe@example.com", "I", "Engineering", nil, "", sqlmock.AnyArg(), sqlmock.AnyArg(), "", int64(5), int64(2)).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}))
+				mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").WillReturnRows(userRow(3))
+			})
+
+			ctx := newContext(http.MethodPut, putBody, map[string]string{echo.HeaderContentType: echo.MIMEApplicationJSON, "If-Match": `"5-2"`})
+			err := h.UpdateUser(ctx)
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusPreconditionFailed))
+		})
+
+		It("should never match a weak entity tag in If-Match", func() {
+			expectRolledBack(func() {
+				mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").WillReturnRows(userRow(3))
+				mockConnector.Sqlmock.ExpectQuery("UPDATE users SET (.+) WHERE user_id = \\$12 AND deleted_at IS NULL AND \\(1=0\\) RETURNING version").
+					WillReturnRows(sqlmock.NewRows([]string{"version"}))
+				mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").WillReturnRows(userRow(3))
+			})
+
+			ctx := newContext(http.MethodPut, putBody, map[string]string{echo.HeaderContentType: echo.MIMEApplicationJSON, "If-Match": `W/"5-3"`})
+			err := h.UpdateUser(ctx)
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusPreconditionFailed))
+		})
+
+		It("should reject a patch based on a stale version before writing", func() {
+			mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").WillReturnRows(userRow(3))
+
+			ctx := newContext(http.MethodPatch, `{"department":"Sales"}`, map[string]string{echo.HeaderContentType: handlers.MIMEMergePatch, "If-Match": `"5-2"`})
+			err := h.PatchUser(ctx)
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusPreconditionFailed))
+			Expect(mockConnector.Sqlmock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("should guard a conditional patch against concurrent writes", func() {
+			mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").WillReturnRows(userRow(3))
+			expectRolledBack(func() {
+				mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").WillReturnRows(userRow(4))
+				mockConnector.Sqlmock.ExpectQuery("UPDATE users SET department = \\$1, updated_at = \\$2, updated_by = \\$3, version = version \\+ 1 WHERE user_id = \\$4 AND deleted_at IS NULL AND version IN \\(\\$5\\) RETURNING version").
+					WithArgs("Sales", sqlmock.AnyArg(), "", int64(5), int64(3)).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}))
+				mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").WillReturnRows(userRow(4))
+			})
+
+			ctx := newContext(http.MethodPatch, `{"department":"Sales"}`, map[string]string{echo.HeaderContentType: handlers.MIMEMergePatch, "If-Match": `"5-3"`})
+			err := h.PatchUser(ctx)
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusPreconditionFailed))
+		})
+
+		It("should delete only the matching version", func() {
+			expectRolledBack(func() {
+				mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").WillReturnRows(userRow(3))
+				mockConnector.Sqlmock.ExpectExec("UPDATE users SET deleted_at = \\$1, updated_at = \\$2, updated_by = \\$3, version = version \\+ 1 WHERE user_id = \\$4 AND deleted_at IS NULL AND version IN \\(\\$5\\)").
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "", int64(5), int64(2)).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mockConnector.Sqlmock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").WillReturnRows(userRow(3))
+			})
+
+			err := h.DeleteUser(newContext(http.MethodDelete, "", map[string]string{"If-Match": `"5-2"`}))
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusPreconditionFailed))
+		})
+
+		It("should reject a malformed If-Match header", func() {
+			err := h.DeleteUser(newContext(http.MethodDelete, "", map[string]string{"If-Match": "5-2"}))
+			Expect(err).Should(BeAssignableToTypeOf(&echo.HTTPError{}))
+			Expect(err.(*echo.HTTPError).Code).To(Equal(http.StatusBadRequest))
+		})
+	})
 })
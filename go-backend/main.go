@@ -1,16 +1,41 @@
 package main
 
 import (
-	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
-	echoSwagger "github.com/swaggo/echo-swagger"
+	"fmt"
+	"log"
+	"os"
+	"strings"
 
 	"github.com/sedmo/integra-coding-assessment/go-backend/db"
 	"github.com/sedmo/integra-coding-assessment/go-backend/db/connectors"
 	_ "github.com/sedmo/integra-coding-assessment/go-backend/docs"
-	"github.com/sedmo/integra-coding-assessment/go-backend/handlers"
 )
 
+const usage = `Usage: main <command> [arguments]
+
+Commands:
+  serve [-addr :1323] [-schedule-interval 1m]
+                                    start the HTTP API (default) and apply
+                                    scheduled changes as they fall due
+  migrate up                        apply all pending migrations
+  migrate down -confirm             roll back every migration, deleting all data
+  migrate goto <version>            migrate up or down to a version
+  migrate version                   print the schema version and dirty state
+  migrate force <version>           set the schema version without migrating
+  seed                              insert the sample users
+  users import [-format f] [-skip-existing] <file>
+                                    import users from a JSON or CSV file
+  users export [-format f] [-o file]
+                                    export every user as JSON or CSV
+  users purge [-older-than 720h]    remove users deleted longer ago for good
+  roles list <user_name>            print the roles granted to a user
+  roles grant|revoke [-department d] <user_name> <role>
+                                    grant or revoke viewer, editor or admin
+  audit verify                      check that the audit log has not been altered
+  token [-ttl 1h] [-claim k=v] <subject>
+                                    print an HS256 token signed with JWT_SECRET
+`
+
 // @title Integra API
 // @version 1.0
 // @description This is a server for the integra coding assessment.
@@ -25,29 +50,51 @@ import (
 
 // @host localhost:1323
 // @BasePath /
-func main() {
-
-	e := echo.New()
-
-	e.Use(middleware.Logger())
-	e.Use(middleware.Recover())
 
-	// Enable CORS
-	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"http://localhost:4200"},
-		AllowMethods: []string{echo.GET, echo.POST, echo.PUT, echo.DELETE},
-	}))
-
-	e.GET("/swagger/*", echoSwagger.WrapHandler)
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT as "Bearer <token>"; see the token command.
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	pgConnector := &connectors.PostgresConnector{}
-	db.InitDB(pgConnector)
+func run(args []string) error {
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
 
-	// Routes
-	e.GET("/users", handlers.GetUsers)
-	e.POST("/users", handlers.CreateUser)
-	e.PUT("/users/:id", handlers.UpdateUser)
-	e.DELETE("/users/:id", handlers.DeleteUser)
+	switch command {
+	case "serve":
+		return serve(args)
+	case "migrate":
+		return migrateCommand(args)
+	case "seed":
+		return seedCommand(args)
+	case "users":
+		return usersCommand(args)
+	case "roles":
+		return rolesCommand(args)
+	case "token":
+		return tokenCommand(args)
+	case "audit":
+		return auditCommand(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q\n\n%s", command, usage)
+}
 
-	e.Logger.Fatal(e.Start(":1323"))
+// newConnector returns the connector for the database named by DATABASE_URL:
+// SQLite for sqlite:// URLs and Postgres otherwise.
+func newConnector() db.DBConnector {
+	dir := os.Getenv("DB_MIGRATIONS_DIR")
+	if strings.HasPrefix(os.Getenv("DATABASE_URL"), connectors.SQLiteScheme) {
+		return &connectors.SQLiteConnector{MigrationsDir: dir}
+	}
+	return &connectors.PostgresConnector{MigrationsDir: dir}
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/db"
+)
+
+func migrateCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down|goto <version>|version|force <version>")
+	}
+
+	connector := newConnector()
+	database, err := db.Connect(connector)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	sub, args := args[0], args[1:]
+	switch sub {
+	case "up":
+		return connector.RunMigrationsUp(database)
+
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ExitOnError)
+		confirm := flags.Bool("confirm", false, "confirm that every table and all data should be dropped")
+		flags.Parse(args)
+		if !*confirm {
+			return errors.New("migrate down drops every table; rerun with -confirm to proceed")
+		}
+		return connector.RunMigrationsDown(database)
+
+	case "goto":
+		if len(args) != 1 {
+			return errors.New("usage: migrate goto <version>")
+		}
+		version, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		return connector.MigrateTo(database, uint(version))
+
+	case "version":
+		version, dirty, err := connector.MigrationVersion(database)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			fmt.Printf("%d (dirty)\n", version)
+		} else {
+			fmt.Println(version)
+		}
+		return nil
+
+	case "force":
+		if len(args) != 1 {
+			return errors.New("usage: migrate force <version>")
+		}
+		version, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		return connector.ForceMigrationVersion(database, version)
+	}
+
+	return fmt.Errorf("unknown migrate command %q", sub)
+}
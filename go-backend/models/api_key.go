@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// APIKey lets a service call the API without a user token. Only a hash of
+// its secret is stored; Scopes lists the permissions it carries, such as
+// users:read.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	SecretHash string     `json:"-"`
+}
+
+// NewAPIKey is the body of a request to create an API key. The scopes are
+// the permissions of the user endpoints.
+type NewAPIKey struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=users:read users:create users:update users:terminate users:delete"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Validate validates the NewAPIKey fields.
+func (k *NewAPIKey) Validate() error {
+	return validate.Struct(k)
+}
+
+// CreatedAPIKey is the response to creating an API key. Key holds the full
+// secret, which is never shown again.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Operations recorded in the audit log.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	// AuditRestore records a soft deleted user being restored. Like a
+	// create, it lists every field as new.
+	AuditRestore = "restore"
+)
+
+// FieldChange is the value of a field before and after a change. Old is nil
+// for a created or restored user and New is nil for a deleted one.
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// AuditEntry records one change to a user: who made it, when, from which
+// request and what it changed, keyed by the JSON name of each field.
+//
+// Entries form a hash chain: Hash covers the contents of the entry and
+// PrevHash, the Hash of the entry before it, so that editing or deleting an
+// entry breaks the chain at the entry after it.
+type AuditEntry struct {
+	ID        int64                  `json:"id"`
+	UserID    int64                  `json:"user_id"`
+	Actor     string                 `json:"actor"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Operation string                 `json:"operation"`
+	Changes   map[string]FieldChange `json:"changes"`
+	At        time.Time              `json:"at"`
+	PrevHash  string                 `json:"prev_hash"`
+	Hash      string                 `json:"hash"`
+}
+
+// ComputeHash returns the hex SHA-256 of the contents of the entry and its
+// PrevHash. The id is left out since it is assigned after the hash is
+// computed; the order of the chain protects it instead.
+func (e *AuditEntry) ComputeHash() string {
+	// Fields are marshalled in declaration order and map keys sorted, so
+	// the encoding is the same wherever the entry was read from.
+	content, _ := json.Marshal(struct {
+		UserID    int64                  `json:"user_id"`
+		Actor     string                 `json:"actor"`
+		RequestID string                 `json:"request_id"`
+		Operation string                 `json:"operation"`
+		Changes   map[string]FieldChange `json:"changes"`
+		At        string                 `json:"at"`
+		PrevHash  string                 `json:"prev_hash"`
+	}{e.UserID, e.Actor, e.RequestID, e.Operation, e.Changes, e.At.UTC().Format(time.RFC3339Nano), e.PrevHash})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditVerification reports on a walk of the audit chain from its first
+// entry.
+type AuditVerification struct {
+	// Valid is set when every link checked holds.
+	Valid bool `json:"valid"`
+	// Entries is the number of entries checked.
+	Entries int64 `json:"entries"`
+	// Unsealed counts the entries recorded before the log was chained,
+	// which have no hash and can only precede the chain.
+	Unsealed int64 `json:"unsealed"`
+	// Head is the hash of the last entry, which must match the head
+	// anchored in the database. Deleting entries from the end of the log
+	// together with rewinding the anchor goes unnoticed, so compare it with
+	// a previously noted head to detect that.
+	Head string `json:"head,omitempty"`
+	// Broken is the first link found broken.
+	Broken *BrokenLink `json:"broken,omitempty"`
+}
+
+// BrokenLink is an audit entry that does not chain onto the entries before
+// it.
+type BrokenLink struct {
+	AuditID int64  `json:"audit_id"`
+	Reason  string `json:"reason"`
+}
+
+// AuditPage is one page of audit entries, newest first.
+type AuditPage struct {
+	Data   []AuditEntry `json:"data"`
+	Total  int64        `json:"total"`
+	Limit  uint64       `json:"limit"`
+	Offset uint64       `json:"offset"`
+}
+
+// NewAuditEntry returns the entry recording operation on a user, which was
+// before and became after; before is nil for a created user and after is nil
+// for a deleted one. Only the fields that differ are listed as changes.
+func NewAuditEntry(operation string, before, after *User) *AuditEntry {
+	entry := &AuditEntry{Operation: operation, Changes: map[string]FieldChange{}}
+	old, new := auditFields(before), auditFields(after)
+	for _, name := range auditedFields {
+		if before != nil && after != nil && old[name] == new[name] {
+			continue
+		}
+		change := FieldChange{}
+		if before != nil {
+			change.Old = old[name]
+			entry.UserID = before.UserID
+		}
+		if after != nil {
+			change.New = new[name]
+			entry.UserID = after.UserID
+		}
+		entry.Changes[name] = change
+	}
+	return entry
+}
+
+// auditedFields are the fields of a user whose changes are audited.
+var auditedFields = []string{"user_name", "first_name", "last_name", "email", "user_status", "department", "manager_id", "status_reason", "status_effective_date"}
+
+func auditFields(u *User) map[string]string {
+	if u == nil {
+		return nil
+	}
+	managerID := ""
+	if u.ManagerID != nil {
+		managerID = strconv.FormatInt(*u.ManagerID, 10)
+	}
+	return map[string]string{
+		"user_name":             u.UserName,
+		"first_name":            u.FirstName,
+		"last_name":             u.LastName,
+		"email":                 u.Email,
+		"user_status":           u.UserStatus,
+		"department":            u.Department,
+		"manager_id":            managerID,
+		"status_reason":         u.StatusReason,
+		"status_effective_date": u.StatusEffectiveDate,
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+// Department is a unit of the organisation that users belong to. Users refer
+// to their department by name, so renaming a department moves its users,
+// grants and scheduled changes along with it.
+type Department struct {
+	ID         int64  `json:"id" readonly:"true"`
+	Code       string `json:"code" validate:"required,max=50" example:"ENG"`
+	Name       string `json:"name" validate:"required,max=255" example:"Engineering"`
+	ParentID   *int64 `json:"parent_id,omitempty"`
+	CostCenter string `json:"cost_center,omitempty" validate:"max=50" example:"CC-1000"`
+}
+
+// Validate validates the Department fields.
+func (d *Department) Validate() error {
+	return validate.Struct(d)
+}
@@ -0,0 +1,12 @@
+package models
+
+// OrgChartNode is a user in an org chart together with the users reporting
+// to them, ordered by user id.
+type OrgChartNode struct {
+	UserID     int64          `json:"user_id" example:"1"`
+	UserName   string         `json:"user_name" example:"jdoe"`
+	FirstName  string         `json:"first_name" example:"John"`
+	LastName   string         `json:"last_name" example:"Doe"`
+	Department string         `json:"department" example:"Engineering"`
+	Reports    []OrgChartNode `json:"reports"`
+}
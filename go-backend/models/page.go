@@ -0,0 +1,19 @@
+package models
+
+// PageLinks holds the relative URLs of the neighbouring pages of a listing.
+type PageLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// UserPage is one page of users along with the total number of matching rows.
+type UserPage struct {
+	Data       []User    `json:"data"`
+	Total      int64     `json:"total"`
+	Limit      uint64    `json:"limit"`
+	Offset     uint64    `json:"offset"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	PrevCursor string    `json:"prev_cursor,omitempty"`
+	Links      PageLinks `json:"links"`
+}
@@ -0,0 +1,22 @@
+package models
+
+// Role is a named set of permissions that can be granted to users. The
+// permissions come from the API's permission matrix rather than the database.
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// Grant gives a user a role, either everywhere or, when Department is set,
+// only over the users of that department.
+type Grant struct {
+	Role       string `json:"role"`
+	Department string `json:"department,omitempty"`
+}
+
+// UserRoles lists the roles granted to a user.
+type UserRoles struct {
+	UserID int64   `json:"user_id"`
+	Roles  []Grant `json:"roles"`
+}
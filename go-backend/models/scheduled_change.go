@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// States of a scheduled change. A change is pending until it is applied,
+// cancelled or, when it is due but cannot be applied, failed.
+const (
+	ChangePending   = "pending"
+	ChangeApplied   = "applied"
+	ChangeCancelled = "cancelled"
+	ChangeFailed    = "failed"
+)
+
+// ScheduledChange is a change of a user's status, department or both that
+// takes effect at EffectiveAt. A status change is checked against the status
+// state machine when it is applied, not when it is scheduled.
+type ScheduledChange struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+	// UserStatus and Department are the values the user gets; an empty one
+	// is left unchanged.
+	UserStatus  string    `json:"user_status,omitempty"`
+	Department  string    `json:"department,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	EffectiveAt time.Time `json:"effective_at"`
+	State       string    `json:"state"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	// ResolvedAt is when the change was applied, cancelled or failed and
+	// ResolvedBy who cancelled it. Error says why a failed change failed.
+	ResolvedBy string     `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// NewScheduledChange is the body of a request to schedule a change. It must
+// change the status, the department or both.
+type NewScheduledChange struct {
+	UserStatus  string    `json:"user_status" validate:"required_without=Department,omitempty,oneof=A I T"`
+	Department  string    `json:"department" validate:"required_without=UserStatus,max=255"`
+	Reason      string    `json:"reason" validate:"max=255"`
+	EffectiveAt time.Time `json:"effective_at" validate:"required"`
+}
+
+// Validate validates the NewScheduledChange fields.
+func (c *NewScheduledChange) Validate() error {
+	return validate.Struct(c)
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Statuses of a user.
+const (
+	StatusActive     = "A"
+	StatusInactive   = "I"
+	StatusTerminated = "T"
+)
+
+// DateLayout is the format of dates such as the effective date of a status
+// change.
+const DateLayout = "2006-01-02"
+
+var statusNames = map[string]string{
+	StatusActive:     "active",
+	StatusInactive:   "inactive",
+	StatusTerminated: "terminated",
+}
+
+// statusTransitions lists the statuses a user may move to from each status.
+// Terminated is final: a terminated user cannot be brought back.
+var statusTransitions = map[string][]string{
+	StatusActive:     {StatusInactive, StatusTerminated},
+	StatusInactive:   {StatusActive, StatusTerminated},
+	StatusTerminated: {},
+}
+
+// TransitionError reports a change of user_status that the status state
+// machine does not allow.
+type TransitionError struct {
+	From, To string
+}
+
+func (e *TransitionError) Error() string {
+	if e.From == e.To {
+		return fmt.Sprintf("user is already %s", statusNames[e.From])
+	}
+	return fmt.Sprintf("user_status cannot change from %s (%s) to %s (%s)", statusNames[e.From], e.From, statusNames[e.To], e.To)
+}
+
+// CheckTransition returns a *TransitionError unless a user may move from
+// status from to status to. Staying in the same status is not a transition.
+func CheckTransition(from, to string) error {
+	if !slices.Contains(statusTransitions[from], to) {
+		return &TransitionError{From: from, To: to}
+	}
+	return nil
+}
+
+// StatusChange is the reason and effective date recorded with a change of
+// user_status.
+type StatusChange struct {
+	Reason string `json:"reason" validate:"max=255"`
+	// EffectiveDate is the day the change took effect, today if empty. A
+	// later day schedules the change for the start of that day in UTC.
+	EffectiveDate string `json:"effective_date,omitempty" validate:"omitempty,datetime=2006-01-02" example:"2024-06-30"`
+}
+
+// Validate validates the StatusChange fields.
+func (s *StatusChange) Validate() error {
+	return validate.Struct(s)
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+)
+
+// ValidationFailedKey is the translation key of the summary of a failed
+// validation, e.g. "one or more fields are invalid".
+const ValidationFailedKey = "validation_failed"
+
+type translation struct {
+	locale   locales.Translator
+	register func(*validator.Validate, ut.Translator) error
+	// validationFailed is the translation of ValidationFailedKey.
+	validationFailed string
+}
+
+var translations = []translation{
+	{en.New(), en_translations.RegisterDefaultTranslations, "one or more fields are invalid"},
+	{fr.New(), fr_translations.RegisterDefaultTranslations, "un ou plusieurs champs sont invalides"},
+	{es.New(), es_translations.RegisterDefaultTranslations, "uno o más campos no son válidos"},
+}
+
+var translators = newTranslators()
+
+// newTranslators registers the validator's messages for every supported
+// locale. English is the fallback for clients that accept none of them.
+func newTranslators() *ut.UniversalTranslator {
+	uni := ut.New(translations[0].locale)
+	for _, t := range translations {
+		if err := uni.AddTranslator(t.locale, true); err != nil {
+			panic(err)
+		}
+		trans, _ := uni.GetTranslator(t.locale.Locale())
+		if err := t.register(validate, trans); err != nil {
+			panic(err)
+		}
+		if err := trans.Add(ValidationFailedKey, t.validationFailed, true); err != nil {
+			panic(err)
+		}
+	}
+	return uni
+}
+
+// Translator returns the translator of the first supported locale in
+// preferred, such as "fr" or "es", or the English one if none is supported.
+// Use it with validator.FieldError's Translate method to describe a failed
+// validation in the client's language.
+func Translator(preferred ...string) ut.Translator {
+	trans, _ := translators.FindTranslator(preferred...)
+	return trans
+}
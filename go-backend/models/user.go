@@ -1,13 +1,27 @@
 package models
 
 import (
+	"reflect"
+	"strings"
+	"time"
+
 	"github.com/go-playground/validator/v10"
 )
 
-var validate *validator.Validate
+var validate = newValidator()
 
-func init() {
-	validate = validator.New()
+func newValidator() *validator.Validate {
+	validate := validator.New()
+	// Report fields by their JSON names so that clients can map errors back
+	// to the members they sent.
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return validate
 }
 
 // User represents a user in the system
@@ -18,7 +32,27 @@ type User struct {
 	LastName   string `json:"last_name" validate:"required,max=50"`
 	Email      string `json:"email" validate:"required,max=100,email"`
 	UserStatus string `json:"user_status" validate:"required,oneof=A I T"`
-	Department string `json:"department" validate:"required,max=50"`
+	Department string `json:"department" validate:"required,max=255"`
+	// ManagerID is the id of the user this user reports to, if any.
+	ManagerID *int64 `json:"manager_id,omitempty" example:"1"`
+	// Version is incremented on every write and is surfaced to clients
+	// through the ETag header rather than the body.
+	Version int64 `json:"-"`
+	// DeletedAt is set on soft deleted users, which are only listed on
+	// request. It cannot be written by clients.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" readonly:"true"`
+	// StatusReason and StatusEffectiveDate record why and from which day
+	// the user has its UserStatus. They are set by the status actions and
+	// cannot be written by clients.
+	StatusReason        string `json:"status_reason,omitempty" readonly:"true"`
+	StatusEffectiveDate string `json:"status_effective_date,omitempty" readonly:"true" example:"2024-06-30"`
+	// CreatedAt and CreatedBy record when and by whom the user was created,
+	// UpdatedAt and UpdatedBy when and by whom it was last written. They
+	// are kept by the repository and cannot be written by clients.
+	CreatedAt time.Time `json:"created_at" readonly:"true"`
+	CreatedBy string    `json:"created_by,omitempty" readonly:"true"`
+	UpdatedAt time.Time `json:"updated_at" readonly:"true"`
+	UpdatedBy string    `json:"updated_by,omitempty" readonly:"true"`
 }
 
 // Validate validates the User fields.
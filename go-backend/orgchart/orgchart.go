@@ -0,0 +1,165 @@
+// Package orgchart arranges users into the trees formed by their reporting
+// lines and writes them as JSON, Graphviz DOT or Mermaid.
+package orgchart
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+// Format is an output format for an org chart.
+type Format string
+
+const (
+	JSON    Format = "json"
+	DOT     Format = "dot"
+	Mermaid Format = "mermaid"
+)
+
+// ParseFormat validates a format name given by a client.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case JSON, DOT, Mermaid:
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported format %q, expected json, dot or mermaid", name)
+}
+
+// ContentType is the media type of a chart written in f.
+func (f Format) ContentType() string {
+	switch f {
+	case DOT:
+		return "text/vnd.graphviz; charset=utf-8"
+	case Mermaid:
+		return "text/vnd.mermaid; charset=utf-8"
+	}
+	return "application/json; charset=utf-8"
+}
+
+// Build arranges users into trees. A user whose manager is not among users,
+// because they have none or the manager was left out, roots a tree of their
+// own. Roots and reports are ordered by user id.
+func Build(users []models.User) []models.OrgChartNode {
+	users = slices.Clone(users)
+	slices.SortFunc(users, func(a, b models.User) int {
+		return cmp.Compare(a.UserID, b.UserID)
+	})
+
+	known := make(map[int64]bool, len(users))
+	for _, u := range users {
+		known[u.UserID] = true
+	}
+	reports := make(map[int64][]models.User)
+	var roots []models.User
+	for _, u := range users {
+		if u.ManagerID != nil && known[*u.ManagerID] {
+			reports[*u.ManagerID] = append(reports[*u.ManagerID], u)
+		} else {
+			roots = append(roots, u)
+		}
+	}
+
+	var build func(u models.User) models.OrgChartNode
+	build = func(u models.User) models.OrgChartNode {
+		node := models.OrgChartNode{
+			UserID:     u.UserID,
+			UserName:   u.UserName,
+			FirstName:  u.FirstName,
+			LastName:   u.LastName,
+			Department: u.Department,
+			Reports:    []models.OrgChartNode{},
+		}
+		for _, r := range reports[u.UserID] {
+			node.Reports = append(node.Reports, build(r))
+		}
+		return node
+	}
+	nodes := make([]models.OrgChartNode, 0, len(roots))
+	for _, u := range roots {
+		nodes = append(nodes, build(u))
+	}
+	return nodes
+}
+
+// Write writes the trees to w in format f.
+func Write(w io.Writer, f Format, chart []models.OrgChartNode) error {
+	switch f {
+	case JSON:
+		return json.NewEncoder(w).Encode(chart)
+	case DOT:
+		return writeDOT(w, chart)
+	case Mermaid:
+		return writeMermaid(w, chart)
+	}
+	return fmt.Errorf("unsupported format %q", f)
+}
+
+// label is the text shown for a user in the drawn formats.
+func label(n models.OrgChartNode) string {
+	return fmt.Sprintf("%s %s (%s)\n%s", n.FirstName, n.LastName, n.UserName, n.Department)
+}
+
+func writeDOT(w io.Writer, chart []models.OrgChartNode) error {
+	var b strings.Builder
+	b.WriteString("digraph org_chart {\n")
+	b.WriteString("\tnode [shape=box];\n")
+	walk(chart, func(n models.OrgChartNode) {
+		fmt.Fprintf(&b, "\tu%d [label=%s];\n", n.UserID, dotQuote(label(n)))
+	}, func(manager, report models.OrgChartNode) {
+		fmt.Fprintf(&b, "\tu%d -> u%d;\n", manager.UserID, report.UserID)
+	})
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote quotes s as a DOT string, keeping line breaks as \n escapes.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", "")
+	return `"` + r.Replace(s) + `"`
+}
+
+func writeMermaid(w io.Writer, chart []models.OrgChartNode) error {
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	walk(chart, func(n models.OrgChartNode) {
+		fmt.Fprintf(&b, "    u%d[\"%s\"]\n", n.UserID, mermaidEscape(label(n)))
+	}, func(manager, report models.OrgChartNode) {
+		fmt.Fprintf(&b, "    u%d --> u%d\n", manager.UserID, report.UserID)
+	})
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidEscape escapes s for a quoted Mermaid node label, where quotes and
+// markup characters are written as entity codes and line breaks as <br/>.
+func mermaidEscape(s string) string {
+	r := strings.NewReplacer(
+		`#`, `#35;`,
+		`"`, `#quot;`,
+		`<`, `#lt;`,
+		`>`, `#gt;`,
+		"\r", "",
+		"\n", `<br/>`,
+	)
+	return r.Replace(s)
+}
+
+// walk visits every node of chart depth first, calling node for each node
+// before edge for each of its reporting lines, so nodes are declared before
+// they are linked.
+func walk(chart []models.OrgChartNode, node func(models.OrgChartNode), edge func(manager, report models.OrgChartNode)) {
+	for _, n := range chart {
+		node(n)
+		walk(n.Reports, node, edge)
+		for _, r := range n.Reports {
+			edge(n, r)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package orgchart_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/orgchart"
+)
+
+func TestOrgChart(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "OrgChart Suite")
+}
+
+var _ = Describe("OrgChart", func() {
+	managedBy := func(id int64) *int64 { return &id }
+	users := []models.User{
+		{UserID: 3, UserName: "asmith", FirstName: "Alice", LastName: "Smith", Department: "Engineering", ManagerID: managedBy(1)},
+		{UserID: 1, UserName: "jdoe", FirstName: "John", LastName: "Doe", Department: "Management"},
+		{UserID: 2, UserName: "bwilson", FirstName: "Bob", LastName: `"Bobby" Wilson`, Department: "Sales", ManagerID: managedBy(1)},
+		{UserID: 4, UserName: "cfox", FirstName: "Carol", LastName: "Fox", Department: "Engineering", ManagerID: managedBy(3)},
+		{UserID: 5, UserName: "dlee", FirstName: "Dan", LastName: "Lee", Department: "HR", ManagerID: managedBy(9)},
+	}
+
+	write := func(format orgchart.Format) string {
+		var buf bytes.Buffer
+		Expect(orgchart.Write(&buf, format, orgchart.Build(users))).To(Succeed())
+		return buf.String()
+	}
+
+	It("should root a tree at each user whose manager is not charted", func() {
+		chart := orgchart.Build(users)
+		Expect(chart).To(HaveLen(2))
+		Expect(chart[0].UserName).To(Equal("jdoe"))
+		Expect(chart[1].UserName).To(Equal("dlee"))
+		Expect(chart[1].Reports).To(BeEmpty())
+
+		reports := chart[0].Reports
+		Expect(reports).To(HaveLen(2))
+		Expect(reports[0].UserName).To(Equal("bwilson"))
+		Expect(reports[1].UserName).To(Equal("asmith"))
+		Expect(reports[1].Reports).To(ConsistOf(HaveField("UserName", "cfox")))
+	})
+
+	It("should write the trees as JSON", func() {
+		var chart []models.OrgChartNode
+		Expect(json.Unmarshal([]byte(write(orgchart.JSON)), &chart)).To(Succeed())
+		Expect(chart).To(Equal(orgchart.Build(users)))
+	})
+
+	It("should write an empty JSON array when there are no users", func() {
+		var buf bytes.Buffer
+		Expect(orgchart.Write(&buf, orgchart.JSON, orgchart.Build(nil))).To(Succeed())
+		Expect(buf.String()).To(Equal("[]\n"))
+	})
+
+	It("should write a Graphviz digraph with escaped labels", func() {
+		dot := write(orgchart.DOT)
+		Expect(dot).To(HavePrefix("digraph org_chart {\n"))
+		Expect(dot).To(ContainSubstring(`u2 [label="Bob \"Bobby\" Wilson (bwilson)\nSales"];`))
+		Expect(dot).To(ContainSubstring("u1 -> u2;\n"))
+		Expect(dot).To(ContainSubstring("u3 -> u4;\n"))
+		Expect(dot).NotTo(ContainSubstring("-> u5"))
+		Expect(dot).To(HaveSuffix("}\n"))
+	})
+
+	It("should write a Mermaid flowchart with escaped labels", func() {
+		mermaid := write(orgchart.Mermaid)
+		Expect(mermaid).To(HavePrefix("flowchart TD\n"))
+		Expect(mermaid).To(ContainSubstring(`u2["Bob #quot;Bobby#quot; Wilson (bwilson)<br/>Sales"]`))
+		Expect(mermaid).To(ContainSubstring("u1 --> u3\n"))
+		Expect(mermaid).To(ContainSubstring("u3 --> u4\n"))
+	})
+
+	It("should accept format names in any case", func() {
+		Expect(orgchart.ParseFormat("DOT")).To(Equal(orgchart.DOT))
+		_, err := orgchart.ParseFormat("svg")
+		Expect(err).To(MatchError(ContainSubstring("unsupported format")))
+	})
+})
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+type actorKey struct{}
+
+// WithActor attributes the writes made with the returned context to actor,
+// who is recorded as the creator of the users it creates and as the last to
+// write the users it changes, deletes or restores.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// stamp returns the time of a write made now and the actor ctx attributes
+// it to, if any.
+func stamp(ctx context.Context) (time.Time, string) {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return time.Now().UTC().Truncate(time.Microsecond), actor
+}
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+// ErrAPIKeyNotFound is returned when no API key matches the requested key.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKeyRepository stores API keys. Keys are never deleted; revoking one
+// records when it stopped working.
+type APIKeyRepository interface {
+	// CreateAPIKey inserts key and stores the assigned id on it.
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	// APIKeys lists every key, revoked ones included, ordered by id.
+	APIKeys(ctx context.Context) ([]models.APIKey, error)
+	APIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	// RevokeAPIKey revokes key id at the given time and returns it. A key
+	// that is already revoked keeps its original revocation time.
+	RevokeAPIKey(ctx context.Context, id int64, at time.Time) (*models.APIKey, error)
+	// TouchAPIKey records that key id was used at the given time.
+	TouchAPIKey(ctx context.Context, id int64, at time.Time) error
+}
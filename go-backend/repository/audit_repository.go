@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+// AuditFilter selects a page of audit entries. Zero fields match every
+// entry.
+type AuditFilter struct {
+	UserID     int64
+	Actors     []string
+	Operations []string
+	// Since and Until bound the time of the entries; Since is inclusive and
+	// Until exclusive.
+	Since time.Time
+	Until time.Time
+	// Limit caps the number of entries returned; zero returns every entry.
+	Limit  uint64
+	Offset uint64
+}
+
+// AuditAnchor bounds the audit chain. The anchor is updated with every
+// entry appended, so a walk of the chain can tell entries missing from
+// either end.
+type AuditAnchor struct {
+	// UnsealedUntil is the id of the last entry recorded before the log was
+	// chained; every later entry must be chained.
+	UnsealedUntil int64
+	// Head is the hash of the last chained entry and Entries the number of
+	// chained entries.
+	Head    string
+	Entries int64
+}
+
+// AuditRepository stores the append-only log of changes to users. Entries
+// outlive the users they describe.
+type AuditRepository interface {
+	// RecordAudit appends entry, chaining it onto the last entry, and stores
+	// the assigned id, PrevHash and Hash on it. Appends are serialized so
+	// that two entries never chain onto the same one.
+	RecordAudit(ctx context.Context, entry *models.AuditEntry) error
+	// AuditLog returns one page of the entries matching filter, newest
+	// first, together with the number of entries matching it.
+	AuditLog(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, int64, error)
+	// AuditChain calls fn with every entry in id order, stopping at the
+	// first error fn returns.
+	AuditChain(ctx context.Context, fn func(models.AuditEntry) error) error
+	// AuditAnchor returns the bounds of the chain as of the last append.
+	AuditAnchor(ctx context.Context) (AuditAnchor, error)
+}
+
+// errStopWalk ends a walk of the audit chain early.
+var errStopWalk = errors.New("stop walking the audit chain")
+
+// VerifyAuditChain walks the audit chain of audit and reports the first
+// entry that does not chain onto the ones before it: one whose hash does not
+// match its contents, which was edited, or whose PrevHash does not match the
+// entry before it, which was edited or deleted. A chain that does not end at
+// the anchored head is reported at its last entry: entries after it were
+// deleted, or added without going through the repository.
+func VerifyAuditChain(ctx context.Context, audit AuditRepository) (*models.AuditVerification, error) {
+	anchor, err := audit.AuditAnchor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.AuditVerification{Valid: true}
+	prev := ""
+	var last int64
+	err = audit.AuditChain(ctx, func(entry models.AuditEntry) error {
+		report.Entries++
+		last = entry.ID
+		var reason string
+		switch {
+		case entry.Hash == "" && entry.ID <= anchor.UnsealedUntil:
+			report.Unsealed++
+			return nil
+		case entry.Hash == "":
+			reason = "entry has no hash"
+		case entry.PrevHash != prev:
+			reason = "prev_hash does not match the hash of the previous entry"
+		case entry.ComputeHash() != entry.Hash:
+			reason = "hash does not match the contents of the entry"
+		}
+		if reason != "" {
+			report.Valid = false
+			report.Broken = &models.BrokenLink{AuditID: entry.ID, Reason: reason}
+			return errStopWalk
+		}
+		prev = entry.Hash
+		report.Head = entry.Hash
+		return nil
+	})
+	if errors.Is(err, errStopWalk) {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Valid && (report.Head != anchor.Head || report.Entries-report.Unsealed != anchor.Entries) {
+		report.Valid = false
+		report.Broken = &models.BrokenLink{AuditID: last, Reason: "chain does not end at the anchored head"}
+	}
+	return report, nil
+}
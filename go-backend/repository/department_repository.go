@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+var (
+	// ErrDepartmentNotFound is returned when no department matches the
+	// requested id or name.
+	ErrDepartmentNotFound = errors.New("department not found")
+	// ErrUnknownDepartment is returned when a write refers to a department
+	// that does not exist: a user's department or a department's parent.
+	ErrUnknownDepartment = errors.New("department does not exist")
+	// ErrDepartmentCodeTaken and ErrDepartmentNameTaken are returned when a
+	// write would duplicate the code or the name of a department.
+	ErrDepartmentCodeTaken = errors.New("department code already exists")
+	ErrDepartmentNameTaken = errors.New("department name already exists")
+	// ErrInvalidDepartment is returned when a write breaks a column
+	// constraint of the departments table.
+	ErrInvalidDepartment = errors.New("department violates a column constraint")
+	// ErrDepartmentInUse is returned when deleting a department that users,
+	// deleted ones included, or other departments still belong to.
+	ErrDepartmentInUse = errors.New("department still has users or sub-departments")
+	// ErrDepartmentCycle is returned when a department would become its own
+	// ancestor.
+	ErrDepartmentCycle = errors.New("department cannot be its own ancestor")
+)
+
+// DepartmentRepository stores the departments users belong to. Users refer
+// to their department by name, and the department of every user written must
+// exist.
+type DepartmentRepository interface {
+	// Departments lists every department, ordered by name.
+	Departments(ctx context.Context) ([]models.Department, error)
+	Department(ctx context.Context, id int64) (*models.Department, error)
+	DepartmentByName(ctx context.Context, name string) (*models.Department, error)
+	// CreateDepartment inserts department and stores the assigned id on it.
+	CreateDepartment(ctx context.Context, department *models.Department) error
+	// UpdateDepartment writes every field of department to the department
+	// with its id. Renaming a department moves its users, recording the
+	// write on them as Update does, along with the role grants and pending
+	// scheduled changes made in it.
+	UpdateDepartment(ctx context.Context, department *models.Department) error
+	// DeleteDepartment deletes department id and the role grants made in
+	// it. It fails with ErrDepartmentInUse while users or other departments
+	// belong to it.
+	DeleteDepartment(ctx context.Context, id int64) error
+}
+
+// checkAncestry fails with ErrDepartmentCycle when department id would be
+// among the ancestors of parent, which lookup finds by id, and with
+// ErrUnknownDepartment when one of them does not exist.
+func checkAncestry(id int64, parent *int64, lookup func(int64) (*models.Department, error)) error {
+	seen := map[int64]bool{}
+	for parent != nil {
+		if *parent == id || seen[*parent] {
+			return ErrDepartmentCycle
+		}
+		seen[*parent] = true
+		ancestor, err := lookup(*parent)
+		if errors.Is(err, ErrDepartmentNotFound) {
+			return ErrUnknownDepartment
+		}
+		if err != nil {
+			return err
+		}
+		parent = ancestor.ParentID
+	}
+	return nil
+}
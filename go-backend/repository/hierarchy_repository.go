@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+var (
+	// ErrUnknownManager is returned when a write gives a user a manager who
+	// is not a user, or is a deleted one.
+	ErrUnknownManager = errors.New("manager does not exist")
+	// ErrManagerCycle is returned when a write would make a user report to
+	// themselves, directly or through their reports.
+	ErrManagerCycle = errors.New("user cannot report to themselves")
+)
+
+// HierarchyRepository reads the reporting lines between users, where each
+// user reports to the user in its ManagerID. Deleted users are left out, and
+// so are the lines that run through them. Users outside the departments of
+// ctx are left out too, but the lines through them are followed.
+type HierarchyRepository interface {
+	// ManagementChain lists the managers of user id, from its own manager up
+	// to the top of the hierarchy.
+	ManagementChain(ctx context.Context, id int64) ([]models.User, error)
+	// Subordinates lists the users who report to user id, directly or
+	// through others, ordered by user_id.
+	Subordinates(ctx context.Context, id int64) ([]models.User, error)
+}
+
+// managementChain walks up from user id through users, which holds it and
+// its managers by id, and returns the managers in the scope of ctx from its
+// own manager upwards. It stops at a manager missing from users, or at one
+// already seen should the data hold a cycle.
+func managementChain(ctx context.Context, id int64, users map[int64]models.User) []models.User {
+	chain := []models.User{}
+	seen := map[int64]bool{id: true}
+	next := users[id].ManagerID
+	for next != nil && !seen[*next] {
+		manager, ok := users[*next]
+		if !ok {
+			break
+		}
+		seen[*next] = true
+		if inScope(ctx, manager.Department) {
+			chain = append(chain, manager)
+		}
+		next = manager.ManagerID
+	}
+	return chain
+}
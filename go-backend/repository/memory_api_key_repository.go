@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+func (r *MemoryUserRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return r.write(func(s *memoryState) error {
+		for _, other := range s.keys {
+			if other.Prefix == key.Prefix {
+				return fmt.Errorf("api key prefix %q already exists", key.Prefix)
+			}
+		}
+		key.ID = atomic.AddInt64(r.nextKeyID, 1)
+		s.keys[key.ID] = copyAPIKey(*key)
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) APIKeys(ctx context.Context) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	err := r.read(func(s *memoryState) error {
+		for _, key := range s.keys {
+			keys = append(keys, copyAPIKey(key))
+		}
+		return nil
+	})
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, err
+}
+
+func (r *MemoryUserRepository) APIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	var found *models.APIKey
+	err := r.read(func(s *memoryState) error {
+		for _, key := range s.keys {
+			if key.Prefix == prefix {
+				key = copyAPIKey(key)
+				found = &key
+				return nil
+			}
+		}
+		return ErrAPIKeyNotFound
+	})
+	return found, err
+}
+
+func (r *MemoryUserRepository) RevokeAPIKey(ctx context.Context, id int64, at time.Time) (*models.APIKey, error) {
+	var revoked models.APIKey
+	err := r.write(func(s *memoryState) error {
+		key, ok := s.keys[id]
+		if !ok {
+			return ErrAPIKeyNotFound
+		}
+		if key.RevokedAt == nil {
+			key.RevokedAt = &at
+			s.keys[id] = key
+		}
+		revoked = copyAPIKey(key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &revoked, nil
+}
+
+func (r *MemoryUserRepository) TouchAPIKey(ctx context.Context, id int64, at time.Time) error {
+	return r.write(func(s *memoryState) error {
+		key, ok := s.keys[id]
+		if !ok {
+			return ErrAPIKeyNotFound
+		}
+		key.LastUsedAt = &at
+		s.keys[id] = key
+		return nil
+	})
+}
+
+// copyAPIKey returns a copy of key that shares no memory with it.
+func copyAPIKey(key models.APIKey) models.APIKey {
+	key.Scopes = append([]string(nil), key.Scopes...)
+	for _, t := range []**time.Time{&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt} {
+		if *t != nil {
+			copied := **t
+			*t = &copied
+		}
+	}
+	return key
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+func (r *MemoryUserRepository) RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	switch entry.Operation {
+	case models.AuditCreate, models.AuditUpdate, models.AuditDelete, models.AuditRestore:
+	default:
+		return fmt.Errorf("unknown audit operation %q", entry.Operation)
+	}
+	return r.write(func(s *memoryState) error {
+		entry.At = entry.At.UTC().Truncate(time.Microsecond)
+		entry.PrevHash = ""
+		if len(s.audit) > 0 {
+			entry.PrevHash = s.audit[len(s.audit)-1].Hash
+		}
+		entry.Hash = entry.ComputeHash()
+		entry.ID = atomic.AddInt64(r.nextAuditID, 1)
+		s.audit = append(s.audit, copyAuditEntry(*entry))
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) AuditLog(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, int64, error) {
+	var matched []models.AuditEntry
+	err := r.read(func(s *memoryState) error {
+		// Entries are appended in id order; walk them newest first.
+		for i := len(s.audit) - 1; i >= 0; i-- {
+			if matchesAudit(s.audit[i], filter) {
+				matched = append(matched, copyAuditEntry(s.audit[i]))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	total := int64(len(matched))
+
+	entries := []models.AuditEntry{}
+	if filter.Offset < uint64(len(matched)) {
+		entries = append(entries, matched[filter.Offset:]...)
+	}
+	if filter.Limit > 0 && uint64(len(entries)) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, total, nil
+}
+
+func (r *MemoryUserRepository) AuditChain(ctx context.Context, fn func(models.AuditEntry) error) error {
+	var entries []models.AuditEntry
+	err := r.read(func(s *memoryState) error {
+		entries = slices.Clip(s.audit)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := fn(copyAuditEntry(entry)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AuditAnchor derives the anchor from the log itself, which only this
+// repository can change.
+func (r *MemoryUserRepository) AuditAnchor(ctx context.Context) (AuditAnchor, error) {
+	var anchor AuditAnchor
+	err := r.read(func(s *memoryState) error {
+		anchor.Entries = int64(len(s.audit))
+		if len(s.audit) > 0 {
+			anchor.Head = s.audit[len(s.audit)-1].Hash
+		}
+		return nil
+	})
+	return anchor, err
+}
+
+func matchesAudit(entry models.AuditEntry, filter AuditFilter) bool {
+	switch {
+	case filter.UserID != 0 && entry.UserID != filter.UserID:
+		return false
+	case len(filter.Actors) > 0 && !slices.Contains(filter.Actors, entry.Actor):
+		return false
+	case len(filter.Operations) > 0 && !slices.Contains(filter.Operations, entry.Operation):
+		return false
+	case !filter.Since.IsZero() && entry.At.Before(filter.Since):
+		return false
+	case !filter.Until.IsZero() && !entry.At.Before(filter.Until):
+		return false
+	}
+	return true
+}
+
+// copyAuditEntry returns a copy of entry that shares no memory with it.
+func copyAuditEntry(entry models.AuditEntry) models.AuditEntry {
+	changes := make(map[string]models.FieldChange, len(entry.Changes))
+	for name, change := range entry.Changes {
+		changes[name] = change
+	}
+	entry.Changes = changes
+	return entry
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+// departmentLimits mirrors the VARCHAR lengths of the departments table.
+var departmentLimits = map[string]int{
+	"code":        50,
+	"name":        255,
+	"cost_center": 50,
+}
+
+func (r *MemoryUserRepository) Departments(ctx context.Context) ([]models.Department, error) {
+	departments := []models.Department{}
+	err := r.read(func(s *memoryState) error {
+		for _, department := range s.departments {
+			departments = append(departments, copyDepartment(department))
+		}
+		return nil
+	})
+	sort.Slice(departments, func(i, j int) bool { return departments[i].Name < departments[j].Name })
+	return departments, err
+}
+
+func (r *MemoryUserRepository) Department(ctx context.Context, id int64) (*models.Department, error) {
+	var found *models.Department
+	err := r.read(func(s *memoryState) error {
+		department, err := s.department(id)
+		found = department
+		return err
+	})
+	return found, err
+}
+
+func (r *MemoryUserRepository) DepartmentByName(ctx context.Context, name string) (*models.Department, error) {
+	var found *models.Department
+	err := r.read(func(s *memoryState) error {
+		department, ok := s.departmentNamed(name)
+		if !ok {
+			return ErrDepartmentNotFound
+		}
+		found = &department
+		return nil
+	})
+	return found, err
+}
+
+func (r *MemoryUserRepository) CreateDepartment(ctx context.Context, department *models.Department) error {
+	if err := checkDepartment(department); err != nil {
+		return err
+	}
+	return r.write(func(s *memoryState) error {
+		id := atomic.AddInt64(r.nextDepartmentID, 1)
+		if err := s.checkDepartmentKeys(id, department); err != nil {
+			return err
+		}
+		department.ID = id
+		s.departments[id] = copyDepartment(*department)
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) UpdateDepartment(ctx context.Context, department *models.Department) error {
+	if err := checkDepartment(department); err != nil {
+		return err
+	}
+	return r.write(func(s *memoryState) error {
+		stored, err := s.department(department.ID)
+		if err != nil {
+			return err
+		}
+		if err := s.checkDepartmentKeys(department.ID, department); err != nil {
+			return err
+		}
+		s.departments[department.ID] = copyDepartment(*department)
+		if stored.Name == department.Name {
+			return nil
+		}
+
+		now, actor := stamp(ctx)
+		for id, user := range s.users {
+			if user.Department == stored.Name {
+				user.Department = department.Name
+				user.UpdatedAt, user.UpdatedBy = now, actor
+				user.Version++
+				s.users[id] = user
+			}
+		}
+		for _, grants := range s.grants {
+			for grant := range grants {
+				if grant.Department == stored.Name {
+					delete(grants, grant)
+					grant.Department = department.Name
+					grants[grant] = true
+				}
+			}
+		}
+		for id, change := range s.changes {
+			if change.Department == stored.Name && change.State == models.ChangePending {
+				change.Department = department.Name
+				s.changes[id] = change
+			}
+		}
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) DeleteDepartment(ctx context.Context, id int64) error {
+	return r.write(func(s *memoryState) error {
+		stored, err := s.department(id)
+		if err != nil {
+			return err
+		}
+		for _, user := range s.users {
+			if user.Department == stored.Name {
+				return ErrDepartmentInUse
+			}
+		}
+		for _, department := range s.departments {
+			if department.ParentID != nil && *department.ParentID == id {
+				return ErrDepartmentInUse
+			}
+		}
+		delete(s.departments, id)
+		for _, grants := range s.grants {
+			for grant := range grants {
+				if grant.Department == stored.Name {
+					delete(grants, grant)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// department returns a copy of department id.
+func (s *memoryState) department(id int64) (*models.Department, error) {
+	department, ok := s.departments[id]
+	if !ok {
+		return nil, ErrDepartmentNotFound
+	}
+	department = copyDepartment(department)
+	return &department, nil
+}
+
+// departmentNamed returns the department named name.
+func (s *memoryState) departmentNamed(name string) (models.Department, bool) {
+	for _, department := range s.departments {
+		if department.Name == name {
+			return copyDepartment(department), true
+		}
+	}
+	return models.Department{}, false
+}
+
+// checkDepartmentKeys enforces the unique and foreign keys of the
+// departments table for department stored with id.
+func (s *memoryState) checkDepartmentKeys(id int64, department *models.Department) error {
+	for otherID, other := range s.departments {
+		if otherID == id {
+			continue
+		}
+		if other.Code == department.Code {
+			return ErrDepartmentCodeTaken
+		}
+		if other.Name == department.Name {
+			return ErrDepartmentNameTaken
+		}
+	}
+	return checkAncestry(id, department.ParentID, s.department)
+}
+
+// checkUserDepartment enforces the foreign key from users to departments.
+func (s *memoryState) checkUserDepartment(user *models.User) error {
+	if _, ok := s.departmentNamed(user.Department); !ok {
+		return ErrUnknownDepartment
+	}
+	return nil
+}
+
+// checkDepartment enforces the column constraints of the departments table.
+func checkDepartment(department *models.Department) error {
+	values := map[string]string{"code": department.Code, "name": department.Name, "cost_center": department.CostCenter}
+	for column, limit := range departmentLimits {
+		if n := utf8.RuneCountInString(values[column]); n > limit {
+			return fmt.Errorf("%w: %s is longer than %d characters", ErrInvalidDepartment, column, limit)
+		}
+	}
+	if department.Code == "" || department.Name == "" {
+		return fmt.Errorf("%w: code and name cannot be empty", ErrInvalidDepartment)
+	}
+	return nil
+}
+
+// copyDepartment returns a copy of department that shares no memory with it.
+func copyDepartment(department models.Department) models.Department {
+	if department.ParentID != nil {
+		parentID := *department.ParentID
+		department.ParentID = &parentID
+	}
+	return department
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+func (r *MemoryUserRepository) ManagementChain(ctx context.Context, id int64) ([]models.User, error) {
+	var chain []models.User
+	err := r.read(func(s *memoryState) error {
+		chain = managementChain(ctx, id, s.liveUsers())
+		return nil
+	})
+	return chain, err
+}
+
+func (r *MemoryUserRepository) Subordinates(ctx context.Context, id int64) ([]models.User, error) {
+	subordinates := []models.User{}
+	err := r.read(func(s *memoryState) error {
+		reports := map[int64][]models.User{}
+		for _, user := range s.liveUsers() {
+			if user.ManagerID != nil {
+				reports[*user.ManagerID] = append(reports[*user.ManagerID], user)
+			}
+		}
+		seen := map[int64]bool{id: true}
+		queue := []int64{id}
+		for len(queue) > 0 {
+			for _, user := range reports[queue[0]] {
+				if seen[user.UserID] {
+					continue
+				}
+				seen[user.UserID] = true
+				queue = append(queue, user.UserID)
+				if inScope(ctx, user.Department) {
+					subordinates = append(subordinates, user)
+				}
+			}
+			queue = queue[1:]
+		}
+		return nil
+	})
+	sort.Slice(subordinates, func(i, j int) bool { return subordinates[i].UserID < subordinates[j].UserID })
+	return subordinates, err
+}
+
+// liveUsers returns the users that are not deleted by id.
+func (s *memoryState) liveUsers() map[int64]models.User {
+	live := make(map[int64]models.User, len(s.users))
+	for id, user := range s.users {
+		if user.DeletedAt == nil {
+			live[id] = user
+		}
+	}
+	return live
+}
+
+// checkManager enforces the foreign key of users.manager_id for user id,
+// which is zero for a user yet to be created, and keeps it from reporting to
+// itself. A user can always keep its manager, even one deleted since.
+func (s *memoryState) checkManager(id int64, manager *int64) error {
+	if manager == nil {
+		return nil
+	}
+	if *manager == id {
+		return ErrManagerCycle
+	}
+	if stored, ok := s.users[id]; ok && stored.ManagerID != nil && *stored.ManagerID == *manager {
+		return nil
+	}
+	if user, ok := s.users[*manager]; !ok || user.DeletedAt != nil {
+		return ErrUnknownManager
+	}
+
+	// Deleted users are followed too, as restoring them brings their lines
+	// back.
+	seen := map[int64]bool{}
+	for next := s.users[*manager].ManagerID; next != nil && !seen[*next]; next = s.users[*next].ManagerID {
+		if *next == id {
+			return ErrManagerCycle
+		}
+		seen[*next] = true
+	}
+	return nil
+}
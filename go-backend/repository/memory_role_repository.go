@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+func (r *MemoryUserRepository) Roles(ctx context.Context) ([]models.Role, error) {
+	return append([]models.Role(nil), DefaultRoles...), nil
+}
+
+func (r *MemoryUserRepository) UserRoles(ctx context.Context, userID int64) ([]models.Grant, error) {
+	grants := []models.Grant{}
+	err := r.read(func(s *memoryState) error {
+		if _, err := s.get(ctx, userID); err != nil {
+			return err
+		}
+		for grant := range s.grants[userID] {
+			grants = append(grants, grant)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(grants, func(i, j int) bool {
+		if grants[i].Role != grants[j].Role {
+			return grants[i].Role < grants[j].Role
+		}
+		return grants[i].Department < grants[j].Department
+	})
+	return grants, nil
+}
+
+func (r *MemoryUserRepository) GrantRole(ctx context.Context, userID int64, grant models.Grant) error {
+	return r.write(func(s *memoryState) error {
+		if err := s.checkGrant(ctx, userID, grant); err != nil {
+			return err
+		}
+		if s.grants[userID] == nil {
+			s.grants[userID] = map[models.Grant]bool{}
+		}
+		s.grants[userID][grant] = true
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) RevokeRole(ctx context.Context, userID int64, grant models.Grant) error {
+	return r.write(func(s *memoryState) error {
+		if err := s.checkGrant(ctx, userID, grant); err != nil {
+			return err
+		}
+		delete(s.grants[userID], grant)
+		return nil
+	})
+}
+
+// checkGrant checks that user id exists and that the role of grant is one of
+// DefaultRoles.
+func (s *memoryState) checkGrant(ctx context.Context, userID int64, grant models.Grant) error {
+	if _, err := s.get(ctx, userID); err != nil {
+		return err
+	}
+	if utf8.RuneCountInString(grant.Department) > columnLimits["department"] {
+		return fmt.Errorf("%w: department is longer than %d characters", ErrInvalidUser, columnLimits["department"])
+	}
+	for _, r := range DefaultRoles {
+		if r.Name == grant.Role {
+			return nil
+		}
+	}
+	return ErrUnknownRole
+}
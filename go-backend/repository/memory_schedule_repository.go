@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync/atomic"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+func (r *MemoryUserRepository) ScheduleChange(ctx context.Context, change *models.ScheduledChange) error {
+	return r.write(func(s *memoryState) error {
+		// Like the foreign key, a change may be scheduled for a deleted
+		// user but not for a missing one.
+		if _, ok := s.users[change.UserID]; !ok {
+			return ErrNotFound
+		}
+		change.ID = atomic.AddInt64(r.nextChangeID, 1)
+		s.changes[change.ID] = copyScheduledChange(*change)
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) ScheduledChanges(ctx context.Context, filter ScheduleFilter) ([]models.ScheduledChange, error) {
+	changes := []models.ScheduledChange{}
+	err := r.read(func(s *memoryState) error {
+		for _, change := range s.changes {
+			switch {
+			case filter.UserID != 0 && change.UserID != filter.UserID,
+				len(filter.States) > 0 && !slices.Contains(filter.States, change.State),
+				!filter.DueBy.IsZero() && change.EffectiveAt.After(filter.DueBy),
+				!s.changeInScope(ctx, change):
+				continue
+			}
+			changes = append(changes, copyScheduledChange(change))
+		}
+		return nil
+	})
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].EffectiveAt.Equal(changes[j].EffectiveAt) {
+			return changes[i].EffectiveAt.Before(changes[j].EffectiveAt)
+		}
+		return changes[i].ID < changes[j].ID
+	})
+	return changes, err
+}
+
+func (r *MemoryUserRepository) ScheduledChange(ctx context.Context, id int64) (*models.ScheduledChange, error) {
+	var found models.ScheduledChange
+	err := r.read(func(s *memoryState) error {
+		change, ok := s.changes[id]
+		if !ok || !s.changeInScope(ctx, change) {
+			return ErrChangeNotFound
+		}
+		found = copyScheduledChange(change)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func (r *MemoryUserRepository) ResolveScheduledChange(ctx context.Context, change *models.ScheduledChange) error {
+	return r.write(func(s *memoryState) error {
+		stored, ok := s.changes[change.ID]
+		if !ok || !s.changeInScope(ctx, stored) {
+			return ErrChangeNotFound
+		}
+		if stored.State != models.ChangePending {
+			return ErrChangeNotPending
+		}
+		stored.State = change.State
+		stored.ResolvedBy = change.ResolvedBy
+		stored.ResolvedAt = change.ResolvedAt
+		stored.Error = change.Error
+		s.changes[change.ID] = copyScheduledChange(stored)
+		return nil
+	})
+}
+
+// changeInScope reports whether ctx allows access to the user change is
+// scheduled for.
+func (s *memoryState) changeInScope(ctx context.Context, change models.ScheduledChange) bool {
+	return inScope(ctx, s.users[change.UserID].Department)
+}
+
+// copyScheduledChange returns a copy of change that shares no memory with
+// it.
+func copyScheduledChange(change models.ScheduledChange) models.ScheduledChange {
+	if change.ResolvedAt != nil {
+		resolvedAt := *change.ResolvedAt
+		change.ResolvedAt = &resolvedAt
+	}
+	return change
+}
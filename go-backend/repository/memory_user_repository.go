@@ -0,0 +1,560 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+// MemoryURL is the DATABASE_URL that selects the in-memory repository.
+const MemoryURL = "memory://"
+
+// columnLimits mirrors the VARCHAR lengths of the users table.
+var columnLimits = map[string]int{
+	"user_name":     50,
+	"first_name":    255,
+	"last_name":     255,
+	"email":         255,
+	"user_status":   1,
+	"department":    255,
+	"status_reason": 255,
+}
+
+// memoryState is the data a transaction works on a private copy of.
+type memoryState struct {
+	users map[int64]models.User
+	// grants holds the roles granted to each user.
+	grants map[int64]map[models.Grant]bool
+	// keys holds the API keys by id.
+	keys map[int64]models.APIKey
+	// audit is the audit log in id order.
+	audit []models.AuditEntry
+	// changes holds the scheduled changes by id.
+	changes map[int64]models.ScheduledChange
+	// departments holds the departments by id.
+	departments map[int64]models.Department
+}
+
+func newMemoryState() *memoryState {
+	return &memoryState{
+		users:       map[int64]models.User{},
+		grants:      map[int64]map[models.Grant]bool{},
+		keys:        map[int64]models.APIKey{},
+		changes:     map[int64]models.ScheduledChange{},
+		departments: map[int64]models.Department{},
+	}
+}
+
+func (s *memoryState) clone() *memoryState {
+	clone := newMemoryState()
+	for id, user := range s.users {
+		clone.users[id] = user
+	}
+	for id, grants := range s.grants {
+		clone.grants[id] = make(map[models.Grant]bool, len(grants))
+		for grant := range grants {
+			clone.grants[id][grant] = true
+		}
+	}
+	for id, key := range s.keys {
+		clone.keys[id] = copyAPIKey(key)
+	}
+	for id, change := range s.changes {
+		clone.changes[id] = copyScheduledChange(change)
+	}
+	for id, department := range s.departments {
+		clone.departments[id] = copyDepartment(department)
+	}
+	// Entries are never changed once appended, so they can be shared.
+	clone.audit = slices.Clip(s.audit)
+	return clone
+}
+
+// get returns user id if it is within the departments ctx is confined to
+// and, unless ctx includes them, not deleted.
+func (s *memoryState) get(ctx context.Context, id int64) (models.User, error) {
+	user, ok := s.users[id]
+	if !ok || !inScope(ctx, user.Department) || (user.DeletedAt != nil && !includesDeleted(ctx, user.Department)) {
+		return models.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+// live returns user id like get, but never a deleted user.
+func (s *memoryState) live(ctx context.Context, id int64) (models.User, error) {
+	user, err := s.get(ctx, id)
+	if err == nil && user.DeletedAt != nil {
+		return models.User{}, ErrNotFound
+	}
+	return user, err
+}
+
+// byUsername returns the user that is not deleted named username.
+func (s *memoryState) byUsername(username string) (models.User, bool) {
+	for _, user := range s.users {
+		if user.UserName == username && user.DeletedAt == nil {
+			return user, true
+		}
+	}
+	return models.User{}, false
+}
+
+// MemoryUserRepository keeps users in memory with the same constraints as the
+// Postgres schema: unique user names, the user_status check, column lengths,
+// departments and managers that exist and ids drawn from a sequence that is
+// never reused, not even by a rolled back transaction. It is safe for
+// concurrent use.
+type MemoryUserRepository struct {
+	mu     *sync.RWMutex
+	state  *memoryState
+	nextID *int64
+	// nextKeyID, nextAuditID, nextChangeID and nextDepartmentID are the
+	// sequences of API key, audit entry, scheduled change and department
+	// ids.
+	nextKeyID        *int64
+	nextAuditID      *int64
+	nextChangeID     *int64
+	nextDepartmentID *int64
+	// inTx is set on the repository handed to a WithTx callback, which
+	// already holds the write lock and owns a private copy of the state.
+	inTx bool
+}
+
+// NewMemoryUserRepository returns an empty in-memory repository.
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		mu:               new(sync.RWMutex),
+		state:            newMemoryState(),
+		nextID:           new(int64),
+		nextKeyID:        new(int64),
+		nextAuditID:      new(int64),
+		nextChangeID:     new(int64),
+		nextDepartmentID: new(int64),
+	}
+}
+
+func (r *MemoryUserRepository) read(fn func(*memoryState) error) error {
+	if !r.inTx {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+	}
+	return fn(r.state)
+}
+
+func (r *MemoryUserRepository) write(fn func(*memoryState) error) error {
+	if !r.inTx {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+	}
+	return fn(r.state)
+}
+
+func (r *MemoryUserRepository) List(ctx context.Context, opts ListOptions) ([]models.User, int64, error) {
+	for _, f := range opts.Filters {
+		if !isColumn(f.Column) {
+			return nil, 0, fmt.Errorf("unknown column %q", f.Column)
+		}
+	}
+	for _, tr := range opts.Ranges {
+		if !isColumn(tr.Column) {
+			return nil, 0, fmt.Errorf("unknown column %q", tr.Column)
+		}
+	}
+	for _, f := range opts.Sort {
+		if !isColumn(f.Column) {
+			return nil, 0, fmt.Errorf("unknown column %q", f.Column)
+		}
+	}
+
+	var matched []models.User
+	err := r.read(func(s *memoryState) error {
+		for _, user := range s.users {
+			if user.DeletedAt != nil && !includesDeleted(ctx, user.Department) {
+				continue
+			}
+			if inScope(ctx, user.Department) && matchesFilters(user, opts.Filters) && inRanges(user, opts.Ranges) {
+				matched = append(matched, user)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	total := int64(len(matched))
+
+	sort.Slice(matched, func(i, j int) bool {
+		return compareUsers(matched[i], matched[j], opts.Sort) < 0
+	})
+
+	users := []models.User{}
+	if opts.Cursor != nil {
+		for _, user := range matched {
+			c := compareToKeys(user, opts.Cursor.Keys, opts.Sort)
+			if (opts.Cursor.Before && c < 0) || (!opts.Cursor.Before && c > 0) {
+				users = append(users, user)
+			}
+		}
+		// Keep the rows nearest to the cursor.
+		if opts.Cursor.Before && opts.Limit > 0 && uint64(len(users)) > opts.Limit {
+			users = users[uint64(len(users))-opts.Limit:]
+		}
+	} else if opts.Offset < uint64(len(matched)) {
+		users = append(users, matched[opts.Offset:]...)
+	}
+	if opts.Limit > 0 && uint64(len(users)) > opts.Limit {
+		users = users[:opts.Limit]
+	}
+	return users, total, nil
+}
+
+func (r *MemoryUserRepository) Get(ctx context.Context, id int64) (*models.User, error) {
+	var found *models.User
+	err := r.read(func(s *memoryState) error {
+		user, err := s.get(ctx, id)
+		if err != nil {
+			return err
+		}
+		found = &user
+		return nil
+	})
+	return found, err
+}
+
+func (r *MemoryUserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	var found *models.User
+	err := r.read(func(s *memoryState) error {
+		user, ok := s.byUsername(username)
+		if !ok || !inScope(ctx, user.Department) {
+			return ErrNotFound
+		}
+		found = &user
+		return nil
+	})
+	return found, err
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	if err := checkUser(user); err != nil {
+		return err
+	}
+	if !inScope(ctx, user.Department) {
+		return ErrOutOfScope
+	}
+	return r.write(func(s *memoryState) error {
+		// Like a SERIAL column, the id is drawn before the unique
+		// constraint is checked, so a failed insert still consumes it.
+		id := atomic.AddInt64(r.nextID, 1)
+		if _, taken := s.byUsername(user.UserName); taken {
+			return ErrUserNameTaken
+		}
+		if err := s.checkUserDepartment(user); err != nil {
+			return err
+		}
+		if err := s.checkManager(0, user.ManagerID); err != nil {
+			return err
+		}
+		user.UserID = id
+		user.Version = 1
+		now, actor := stamp(ctx)
+		user.CreatedAt, user.CreatedBy = now, actor
+		user.UpdatedAt, user.UpdatedBy = now, actor
+		stored := *user
+		setColumnValue(&stored, "manager_id", ColumnValue(*user, "manager_id"))
+		s.users[id] = stored
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) Update(ctx context.Context, user *models.User, columns []string, pre *Precondition) error {
+	if len(columns) == 0 {
+		columns = WritableColumns
+	}
+	for _, column := range columns {
+		if !isWritable(column) {
+			return fmt.Errorf("column %q cannot be updated", column)
+		}
+	}
+
+	return r.write(func(s *memoryState) error {
+		stored, err := s.live(ctx, user.UserID)
+		if err != nil {
+			return err
+		}
+		if !pre.matches(stored.Version) {
+			return ErrVersionMismatch
+		}
+
+		updated := stored
+		for _, column := range columns {
+			setColumnValue(&updated, column, ColumnValue(*user, column))
+		}
+		if err := checkUser(&updated); err != nil {
+			return err
+		}
+		if !inScope(ctx, updated.Department) {
+			return ErrOutOfScope
+		}
+		if updated.UserName != stored.UserName {
+			if _, taken := s.byUsername(updated.UserName); taken {
+				return ErrUserNameTaken
+			}
+		}
+		if err := s.checkUserDepartment(&updated); err != nil {
+			return err
+		}
+		if err := s.checkManager(updated.UserID, updated.ManagerID); err != nil {
+			return err
+		}
+
+		updated.Version++
+		updated.UpdatedAt, updated.UpdatedBy = stamp(ctx)
+		s.users[user.UserID] = updated
+		user.Version = updated.Version
+		user.UpdatedAt, user.UpdatedBy = updated.UpdatedAt, updated.UpdatedBy
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) Delete(ctx context.Context, id int64, pre *Precondition) error {
+	return r.write(func(s *memoryState) error {
+		stored, err := s.live(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !pre.matches(stored.Version) {
+			return ErrVersionMismatch
+		}
+		now, actor := stamp(ctx)
+		stored.DeletedAt = &now
+		stored.UpdatedAt, stored.UpdatedBy = now, actor
+		stored.Version++
+		s.users[id] = stored
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) Restore(ctx context.Context, id int64, pre *Precondition) (*models.User, error) {
+	var restored *models.User
+	err := r.write(func(s *memoryState) error {
+		stored, err := s.get(WithDeleted(ctx), id)
+		if err != nil {
+			return err
+		}
+		if stored.DeletedAt == nil {
+			return ErrNotDeleted
+		}
+		if !pre.matches(stored.Version) {
+			return ErrVersionMismatch
+		}
+		if _, taken := s.byUsername(stored.UserName); taken {
+			return ErrUserNameTaken
+		}
+		stored.DeletedAt = nil
+		stored.UpdatedAt, stored.UpdatedBy = stamp(ctx)
+		stored.Version++
+		s.users[id] = stored
+		restored = &stored
+		return nil
+	})
+	return restored, err
+}
+
+func (r *MemoryUserRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	err := r.write(func(s *memoryState) error {
+		for id, user := range s.users {
+			if user.DeletedAt != nil && user.DeletedAt.Before(before) && inScope(ctx, user.Department) {
+				delete(s.users, id)
+				delete(s.grants, id)
+				for changeID, change := range s.changes {
+					if change.UserID == id {
+						delete(s.changes, changeID)
+					}
+				}
+				purged++
+			}
+		}
+		// Like the foreign key, leave the reports of purged users without
+		// a manager.
+		for id, user := range s.users {
+			if user.ManagerID != nil {
+				if _, ok := s.users[*user.ManagerID]; !ok {
+					user.ManagerID = nil
+					s.users[id] = user
+				}
+			}
+		}
+		return nil
+	})
+	return purged, err
+}
+
+// WithTx runs fn with exclusive access to a copy of the data, which replaces
+// the original only if fn returns nil.
+func (r *MemoryUserRepository) WithTx(ctx context.Context, fn func(UserRepository) error) error {
+	if r.inTx {
+		return fn(r)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx := &MemoryUserRepository{mu: r.mu, state: r.state.clone(), nextID: r.nextID, nextKeyID: r.nextKeyID, nextAuditID: r.nextAuditID, nextChangeID: r.nextChangeID,
+		nextDepartmentID: r.nextDepartmentID, inTx: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	r.state = tx.state
+	return nil
+}
+
+func (p *Precondition) matches(version int64) bool {
+	if p == nil {
+		return true
+	}
+	for _, v := range p.Versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUser enforces the column constraints of the users table.
+func checkUser(user *models.User) error {
+	for column, limit := range columnLimits {
+		if n := utf8.RuneCountInString(ColumnValue(*user, column).(string)); n > limit {
+			return fmt.Errorf("%w: %s is longer than %d characters", ErrInvalidUser, column, limit)
+		}
+	}
+	switch user.UserStatus {
+	case "I", "A", "T":
+	default:
+		return fmt.Errorf("%w: user_status must be one of I, A, T", ErrInvalidUser)
+	}
+	return nil
+}
+
+func setColumnValue(user *models.User, column string, value interface{}) {
+	switch column {
+	case "user_name":
+		user.UserName = value.(string)
+	case "first_name":
+		user.FirstName = value.(string)
+	case "last_name":
+		user.LastName = value.(string)
+	case "email":
+		user.Email = value.(string)
+	case "user_status":
+		user.UserStatus = value.(string)
+	case "department":
+		user.Department = value.(string)
+	case "status_reason":
+		user.StatusReason = value.(string)
+	case "status_effective_date":
+		user.StatusEffectiveDate = value.(string)
+	case "manager_id":
+		// Stored users never share a manager id with their callers.
+		user.ManagerID = nil
+		if id, ok := value.(int64); ok {
+			user.ManagerID = &id
+		}
+	}
+}
+
+func matchesFilters(user models.User, filters []Filter) bool {
+	for _, f := range filters {
+		value := fmt.Sprint(ColumnValue(user, f.Column))
+		matched := false
+		if f.Prefix {
+			matched = strings.HasPrefix(value, f.Values[0])
+		} else {
+			for _, v := range f.Values {
+				if value == v {
+					matched = true
+					break
+				}
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// inRanges reports whether the time columns of user fall in every range.
+func inRanges(user models.User, ranges []TimeRange) bool {
+	for _, tr := range ranges {
+		t, _ := ColumnValue(user, tr.Column).(time.Time)
+		if (!tr.Since.IsZero() && t.Before(tr.Since)) || (!tr.Until.IsZero() && !t.Before(tr.Until)) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareUsers orders a and b by the sort columns.
+func compareUsers(a, b models.User, sortFields []SortField) int {
+	for _, f := range sortFields {
+		c := compareValues(ColumnValue(a, f.Column), ColumnValue(b, f.Column))
+		if f.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareToKeys orders user against the row a cursor points at.
+func compareToKeys(user models.User, keys map[string]interface{}, sortFields []SortField) int {
+	for _, f := range sortFields {
+		c := compareValues(ColumnValue(user, f.Column), keys[f.Column])
+		if f.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int64:
+		var bv int64
+		switch b := b.(type) {
+		case int64:
+			bv = b
+		case float64:
+			bv = int64(b)
+		case int:
+			bv = int64(b)
+		}
+		switch {
+		case a < bv:
+			return -1
+		case a > bv:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(a, fmt.Sprint(b))
+	case time.Time:
+		b, _ := b.(time.Time)
+		return a.Compare(b)
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+// SQLSTATEs Postgres reports when a constraint of the users or departments
+// table rejects a write.
+const (
+	pgForeignKeyViolation  = "23503"
+	pgUniqueViolation      = "23505"
+	pgCheckViolation       = "23514"
+	pgStringDataTruncation = "22001"
+)
+
+// Postgres is the dialect of the users table in PostgreSQL.
+var Postgres = Dialect{
+	Placeholder:     squirrel.Dollar,
+	ConstraintError: postgresConstraintError,
+	// SHARE ROW EXCLUSIVE conflicts with itself but not with readers.
+	LockAudit:       "LOCK TABLE user_audit IN SHARE ROW EXCLUSIVE MODE",
+	LockDepartments: "LOCK TABLE departments IN SHARE ROW EXCLUSIVE MODE",
+	LockManagers:    "LOCK TABLE users IN SHARE ROW EXCLUSIVE MODE",
+}
+
+// NewPostgresUserRepository returns a repository backed by a Postgres db.
+func NewPostgresUserRepository(db *sql.DB) *SQLUserRepository {
+	return NewSQLUserRepository(db, Postgres)
+}
+
+// postgresConstraintError relies on the constraints rather than checking
+// first, which keeps concurrent writers from both passing the check.
+func postgresConstraintError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code {
+	case pgUniqueViolation:
+		switch pqErr.Constraint {
+		case "departments_code_key":
+			return ErrDepartmentCodeTaken
+		case "departments_name_key":
+			return ErrDepartmentNameTaken
+		}
+		return ErrUserNameTaken
+	case pgForeignKeyViolation:
+		if pqErr.Constraint == "users_manager_id_fkey" {
+			return ErrUnknownManager
+		}
+		return ErrUnknownDepartment
+	case pgCheckViolation, pgStringDataTruncation:
+		if pqErr.Table == "departments" {
+			return fmt.Errorf("%w: %s", ErrInvalidDepartment, pqErr.Message)
+		}
+		return fmt.Errorf("%w: %s", ErrInvalidUser, pqErr.Message)
+	}
+	return err
+}
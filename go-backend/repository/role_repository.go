@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+// ErrUnknownRole is returned when a role name matches no role.
+var ErrUnknownRole = errors.New("role not found")
+
+// RoleRepository stores the roles that can be granted and which users hold
+// them. Grants are removed together with the user.
+type RoleRepository interface {
+	// Roles lists every role, ordered by name.
+	Roles(ctx context.Context) ([]models.Role, error)
+	// UserRoles returns the grants of user id, ordered by role and
+	// department.
+	UserRoles(ctx context.Context, userID int64) ([]models.Grant, error)
+	// GrantRole gives user id grant. Granting the same role in the same
+	// department twice is a no-op.
+	GrantRole(ctx context.Context, userID int64, grant models.Grant) error
+	// RevokeRole takes grant away from user id. Revoking a grant the user
+	// does not hold is a no-op.
+	RevokeRole(ctx context.Context, userID int64, grant models.Grant) error
+}
+
+// DefaultRoles are the roles created by the migrations.
+var DefaultRoles = []models.Role{
+	{Name: "admin", Description: "Manage users and grant roles"},
+	{Name: "editor", Description: "Read, create and update users"},
+	{Name: "viewer", Description: "Read users"},
+}
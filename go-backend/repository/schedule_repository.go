@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+var (
+	// ErrChangeNotFound is returned when no scheduled change matches the
+	// requested id.
+	ErrChangeNotFound = errors.New("scheduled change not found")
+	// ErrChangeNotPending is returned when resolving a scheduled change that
+	// has already been applied, cancelled or failed.
+	ErrChangeNotPending = errors.New("scheduled change is not pending")
+)
+
+// ScheduleFilter selects scheduled changes. Zero fields match every change.
+type ScheduleFilter struct {
+	UserID int64
+	States []string
+	// DueBy matches the changes taking effect at or before it.
+	DueBy time.Time
+}
+
+// ScheduleRepository stores the changes to users scheduled to take effect
+// later. Calls made with a context from WithDepartments only see the changes
+// to users of those departments.
+type ScheduleRepository interface {
+	// ScheduleChange inserts change and stores the assigned id on it.
+	ScheduleChange(ctx context.Context, change *models.ScheduledChange) error
+	// ScheduledChanges lists the changes matching filter in the order they
+	// take effect.
+	ScheduledChanges(ctx context.Context, filter ScheduleFilter) ([]models.ScheduledChange, error)
+	ScheduledChange(ctx context.Context, id int64) (*models.ScheduledChange, error)
+	// ResolveScheduledChange writes the State, ResolvedBy, ResolvedAt and
+	// Error of change to the change with its id. It fails with
+	// ErrChangeNotPending unless that change is pending, so a change is
+	// resolved once even by concurrent transactions.
+	ResolveScheduledChange(ctx context.Context, change *models.ScheduledChange) error
+}
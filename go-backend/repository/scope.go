@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrOutOfScope is returned when a write would put a user in a department
+// outside the departments the context is confined to.
+var ErrOutOfScope = errors.New("department is outside the caller's scope")
+
+type departmentsKey struct{}
+
+// WithDepartments confines the repository calls made with the returned
+// context to the users of departments. Users in other departments are not
+// found, and creating a user in, or moving one to, another department fails
+// with ErrOutOfScope.
+func WithDepartments(ctx context.Context, departments []string) context.Context {
+	return context.WithValue(ctx, departmentsKey{}, departments)
+}
+
+// unscoped lifts the confinement of ctx to departments, for the checks that
+// have to see every user, such as whether a manager exists.
+func unscoped(ctx context.Context) context.Context {
+	return context.WithValue(ctx, departmentsKey{}, nil)
+}
+
+// scopeOf returns the departments ctx is confined to and whether it is
+// confined at all.
+func scopeOf(ctx context.Context) ([]string, bool) {
+	departments, ok := ctx.Value(departmentsKey{}).([]string)
+	return departments, ok
+}
+
+// inScope reports whether ctx allows access to the users of department.
+func inScope(ctx context.Context, department string) bool {
+	departments, scoped := scopeOf(ctx)
+	if !scoped {
+		return true
+	}
+	for _, d := range departments {
+		if d == department {
+			return true
+		}
+	}
+	return false
+}
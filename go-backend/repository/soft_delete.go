@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"slices"
+)
+
+type deletedKey struct{}
+
+// WithDeleted makes the List and Get calls made with the returned context
+// include soft deleted users.
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deletedKey{}, []string(nil))
+}
+
+// WithDeletedIn is WithDeleted for the soft deleted users of departments
+// only. The users that are not deleted stay confined to the departments of
+// ctx, if any, and deleted users must be in both.
+func WithDeletedIn(ctx context.Context, departments []string) context.Context {
+	return context.WithValue(ctx, deletedKey{}, append([]string{}, departments...))
+}
+
+// deletedScopeOf reports whether ctx includes soft deleted users, and
+// returns the departments it includes them in when not all of them.
+func deletedScopeOf(ctx context.Context) (departments []string, scoped, include bool) {
+	departments, include = ctx.Value(deletedKey{}).([]string)
+	return departments, departments != nil, include
+}
+
+// includesDeleted reports whether ctx includes the soft deleted users of
+// department.
+func includesDeleted(ctx context.Context, department string) bool {
+	departments, scoped, include := deletedScopeOf(ctx)
+	return include && (!scoped || slices.Contains(departments, department))
+}
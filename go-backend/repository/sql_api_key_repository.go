@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+var apiKeyColumns = []string{"key_id", "name", "prefix", "secret_hash", "scopes", "created_by", "created_at", "expires_at", "last_used_at", "revoked_at"}
+
+func (r *SQLUserRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	sqlQuery, args, err := r.psql.Insert("api_keys").
+		Columns(apiKeyColumns[1:8]...).
+		Values(key.Name, key.Prefix, key.SecretHash, strings.Join(key.Scopes, " "), key.CreatedBy, key.CreatedAt, key.ExpiresAt).
+		Suffix("RETURNING key_id").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	return r.q.QueryRowContext(ctx, sqlQuery, args...).Scan(&key.ID)
+}
+
+func (r *SQLUserRepository) APIKeys(ctx context.Context) ([]models.APIKey, error) {
+	sqlQuery, args, err := r.psql.Select(apiKeyColumns...).From("api_keys").OrderBy("key_id").ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.q.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *SQLUserRepository) APIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	return r.findAPIKey(ctx, squirrel.Eq{"prefix": prefix})
+}
+
+func (r *SQLUserRepository) RevokeAPIKey(ctx context.Context, id int64, at time.Time) (*models.APIKey, error) {
+	sqlQuery, args, err := r.psql.Update("api_keys").
+		Set("revoked_at", at).
+		Where(squirrel.Eq{"key_id": id, "revoked_at": nil}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.q.ExecContext(ctx, sqlQuery, args...); err != nil {
+		return nil, err
+	}
+	return r.findAPIKey(ctx, squirrel.Eq{"key_id": id})
+}
+
+func (r *SQLUserRepository) TouchAPIKey(ctx context.Context, id int64, at time.Time) error {
+	sqlQuery, args, err := r.psql.Update("api_keys").
+		Set("last_used_at", at).
+		Where(squirrel.Eq{"key_id": id}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	result, err := r.q.ExecContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// findAPIKey returns the single API key matching pred.
+func (r *SQLUserRepository) findAPIKey(ctx context.Context, pred squirrel.Sqlizer) (*models.APIKey, error) {
+	sqlQuery, args, err := r.psql.Select(apiKeyColumns...).From("api_keys").Where(pred).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := scanAPIKey(r.q.QueryRowContext(ctx, sqlQuery, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// scanAPIKey reads a row selected with apiKeyColumns.
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var (
+		key                            models.APIKey
+		scopes                         string
+		expiresAt, lastUsed, revokedAt sql.NullTime
+	)
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.SecretHash, &scopes, &key.CreatedBy, &key.CreatedAt, &expiresAt, &lastUsed, &revokedAt); err != nil {
+		return nil, err
+	}
+	key.Scopes = strings.Fields(scopes)
+	inUTC(&key.CreatedAt)
+	key.ExpiresAt = nullTime(expiresAt)
+	key.LastUsedAt = nullTime(lastUsed)
+	key.RevokedAt = nullTime(revokedAt)
+	return &key, nil
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	inUTC(&t.Time)
+	return &t.Time
+}
+
+// inUTC moves times read from the database to UTC. SQLite hands them back in
+// a zone with the offset they were written with, not as UTC.
+func inUTC(times ...*time.Time) {
+	for _, t := range times {
+		if t != nil {
+			*t = t.UTC()
+		}
+	}
+}
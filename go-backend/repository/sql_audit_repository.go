@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+var auditColumns = []string{"audit_id", "user_id", "actor", "request_id", "operation", "changes", "occurred_at", "prev_hash", "hash"}
+
+func (r *SQLUserRepository) RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	return r.WithTx(ctx, func(tx UserRepository) error {
+		return tx.(*SQLUserRepository).appendAudit(ctx, entry)
+	})
+}
+
+// appendAudit chains entry onto the last entry. r must be transactional so
+// that the lock is held until the entry is committed.
+func (r *SQLUserRepository) appendAudit(ctx context.Context, entry *models.AuditEntry) error {
+	if r.dialect.LockAudit != "" {
+		if _, err := r.q.ExecContext(ctx, r.dialect.LockAudit); err != nil {
+			return err
+		}
+	}
+
+	// Chain onto the anchored head rather than the last entry, so that
+	// entries deleted from the end stay missing from the chain.
+	anchor, err := r.AuditAnchor(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Stored timestamps keep microseconds; hash what will be read back.
+	entry.At = entry.At.UTC().Truncate(time.Microsecond)
+	entry.PrevHash = anchor.Head
+	entry.Hash = entry.ComputeHash()
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+
+	sqlQuery, args, err := r.psql.Insert("user_audit").
+		Columns(auditColumns[1:]...).
+		Values(entry.UserID, entry.Actor, entry.RequestID, entry.Operation, string(changes), entry.At, entry.PrevHash, entry.Hash).
+		Suffix("RETURNING audit_id").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if err := r.q.QueryRowContext(ctx, sqlQuery, args...).Scan(&entry.ID); err != nil {
+		return err
+	}
+
+	anchorQuery, args, err := r.psql.Update("user_audit_chain").
+		Set("head", entry.Hash).
+		Set("entries", squirrel.Expr("entries + 1")).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, anchorQuery, args...)
+	return err
+}
+
+func (r *SQLUserRepository) AuditLog(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, int64, error) {
+	where := squirrel.And{}
+	if filter.UserID != 0 {
+		where = append(where, squirrel.Eq{"user_id": filter.UserID})
+	}
+	if len(filter.Actors) > 0 {
+		where = append(where, squirrel.Eq{"actor": filter.Actors})
+	}
+	if len(filter.Operations) > 0 {
+		where = append(where, squirrel.Eq{"operation": filter.Operations})
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, squirrel.GtOrEq{"occurred_at": filter.Since.UTC()})
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, squirrel.Lt{"occurred_at": filter.Until.UTC()})
+	}
+
+	countQuery, args, err := r.psql.Select("COUNT(*)").From("user_audit").Where(where).ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if err := r.q.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := r.psql.Select(auditColumns...).
+		From("user_audit").
+		Where(where).
+		OrderBy("audit_id DESC")
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	entries := []models.AuditEntry{}
+	err = r.scanAudit(ctx, query, func(entry models.AuditEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+func (r *SQLUserRepository) AuditChain(ctx context.Context, fn func(models.AuditEntry) error) error {
+	return r.scanAudit(ctx, r.psql.Select(auditColumns...).From("user_audit").OrderBy("audit_id"), fn)
+}
+
+func (r *SQLUserRepository) AuditAnchor(ctx context.Context) (AuditAnchor, error) {
+	var anchor AuditAnchor
+	sqlQuery, args, err := r.psql.Select("unsealed_until", "head", "entries").From("user_audit_chain").ToSql()
+	if err != nil {
+		return anchor, err
+	}
+	err = r.q.QueryRowContext(ctx, sqlQuery, args...).Scan(&anchor.UnsealedUntil, &anchor.Head, &anchor.Entries)
+	return anchor, err
+}
+
+// scanAudit calls fn with each entry selected by query, which selects
+// auditColumns.
+func (r *SQLUserRepository) scanAudit(ctx context.Context, query squirrel.SelectBuilder, fn func(models.AuditEntry) error) error {
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	rows, err := r.q.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			entry   models.AuditEntry
+			changes string
+		)
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.Actor, &entry.RequestID, &entry.Operation, &changes, &entry.At, &entry.PrevHash, &entry.Hash); err != nil {
+			return err
+		}
+		inUTC(&entry.At)
+		if err := json.Unmarshal([]byte(changes), &entry.Changes); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+var departmentColumns = []string{"department_id", "code", "name", "parent_id", "cost_center"}
+
+func (r *SQLUserRepository) Departments(ctx context.Context) ([]models.Department, error) {
+	sqlQuery, args, err := r.psql.Select(departmentColumns...).From("departments").OrderBy("name").ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.q.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	departments := []models.Department{}
+	for rows.Next() {
+		department, err := scanDepartment(rows)
+		if err != nil {
+			return nil, err
+		}
+		departments = append(departments, *department)
+	}
+	return departments, rows.Err()
+}
+
+func (r *SQLUserRepository) Department(ctx context.Context, id int64) (*models.Department, error) {
+	return r.findDepartment(ctx, squirrel.Eq{"department_id": id})
+}
+
+func (r *SQLUserRepository) DepartmentByName(ctx context.Context, name string) (*models.Department, error) {
+	return r.findDepartment(ctx, squirrel.Eq{"name": name})
+}
+
+func (r *SQLUserRepository) CreateDepartment(ctx context.Context, department *models.Department) error {
+	sqlQuery, args, err := r.psql.Insert("departments").
+		Columns(departmentColumns[1:]...).
+		Values(department.Code, department.Name, department.ParentID, department.CostCenter).
+		Suffix("RETURNING department_id").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	err = r.q.QueryRowContext(ctx, sqlQuery, args...).Scan(&department.ID)
+	return r.dialect.ConstraintError(err)
+}
+
+func (r *SQLUserRepository) UpdateDepartment(ctx context.Context, department *models.Department) error {
+	return r.WithTx(ctx, func(tx UserRepository) error {
+		return tx.(*SQLUserRepository).updateDepartment(ctx, department)
+	})
+}
+
+// updateDepartment is UpdateDepartment within a transaction.
+func (r *SQLUserRepository) updateDepartment(ctx context.Context, department *models.Department) error {
+	stored, err := r.Department(ctx, department.ID)
+	if err != nil {
+		return err
+	}
+	if department.ParentID != nil {
+		// Keep concurrent moves from closing a cycle between them.
+		if r.dialect.LockDepartments != "" {
+			if _, err := r.q.ExecContext(ctx, r.dialect.LockDepartments); err != nil {
+				return err
+			}
+		}
+		if err := checkAncestry(department.ID, department.ParentID, func(id int64) (*models.Department, error) {
+			return r.Department(ctx, id)
+		}); err != nil {
+			return err
+		}
+	}
+
+	sqlQuery, args, err := r.psql.Update("departments").
+		Set("code", department.Code).
+		Set("name", department.Name).
+		Set("parent_id", department.ParentID).
+		Set("cost_center", department.CostCenter).
+		Where(squirrel.Eq{"department_id": department.ID}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := r.q.ExecContext(ctx, sqlQuery, args...); err != nil {
+		return r.dialect.ConstraintError(err)
+	}
+	if stored.Name == department.Name {
+		return nil
+	}
+
+	// The foreign key has already moved the users; record the write on them.
+	now, actor := stamp(ctx)
+	moves := []squirrel.Sqlizer{
+		r.psql.Update("users").
+			Set("updated_at", now).
+			Set("updated_by", actor).
+			Set("version", squirrel.Expr("version + 1")).
+			Where(squirrel.Eq{"department": department.Name}),
+		r.psql.Update("user_roles").
+			Set("department", department.Name).
+			Where(squirrel.Eq{"department": stored.Name}),
+		r.psql.Update("scheduled_changes").
+			Set("department", department.Name).
+			Where(squirrel.Eq{"department": stored.Name, "state": models.ChangePending}),
+	}
+	for _, move := range moves {
+		sqlQuery, args, err := move.ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := r.q.ExecContext(ctx, sqlQuery, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SQLUserRepository) DeleteDepartment(ctx context.Context, id int64) error {
+	return r.WithTx(ctx, func(tx UserRepository) error {
+		return tx.(*SQLUserRepository).deleteDepartment(ctx, id)
+	})
+}
+
+// deleteDepartment is DeleteDepartment within a transaction.
+func (r *SQLUserRepository) deleteDepartment(ctx context.Context, id int64) error {
+	stored, err := r.Department(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	sqlQuery, args, err := r.psql.Delete("departments").Where(squirrel.Eq{"department_id": id}).ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := r.q.ExecContext(ctx, sqlQuery, args...); err != nil {
+		// The only foreign keys to a department are those of its users and
+		// sub-departments.
+		if errors.Is(r.dialect.ConstraintError(err), ErrUnknownDepartment) {
+			return ErrDepartmentInUse
+		}
+		return err
+	}
+
+	// Grants are kept by name, so they would otherwise apply to a new
+	// department of the same name.
+	sqlQuery, args, err = r.psql.Delete("user_roles").Where(squirrel.Eq{"department": stored.Name}).ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, sqlQuery, args...)
+	return err
+}
+
+// findDepartment returns the single department matching pred.
+func (r *SQLUserRepository) findDepartment(ctx context.Context, pred squirrel.Sqlizer) (*models.Department, error) {
+	sqlQuery, args, err := r.psql.Select(departmentColumns...).From("departments").Where(pred).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	department, err := scanDepartment(r.q.QueryRowContext(ctx, sqlQuery, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDepartmentNotFound
+	}
+	return department, err
+}
+
+// scanDepartment reads a row selected with departmentColumns.
+func scanDepartment(row rowScanner) (*models.Department, error) {
+	var (
+		department models.Department
+		parentID   sql.NullInt64
+	)
+	if err := row.Scan(&department.ID, &department.Code, &department.Name, &parentID, &department.CostCenter); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		department.ParentID = &parentID.Int64
+	}
+	return &department, nil
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+func (r *SQLUserRepository) ManagementChain(ctx context.Context, id int64) ([]models.User, error) {
+	// The chain is read whole and walked in Go, which orders it and keeps
+	// the users out of scope from cutting it short.
+	chain := squirrel.ConcatExpr(
+		squirrel.Select("user_id", "manager_id").From("users").Where(squirrel.Eq{"user_id": id}),
+		" UNION ",
+		squirrel.Select("m.user_id", "m.manager_id").From("users m").Join("chain ON m.user_id = chain.manager_id").Where(squirrel.Eq{"m.deleted_at": nil}),
+	)
+	users, err := r.selectUsers(ctx, r.psql.Select(Columns...).
+		PrefixExpr(squirrel.Expr("WITH RECURSIVE chain (user_id, manager_id) AS (?)", chain)).
+		From("users").
+		Where("user_id IN (SELECT user_id FROM chain)"))
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]models.User, len(users))
+	for _, user := range users {
+		byID[user.UserID] = user
+	}
+	return managementChain(ctx, id, byID), nil
+}
+
+func (r *SQLUserRepository) Subordinates(ctx context.Context, id int64) ([]models.User, error) {
+	subtree := squirrel.ConcatExpr(
+		squirrel.Select("user_id").From("users").Where(squirrel.Eq{"manager_id": id, "deleted_at": nil}),
+		" UNION ",
+		squirrel.Select("u.user_id").From("users u").Join("subtree ON u.manager_id = subtree.user_id").Where(squirrel.Eq{"u.deleted_at": nil}),
+	)
+	query := r.psql.Select(Columns...).
+		PrefixExpr(squirrel.Expr("WITH RECURSIVE subtree (user_id) AS (?)", subtree)).
+		From("users").
+		Where("user_id IN (SELECT user_id FROM subtree)").
+		OrderBy("user_id")
+	if scope, ok := scopePredicate(ctx); ok {
+		query = query.Where(scope)
+	}
+	return r.selectUsers(ctx, query)
+}
+
+// checkManager fails with ErrManagerCycle or ErrUnknownManager unless user
+// id, which is zero for a user yet to be created, can report to manager. A
+// user can always keep its manager, even one deleted since. It must run
+// within a transaction.
+func (r *SQLUserRepository) checkManager(ctx context.Context, id, manager int64) error {
+	if id == manager {
+		return ErrManagerCycle
+	}
+	ctx = unscoped(ctx)
+	if id != 0 {
+		stored, err := r.Get(WithDeleted(ctx), id)
+		if err == nil && stored.ManagerID != nil && *stored.ManagerID == manager {
+			return nil
+		}
+	}
+
+	// Keep concurrent writes from making users report to each other.
+	if r.dialect.LockManagers != "" {
+		if _, err := r.q.ExecContext(ctx, r.dialect.LockManagers); err != nil {
+			return err
+		}
+	}
+	if _, err := r.Get(ctx, manager); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrUnknownManager
+		}
+		return err
+	}
+	if id == 0 {
+		return nil
+	}
+
+	// Deleted users are followed too, as restoring them brings their lines
+	// back.
+	chain := squirrel.ConcatExpr(
+		squirrel.Select("manager_id").From("users").Where(squirrel.Eq{"user_id": manager}),
+		" UNION ",
+		squirrel.Select("m.manager_id").From("users m").Join("chain ON m.user_id = chain.user_id"),
+	)
+	sqlQuery, args, err := r.psql.Select("1").
+		PrefixExpr(squirrel.Expr("WITH RECURSIVE chain (user_id) AS (?)", chain)).
+		From("chain").
+		Where(squirrel.Eq{"user_id": id}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	var found int
+	err = r.q.QueryRowContext(ctx, sqlQuery, args...).Scan(&found)
+	if err == nil {
+		return ErrManagerCycle
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+// selectUsers runs query, which selects Columns, and returns the users it
+// finds that are not deleted.
+func (r *SQLUserRepository) selectUsers(ctx context.Context, query squirrel.SelectBuilder) ([]models.User, error) {
+	sqlQuery, args, err := query.Where(notDeleted).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.q.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+func (r *SQLUserRepository) Roles(ctx context.Context) ([]models.Role, error) {
+	sqlQuery, args, err := r.psql.Select("name", "description").From("roles").OrderBy("name").ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.q.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []models.Role{}
+	for rows.Next() {
+		var role models.Role
+		if err := rows.Scan(&role.Name, &role.Description); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (r *SQLUserRepository) UserRoles(ctx context.Context, userID int64) ([]models.Grant, error) {
+	if _, err := r.Get(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	sqlQuery, args, err := r.psql.Select("r.name", "ur.department").
+		From("user_roles ur").
+		Join("roles r ON r.role_id = ur.role_id").
+		Where(squirrel.Eq{"ur.user_id": userID}).
+		OrderBy("r.name", "ur.department").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.q.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []models.Grant{}
+	for rows.Next() {
+		var grant models.Grant
+		if err := rows.Scan(&grant.Role, &grant.Department); err != nil {
+			return nil, err
+		}
+		grants = append(grants, grant)
+	}
+	return grants, rows.Err()
+}
+
+func (r *SQLUserRepository) GrantRole(ctx context.Context, userID int64, grant models.Grant) error {
+	roleID, err := r.grantee(ctx, userID, grant.Role)
+	if err != nil {
+		return err
+	}
+
+	sqlQuery, args, err := r.psql.Insert("user_roles").
+		Columns("user_id", "role_id", "department").
+		Values(userID, roleID, grant.Department).
+		Suffix("ON CONFLICT (user_id, role_id, department) DO NOTHING").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, sqlQuery, args...)
+	return r.dialect.ConstraintError(err)
+}
+
+func (r *SQLUserRepository) RevokeRole(ctx context.Context, userID int64, grant models.Grant) error {
+	roleID, err := r.grantee(ctx, userID, grant.Role)
+	if err != nil {
+		return err
+	}
+
+	sqlQuery, args, err := r.psql.Delete("user_roles").
+		Where(squirrel.Eq{"user_id": userID, "role_id": roleID, "department": grant.Department}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, sqlQuery, args...)
+	return err
+}
+
+// grantee checks that user id exists and returns the id of role.
+func (r *SQLUserRepository) grantee(ctx context.Context, userID int64, role string) (int64, error) {
+	if _, err := r.Get(ctx, userID); err != nil {
+		return 0, err
+	}
+
+	sqlQuery, args, err := r.psql.Select("role_id").From("roles").Where(squirrel.Eq{"name": role}).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var roleID int64
+	err = r.q.QueryRowContext(ctx, sqlQuery, args...).Scan(&roleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrUnknownRole
+	}
+	return roleID, err
+}
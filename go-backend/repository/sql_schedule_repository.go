@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+var scheduledChangeColumns = []string{"change_id", "user_id", "user_status", "department", "reason", "effective_at", "state", "created_by", "created_at", "resolved_by", "resolved_at", "error"}
+
+func (r *SQLUserRepository) ScheduleChange(ctx context.Context, change *models.ScheduledChange) error {
+	sqlQuery, args, err := r.psql.Insert("scheduled_changes").
+		Columns(scheduledChangeColumns[1:9]...).
+		Values(change.UserID, change.UserStatus, change.Department, change.Reason, change.EffectiveAt, change.State, change.CreatedBy, change.CreatedAt).
+		Suffix("RETURNING change_id").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	return r.q.QueryRowContext(ctx, sqlQuery, args...).Scan(&change.ID)
+}
+
+func (r *SQLUserRepository) ScheduledChanges(ctx context.Context, filter ScheduleFilter) ([]models.ScheduledChange, error) {
+	query := r.psql.Select(scheduledChangeColumns...).From("scheduled_changes").OrderBy("effective_at", "change_id")
+	if filter.UserID != 0 {
+		query = query.Where(squirrel.Eq{"user_id": filter.UserID})
+	}
+	if len(filter.States) > 0 {
+		query = query.Where(squirrel.Eq{"state": filter.States})
+	}
+	if !filter.DueBy.IsZero() {
+		query = query.Where(squirrel.LtOrEq{"effective_at": filter.DueBy})
+	}
+	if scope, ok := changeScopePredicate(ctx); ok {
+		query = query.Where(scope)
+	}
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.q.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []models.ScheduledChange{}
+	for rows.Next() {
+		change, err := scanScheduledChange(rows)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, *change)
+	}
+	return changes, rows.Err()
+}
+
+func (r *SQLUserRepository) ScheduledChange(ctx context.Context, id int64) (*models.ScheduledChange, error) {
+	query := r.psql.Select(scheduledChangeColumns...).From("scheduled_changes").Where(squirrel.Eq{"change_id": id})
+	if scope, ok := changeScopePredicate(ctx); ok {
+		query = query.Where(scope)
+	}
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	change, err := scanScheduledChange(r.q.QueryRowContext(ctx, sqlQuery, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrChangeNotFound
+	}
+	return change, err
+}
+
+func (r *SQLUserRepository) ResolveScheduledChange(ctx context.Context, change *models.ScheduledChange) error {
+	query := r.psql.Update("scheduled_changes").
+		Set("state", change.State).
+		Set("resolved_by", change.ResolvedBy).
+		Set("resolved_at", change.ResolvedAt).
+		Set("error", change.Error).
+		Where(squirrel.Eq{"change_id": change.ID, "state": models.ChangePending})
+	if scope, ok := changeScopePredicate(ctx); ok {
+		query = query.Where(scope)
+	}
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	result, err := r.q.ExecContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		if _, err := r.ScheduledChange(ctx, change.ID); err != nil {
+			return err
+		}
+		return ErrChangeNotPending
+	}
+	return nil
+}
+
+// changeScopePredicate returns the predicate that confines a query on
+// scheduled changes to the users of the departments of ctx, if it is
+// confined.
+func changeScopePredicate(ctx context.Context) (squirrel.Sqlizer, bool) {
+	scope, ok := scopePredicate(ctx)
+	if !ok {
+		return nil, false
+	}
+	return squirrel.Expr("user_id IN (?)", squirrel.Select("user_id").From("users").Where(scope)), true
+}
+
+// scanScheduledChange reads a row selected with scheduledChangeColumns.
+func scanScheduledChange(row rowScanner) (*models.ScheduledChange, error) {
+	var (
+		change     models.ScheduledChange
+		resolvedAt sql.NullTime
+	)
+	if err := row.Scan(&change.ID, &change.UserID, &change.UserStatus, &change.Department, &change.Reason, &change.EffectiveAt, &change.State,
+		&change.CreatedBy, &change.CreatedAt, &change.ResolvedBy, &resolvedAt, &change.Error); err != nil {
+		return nil, err
+	}
+	inUTC(&change.EffectiveAt, &change.CreatedAt)
+	change.ResolvedAt = nullTime(resolvedAt)
+	return &change, nil
+}
@@ -0,0 +1,566 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Dialect describes what differs between the SQL databases the users table
+// can live in.
+type Dialect struct {
+	Placeholder squirrel.PlaceholderFormat
+	// ConstraintError translates a constraint violation reported by the
+	// driver into ErrUserNameTaken, ErrInvalidUser, ErrUnknownDepartment,
+	// ErrUnknownManager or their department counterparts and returns any
+	// other error unchanged.
+	ConstraintError func(error) error
+	// LockAudit is run before appending to the audit log to keep concurrent
+	// transactions from chaining onto the same entry until the transaction
+	// ends. It is empty when the database serializes writers by itself.
+	LockAudit string
+	// LockDepartments is run before moving a department under another to
+	// keep concurrent moves from making departments each other's ancestors.
+	LockDepartments string
+	// LockManagers is run before giving a user a new manager to keep
+	// concurrent writes from making users report to each other.
+	LockManagers string
+}
+
+// SQLUserRepository stores users in the users table of a SQL database.
+type SQLUserRepository struct {
+	db      *sql.DB
+	q       queryer
+	psql    squirrel.StatementBuilderType
+	dialect Dialect
+}
+
+// NewSQLUserRepository returns a repository backed by db, which speaks
+// dialect.
+func NewSQLUserRepository(db *sql.DB, dialect Dialect) *SQLUserRepository {
+	return &SQLUserRepository{
+		db:      db,
+		q:       db,
+		psql:    squirrel.StatementBuilder.PlaceholderFormat(dialect.Placeholder),
+		dialect: dialect,
+	}
+}
+
+func (r *SQLUserRepository) List(ctx context.Context, opts ListOptions) ([]models.User, int64, error) {
+	where := squirrel.And{}
+	if deleted, ok := deletedPredicate(ctx); ok {
+		where = append(where, deleted)
+	}
+	if scope, ok := scopePredicate(ctx); ok {
+		where = append(where, scope)
+	}
+	for _, f := range opts.Filters {
+		pred, err := filterPredicate(f)
+		if err != nil {
+			return nil, 0, err
+		}
+		where = append(where, pred)
+	}
+	for _, tr := range opts.Ranges {
+		pred, err := rangePredicate(tr)
+		if err != nil {
+			return nil, 0, err
+		}
+		where = append(where, pred)
+	}
+
+	countQuery := r.psql.Select("COUNT(*)").From("users")
+	for _, pred := range where {
+		countQuery = countQuery.Where(pred)
+	}
+	sqlQuery, args, err := countQuery.ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := r.q.QueryRowContext(ctx, sqlQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	before := opts.Cursor != nil && opts.Cursor.Before
+	query := r.psql.Select(Columns...).From("users")
+	for _, pred := range where {
+		query = query.Where(pred)
+	}
+	if opts.Cursor != nil {
+		keyset, err := keysetPredicate(opts.Sort, opts.Cursor)
+		if err != nil {
+			return nil, 0, err
+		}
+		query = query.Where(keyset)
+	} else if opts.Offset > 0 {
+		query = query.Offset(opts.Offset)
+	}
+	orderBy, err := orderByClauses(opts.Sort, before)
+	if err != nil {
+		return nil, 0, err
+	}
+	query = query.OrderBy(orderBy...)
+	if opts.Limit > 0 {
+		query = query.Limit(opts.Limit)
+	}
+
+	sqlQuery, args, err = query.ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.q.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, *user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// Rows before a cursor are fetched nearest first; put them back in the
+	// requested order.
+	if before {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+	return users, total, nil
+}
+
+func (r *SQLUserRepository) Get(ctx context.Context, id int64) (*models.User, error) {
+	if deleted, ok := deletedPredicate(ctx); ok {
+		return r.find(ctx, squirrel.And{squirrel.Eq{"user_id": id}, deleted})
+	}
+	return r.find(ctx, squirrel.Eq{"user_id": id})
+}
+
+// GetByUsername never returns a deleted user, since user names only identify
+// the users that are not deleted.
+func (r *SQLUserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.find(ctx, squirrel.And{squirrel.Eq{"user_name": username}, notDeleted})
+}
+
+func (r *SQLUserRepository) Create(ctx context.Context, user *models.User) error {
+	if !inScope(ctx, user.Department) {
+		return ErrOutOfScope
+	}
+	if user.ManagerID == nil {
+		return r.create(ctx, user)
+	}
+	return r.WithTx(ctx, func(tx UserRepository) error {
+		if err := tx.(*SQLUserRepository).checkManager(ctx, 0, *user.ManagerID); err != nil {
+			return err
+		}
+		return tx.(*SQLUserRepository).create(ctx, user)
+	})
+}
+
+// create is Create once the manager of user has been checked.
+func (r *SQLUserRepository) create(ctx context.Context, user *models.User) error {
+	now, actor := stamp(ctx)
+	query := r.psql.Insert("users").
+		Columns(WritableColumns...).
+		Columns("created_at", "created_by", "updated_at", "updated_by").
+		Values(user.UserName, user.FirstName, user.LastName, user.Email, user.UserStatus, user.Department, columnArg(*user, "manager_id"), now, actor, now, actor).
+		Suffix("RETURNING user_id, version")
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	if err := r.q.QueryRowContext(ctx, sqlQuery, args...).Scan(&user.UserID, &user.Version); err != nil {
+		return r.dialect.ConstraintError(err)
+	}
+	user.CreatedAt, user.CreatedBy = now, actor
+	user.UpdatedAt, user.UpdatedBy = now, actor
+	return nil
+}
+
+func (r *SQLUserRepository) Update(ctx context.Context, user *models.User, columns []string, pre *Precondition) error {
+	if len(columns) == 0 {
+		columns = WritableColumns
+	}
+	if !slices.Contains(columns, "manager_id") || user.ManagerID == nil {
+		return r.update(ctx, user, columns, pre)
+	}
+	return r.WithTx(ctx, func(tx UserRepository) error {
+		if err := tx.(*SQLUserRepository).checkManager(ctx, user.UserID, *user.ManagerID); err != nil {
+			return err
+		}
+		return tx.(*SQLUserRepository).update(ctx, user, columns, pre)
+	})
+}
+
+// update is Update once the manager of user, if written, has been checked.
+func (r *SQLUserRepository) update(ctx context.Context, user *models.User, columns []string, pre *Precondition) error {
+	query := r.psql.Update("users")
+	for _, column := range columns {
+		if !isWritable(column) {
+			return fmt.Errorf("column %q cannot be updated", column)
+		}
+		if column == "department" && !inScope(ctx, user.Department) {
+			return ErrOutOfScope
+		}
+		query = query.Set(column, columnArg(*user, column))
+	}
+	now, actor := stamp(ctx)
+	query = query.
+		Set("updated_at", now).
+		Set("updated_by", actor).
+		Set("version", squirrel.Expr("version + 1")).
+		Where(squirrel.Eq{"user_id": user.UserID}).
+		Where(notDeleted)
+	if scope, ok := scopePredicate(ctx); ok {
+		query = query.Where(scope)
+	}
+	if pre != nil {
+		query = query.Where(squirrel.Eq{"version": pre.Versions})
+	}
+
+	sqlQuery, args, err := query.Suffix("RETURNING version").ToSql()
+	if err != nil {
+		return err
+	}
+
+	err = r.q.QueryRowContext(ctx, sqlQuery, args...).Scan(&user.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return r.missingOrModified(ctx, user.UserID, pre)
+	}
+	if err != nil {
+		return r.dialect.ConstraintError(err)
+	}
+	user.UpdatedAt, user.UpdatedBy = now, actor
+	return nil
+}
+
+func (r *SQLUserRepository) Delete(ctx context.Context, id int64, pre *Precondition) error {
+	now, actor := stamp(ctx)
+	query := r.psql.Update("users").
+		Set("deleted_at", now).
+		Set("updated_at", now).
+		Set("updated_by", actor).
+		Set("version", squirrel.Expr("version + 1")).
+		Where(squirrel.Eq{"user_id": id}).
+		Where(notDeleted)
+	if scope, ok := scopePredicate(ctx); ok {
+		query = query.Where(scope)
+	}
+	if pre != nil {
+		query = query.Where(squirrel.Eq{"version": pre.Versions})
+	}
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	result, err := r.q.ExecContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return r.missingOrModified(ctx, id, pre)
+	}
+	return nil
+}
+
+func (r *SQLUserRepository) Restore(ctx context.Context, id int64, pre *Precondition) (*models.User, error) {
+	now, actor := stamp(ctx)
+	query := r.psql.Update("users").
+		Set("deleted_at", nil).
+		Set("updated_at", now).
+		Set("updated_by", actor).
+		Set("version", squirrel.Expr("version + 1")).
+		Where(squirrel.Eq{"user_id": id}).
+		Where(squirrel.NotEq{"deleted_at": nil})
+	if scope, ok := scopePredicate(ctx); ok {
+		query = query.Where(scope)
+	}
+	if pre != nil {
+		query = query.Where(squirrel.Eq{"version": pre.Versions})
+	}
+
+	sqlQuery, args, err := query.Suffix("RETURNING " + strings.Join(Columns, ", ")).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := scanUser(r.q.QueryRowContext(ctx, sqlQuery, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, r.notRestorable(ctx, id)
+	}
+	if err != nil {
+		return nil, r.dialect.ConstraintError(err)
+	}
+	return user, nil
+}
+
+func (r *SQLUserRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	query := r.psql.Delete("users").Where(squirrel.Lt{"deleted_at": before.UTC()})
+	if scope, ok := scopePredicate(ctx); ok {
+		query = query.Where(scope)
+	}
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.q.ExecContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// WithTx runs fn in a new transaction, or in the current one when r is
+// already transactional.
+func (r *SQLUserRepository) WithTx(ctx context.Context, fn func(UserRepository) error) error {
+	if _, ok := r.q.(*sql.Tx); ok {
+		return fn(r)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&SQLUserRepository{db: r.db, q: tx, psql: r.psql, dialect: r.dialect}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// missingOrModified explains why a write of user id matched no rows: either
+// the user does not exist or, for a conditional write, its version no longer
+// matches.
+func (r *SQLUserRepository) missingOrModified(ctx context.Context, id int64, pre *Precondition) error {
+	if pre == nil {
+		return ErrNotFound
+	}
+	if _, err := r.Get(ctx, id); err != nil {
+		return err
+	}
+	return ErrVersionMismatch
+}
+
+// notRestorable explains why restoring user id matched no rows: the user
+// does not exist, is not deleted or, for a conditional restore, its version
+// no longer matches.
+func (r *SQLUserRepository) notRestorable(ctx context.Context, id int64) error {
+	user, err := r.Get(WithDeleted(ctx), id)
+	if err != nil {
+		return err
+	}
+	if user.DeletedAt == nil {
+		return ErrNotDeleted
+	}
+	return ErrVersionMismatch
+}
+
+// find returns the single user matching pred.
+func (r *SQLUserRepository) find(ctx context.Context, pred squirrel.Sqlizer) (*models.User, error) {
+	query := r.psql.Select(Columns...).From("users").Where(pred)
+	if scope, ok := scopePredicate(ctx); ok {
+		query = query.Where(scope)
+	}
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := scanUser(r.q.QueryRowContext(ctx, sqlQuery, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return user, err
+}
+
+// notDeleted matches the users that are not soft deleted.
+var notDeleted = squirrel.Eq{"deleted_at": nil}
+
+// deletedPredicate returns the predicate that hides the soft deleted users
+// ctx does not include, if it hides any.
+func deletedPredicate(ctx context.Context) (squirrel.Sqlizer, bool) {
+	departments, scoped, include := deletedScopeOf(ctx)
+	switch {
+	case !include:
+		return notDeleted, true
+	case scoped:
+		return squirrel.Or{notDeleted, squirrel.Eq{"department": departments}}, true
+	}
+	return nil, false
+}
+
+// scopePredicate returns the predicate that confines a query on users to
+// the departments of ctx, if it is confined.
+func scopePredicate(ctx context.Context) (squirrel.Sqlizer, bool) {
+	departments, scoped := scopeOf(ctx)
+	if !scoped {
+		return nil, false
+	}
+	return squirrel.Eq{"department": departments}, true
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser reads a row selected with Columns.
+func scanUser(row rowScanner) (*models.User, error) {
+	var (
+		user          models.User
+		effectiveDate sql.NullTime
+		managerID     sql.NullInt64
+	)
+	if err := row.Scan(&user.UserID, &user.UserName, &user.FirstName, &user.LastName, &user.Email, &user.UserStatus, &user.Department, &user.Version, &user.DeletedAt,
+		&user.StatusReason, &effectiveDate, &user.CreatedAt, &user.CreatedBy, &user.UpdatedAt, &user.UpdatedBy, &managerID); err != nil {
+		return nil, err
+	}
+	inUTC(user.DeletedAt, &user.CreatedAt, &user.UpdatedAt)
+	if effectiveDate.Valid {
+		user.StatusEffectiveDate = effectiveDate.Time.Format(models.DateLayout)
+	}
+	if managerID.Valid {
+		user.ManagerID = &managerID.Int64
+	}
+	return &user, nil
+}
+
+// columnArg returns the value of column for user as a query argument. An
+// empty date is stored as NULL.
+func columnArg(user models.User, column string) interface{} {
+	if column == "status_effective_date" && user.StatusEffectiveDate == "" {
+		return nil
+	}
+	return ColumnValue(user, column)
+}
+
+func filterPredicate(f Filter) (squirrel.Sqlizer, error) {
+	if !isColumn(f.Column) {
+		return nil, fmt.Errorf("unknown column %q", f.Column)
+	}
+	switch {
+	case f.Prefix:
+		return squirrel.Like{f.Column: likeEscaper.Replace(f.Values[0]) + "%"}, nil
+	case len(f.Values) == 1:
+		return squirrel.Eq{f.Column: f.Values[0]}, nil
+	default:
+		return squirrel.Eq{f.Column: f.Values}, nil
+	}
+}
+
+func rangePredicate(tr TimeRange) (squirrel.Sqlizer, error) {
+	if !isColumn(tr.Column) {
+		return nil, fmt.Errorf("unknown column %q", tr.Column)
+	}
+	and := squirrel.And{}
+	if !tr.Since.IsZero() {
+		and = append(and, squirrel.GtOrEq{tr.Column: tr.Since.UTC()})
+	}
+	if !tr.Until.IsZero() {
+		and = append(and, squirrel.Lt{tr.Column: tr.Until.UTC()})
+	}
+	return and, nil
+}
+
+// orderByClauses returns the ORDER BY clauses for sort. When reverse is set
+// the direction of every column is flipped, which is used to fetch the rows
+// that precede a cursor.
+func orderByClauses(sort []SortField, reverse bool) ([]string, error) {
+	clauses := make([]string, len(sort))
+	for i, f := range sort {
+		if !isColumn(f.Column) {
+			return nil, fmt.Errorf("unknown column %q", f.Column)
+		}
+		if f.Desc != reverse {
+			clauses[i] = f.Column + " DESC"
+		} else {
+			clauses[i] = f.Column + " ASC"
+		}
+	}
+	return clauses, nil
+}
+
+// keysetPredicate builds the predicate selecting the rows strictly after (or
+// before) the cursor in the given sort order:
+//
+//	(a > ?) OR (a = ? AND b > ?) OR (a = ? AND b = ? AND c > ?)
+func keysetPredicate(sort []SortField, cursor *Cursor) (squirrel.Sqlizer, error) {
+	or := squirrel.Or{}
+	for i, f := range sort {
+		key, ok := cursor.Keys[f.Column]
+		if !ok {
+			return nil, fmt.Errorf("cursor has no value for %q", f.Column)
+		}
+		and := squirrel.And{}
+		for _, prev := range sort[:i] {
+			and = append(and, squirrel.Eq{prev.Column: cursor.Keys[prev.Column]})
+		}
+		if f.Desc != cursor.Before {
+			and = append(and, squirrel.Lt{f.Column: key})
+		} else {
+			and = append(and, squirrel.Gt{f.Column: key})
+		}
+		or = append(or, and)
+	}
+	return or, nil
+}
+
+func isColumn(column string) bool {
+	for _, c := range Columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+func isWritable(column string) bool {
+	for _, c := range WritableColumns {
+		if c == column {
+			return true
+		}
+	}
+	for _, c := range StatusColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
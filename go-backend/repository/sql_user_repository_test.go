@@ -0,0 +1,240 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+func TestRepository(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Repository Suite")
+}
+
+var _ = Describe("SQLUserRepository", func() {
+	var (
+		database *sql.DB
+		mock     sqlmock.Sqlmock
+		repo     *repository.SQLUserRepository
+		ctx      context.Context
+	)
+
+	userRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows(repository.Columns)
+	}
+
+	BeforeEach(func() {
+		var err error
+		database, mock, err = sqlmock.New()
+		Expect(err).NotTo(HaveOccurred())
+		repo = repository.NewPostgresUserRepository(database)
+		ctx = context.Background()
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+		database.Close()
+	})
+
+	Describe("List", func() {
+		It("should return the rows before a cursor in sort order", func() {
+			mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+			mock.ExpectQuery("SELECT (.+) FROM users WHERE deleted_at IS NULL AND \\(\\(user_id < \\$1\\)\\) ORDER BY user_id DESC LIMIT 2").
+				WithArgs(int64(3)).
+				WillReturnRows(userRows().
+					AddRow(2, "b", "B", "B", "b@example.com", "A", "HR", 1, nil, "", nil, time.Time{}, "", time.Time{}, "", nil).
+					AddRow(1, "a", "A", "A", "a@example.com", "A", "HR", 1, nil, "", nil, time.Time{}, "", time.Time{}, "", nil))
+
+			users, total, err := repo.List(ctx, repository.ListOptions{
+				Sort:   []repository.SortField{{Column: "user_id"}},
+				Limit:  2,
+				Cursor: &repository.Cursor{Keys: map[string]interface{}{"user_id": int64(3)}, Before: true},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(int64(3)))
+			Expect(users).To(HaveLen(2))
+			Expect(users[0].UserID).To(Equal(int64(1)))
+			Expect(users[1].UserID).To(Equal(int64(2)))
+		})
+
+		It("should confine the count and the page to the departments of the context", func() {
+			mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users WHERE deleted_at IS NULL AND department IN \\(\\$1,\\$2\\) AND user_status = \\$3").
+				WithArgs("HR", "Sales", "A").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+			mock.ExpectQuery("SELECT (.+) FROM users WHERE deleted_at IS NULL AND department IN \\(\\$1,\\$2\\) AND user_status = \\$3 ORDER BY user_id").
+				WithArgs("HR", "Sales", "A").
+				WillReturnRows(userRows())
+
+			_, _, err := repo.List(repository.WithDepartments(ctx, []string{"HR", "Sales"}), repository.ListOptions{
+				Filters: []repository.Filter{{Column: "user_status", Values: []string{"A"}}},
+				Sort:    []repository.SortField{{Column: "user_id"}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should reject unknown columns", func() {
+			_, _, err := repo.List(ctx, repository.ListOptions{
+				Sort: []repository.SortField{{Column: "password; DROP TABLE users"}},
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Get", func() {
+		It("should report a missing user as ErrNotFound", func() {
+			mock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").
+				WithArgs(int64(9)).
+				WillReturnError(sql.ErrNoRows)
+
+			_, err := repo.Get(ctx, 9)
+			Expect(err).To(MatchError(repository.ErrNotFound))
+		})
+	})
+
+	Describe("Update", func() {
+		It("should write only the requested columns", func() {
+			mock.ExpectQuery("UPDATE users SET email = \\$1, updated_at = \\$2, updated_by = \\$3, version = version \\+ 1 WHERE user_id = \\$4 AND deleted_at IS NULL RETURNING version").
+				WithArgs("new@example.com", sqlmock.AnyArg(), "", int64(4)).
+				WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+
+			user := &models.User{UserID: 4, Email: "new@example.com", Version: 1}
+			Expect(repo.Update(ctx, user, []string{"email"}, nil)).To(Succeed())
+			Expect(user.Version).To(Equal(int64(2)))
+		})
+
+		It("should refuse to write the id or version", func() {
+			user := &models.User{UserID: 4}
+			Expect(repo.Update(ctx, user, []string{"user_id"}, nil)).NotTo(Succeed())
+			Expect(repo.Update(ctx, user, []string{"version"}, nil)).NotTo(Succeed())
+		})
+
+		It("should only update a user of the departments of the context", func() {
+			mock.ExpectQuery("UPDATE users SET first_name = \\$1, updated_at = \\$2, updated_by = \\$3, version = version \\+ 1 WHERE user_id = \\$4 AND deleted_at IS NULL AND department IN \\(\\$5\\) RETURNING version").
+				WithArgs("Jane", sqlmock.AnyArg(), "", int64(3), "HR").
+				WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+
+			user := &models.User{UserID: 3, FirstName: "Jane"}
+			Expect(repo.Update(repository.WithDepartments(ctx, []string{"HR"}), user, []string{"first_name"}, nil)).To(Succeed())
+		})
+
+		It("should tell a stale version from a missing user", func() {
+			mock.ExpectQuery("UPDATE users SET (.+) WHERE user_id = \\$10 AND deleted_at IS NULL AND version IN \\(\\$11\\) RETURNING version").
+				WillReturnError(sql.ErrNoRows)
+			mock.ExpectQuery("SELECT (.+) FROM users WHERE \\(user_id = \\$1 AND deleted_at IS NULL\\)").
+				WillReturnRows(userRows().AddRow(4, "a", "A", "A", "a@example.com", "A", "HR", 3, nil, "", nil, time.Time{}, "", time.Time{}, "", nil))
+
+			user := &models.User{UserID: 4, UserName: "a", FirstName: "A", LastName: "A", Email: "a@example.com", UserStatus: "A", Department: "HR"}
+			err := repo.Update(ctx, user, nil, &repository.Precondition{Versions: []int64{2}})
+			Expect(err).To(MatchError(repository.ErrVersionMismatch))
+		})
+	})
+
+	Describe("Restore", func() {
+		It("should clear deleted_at and return the restored user", func() {
+			mock.ExpectQuery("UPDATE users SET deleted_at = \\$1, updated_at = \\$2, updated_by = \\$3, version = version \\+ 1 WHERE user_id = \\$4 AND deleted_at IS NOT NULL RETURNING user_id, (.+), deleted_at").
+				WithArgs(nil, sqlmock.AnyArg(), "", int64(4)).
+				WillReturnRows(userRows().AddRow(4, "a", "A", "A", "a@example.com", "A", "HR", 3, nil, "", nil, time.Time{}, "", time.Time{}, "", nil))
+
+			user, err := repo.Restore(ctx, 4, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.Version).To(Equal(int64(3)))
+			Expect(user.DeletedAt).To(BeNil())
+		})
+
+		It("should tell a user that is not deleted from a missing one", func() {
+			mock.ExpectQuery("UPDATE users SET (.+) WHERE user_id = \\$4 AND deleted_at IS NOT NULL").
+				WillReturnError(sql.ErrNoRows)
+			mock.ExpectQuery("SELECT (.+) FROM users WHERE user_id = \\$1$").
+				WithArgs(int64(4)).
+				WillReturnRows(userRows().AddRow(4, "a", "A", "A", "a@example.com", "A", "HR", 2, nil, "", nil, time.Time{}, "", time.Time{}, "", nil))
+
+			_, err := repo.Restore(ctx, 4, nil)
+			Expect(err).To(MatchError(repository.ErrNotDeleted))
+		})
+	})
+
+	Describe("Purge", func() {
+		It("should delete the users deleted before the cutoff", func() {
+			cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			mock.ExpectExec("DELETE FROM users WHERE deleted_at < \\$1").
+				WithArgs(cutoff).
+				WillReturnResult(sqlmock.NewResult(0, 2))
+
+			purged, err := repo.Purge(ctx, cutoff)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(purged).To(Equal(int64(2)))
+		})
+	})
+
+	Describe("ScheduledChanges", func() {
+		It("should number the placeholders of the department scope after the filters", func() {
+			due := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			mock.ExpectQuery("SELECT (.+) FROM scheduled_changes WHERE state IN \\(\\$1\\) AND effective_at <= \\$2 AND user_id IN \\(SELECT user_id FROM users WHERE department IN \\(\\$3,\\$4\\)\\) ORDER BY effective_at, change_id").
+				WithArgs(models.ChangePending, due, "HR", "Sales").
+				WillReturnRows(sqlmock.NewRows([]string{"change_id", "user_id", "user_status", "department", "reason", "effective_at", "state", "created_by", "created_at", "resolved_by", "resolved_at", "error"}).
+					AddRow(1, 4, "T", "", "Retiring", due, models.ChangePending, "alice", due, "", nil, ""))
+
+			changes, err := repo.ScheduledChanges(repository.WithDepartments(ctx, []string{"HR", "Sales"}), repository.ScheduleFilter{
+				States: []string{models.ChangePending},
+				DueBy:  due,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(HaveLen(1))
+			Expect(changes[0].UserStatus).To(Equal("T"))
+			Expect(changes[0].ResolvedAt).To(BeNil())
+		})
+	})
+
+	Describe("WithTx", func() {
+		It("should commit when the function succeeds", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec("UPDATE users SET deleted_at = \\$1, updated_at = \\$2, updated_by = \\$3, version = version \\+ 1 WHERE user_id = \\$4 AND deleted_at IS NULL").
+				WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "", int64(1)).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			err := repo.WithTx(ctx, func(tx repository.UserRepository) error {
+				return tx.Delete(ctx, 1, nil)
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should roll back when the function fails", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec("UPDATE users SET deleted_at = (.+) WHERE user_id = \\$4 AND deleted_at IS NULL").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectRollback()
+
+			failure := errors.New("stop")
+			err := repo.WithTx(ctx, func(tx repository.UserRepository) error {
+				if err := tx.Delete(ctx, 1, nil); err != nil {
+					return err
+				}
+				return failure
+			})
+			Expect(err).To(MatchError(failure))
+		})
+
+		It("should reuse the open transaction when nested", func() {
+			mock.ExpectBegin()
+			mock.ExpectCommit()
+
+			err := repo.WithTx(ctx, func(tx repository.UserRepository) error {
+				return tx.WithTx(ctx, func(inner repository.UserRepository) error {
+					Expect(inner).To(BeIdenticalTo(tx))
+					return nil
+				})
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLite is the dialect of the users table in SQLite.
+var SQLite = Dialect{
+	Placeholder:     squirrel.Question,
+	ConstraintError: sqliteConstraintError,
+}
+
+// NewSQLiteUserRepository returns a repository backed by a SQLite db.
+func NewSQLiteUserRepository(db *sql.DB) *SQLUserRepository {
+	return NewSQLUserRepository(db, SQLite)
+}
+
+func sqliteConstraintError(err error) error {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+	// SQLite names the columns of a unique constraint and the name of a
+	// check constraint, if it has one, in the message.
+	message := sqliteErr.Error()
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+		switch {
+		case strings.Contains(message, "departments.code"):
+			return ErrDepartmentCodeTaken
+		case strings.Contains(message, "departments.name"):
+			return ErrDepartmentNameTaken
+		}
+		return ErrUserNameTaken
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		// SQLite does not say which foreign key failed. Managers are checked
+		// before they are written, within the same transaction, so it can
+		// only be the department.
+		return ErrUnknownDepartment
+	case sqlite3.SQLITE_CONSTRAINT_CHECK:
+		if strings.Contains(message, "departments_") {
+			return fmt.Errorf("%w: %s", ErrInvalidDepartment, message)
+		}
+		return fmt.Errorf("%w: %s", ErrInvalidUser, message)
+	}
+	return err
+}
@@ -0,0 +1,172 @@
+// Package repository hides how users are stored from the HTTP handlers and
+// the admin commands.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+var (
+	// ErrNotFound is returned when no user matches the requested key.
+	ErrNotFound = errors.New("user not found")
+	// ErrUserNameTaken is returned when a write would duplicate a user name.
+	ErrUserNameTaken = errors.New("user name already exists")
+	// ErrInvalidUser is returned when a write breaks a column constraint,
+	// such as a value that is too long or an unknown user_status.
+	ErrInvalidUser = errors.New("user violates a column constraint")
+	// ErrVersionMismatch is returned when a conditional write finds the user
+	// at a version other than the ones it was conditioned on.
+	ErrVersionMismatch = errors.New("user has been modified")
+	// ErrNotDeleted is returned when restoring a user that is not deleted.
+	ErrNotDeleted = errors.New("user is not deleted")
+)
+
+// Columns lists the stored user columns in a stable order.
+var Columns = []string{"user_id", "user_name", "first_name", "last_name", "email", "user_status", "department", "version", "deleted_at", "status_reason", "status_effective_date",
+	"created_at", "created_by", "updated_at", "updated_by", "manager_id"}
+
+// WritableColumns lists the columns that Update changes by default.
+var WritableColumns = []string{"user_name", "first_name", "last_name", "email", "user_status", "department", "manager_id"}
+
+// StatusColumns lists the columns that record a change of user_status. Update
+// only writes them when asked to.
+var StatusColumns = []string{"user_status", "status_reason", "status_effective_date"}
+
+// UserRepository stores users, the departments they belong to, the managers
+// they report to, the roles granted to them, the log of their changes, the
+// changes scheduled for them and the API keys that act on them.
+type UserRepository interface {
+	DepartmentRepository
+	HierarchyRepository
+	RoleRepository
+	APIKeyRepository
+	AuditRepository
+	ScheduleRepository
+
+	// List returns one page of users matching opts together with the number
+	// of users matching its filters, ignoring the paging options.
+	List(ctx context.Context, opts ListOptions) ([]models.User, int64, error)
+	Get(ctx context.Context, id int64) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	// Create inserts user and stores the assigned id and version on it,
+	// along with the time it was created and the actor of ctx as both its
+	// creation and its last write. Create and Update fail with
+	// ErrUnknownDepartment unless the user's department exists, and with
+	// ErrUnknownManager or ErrManagerCycle when they give it a manager who
+	// is not a user or who reports to it.
+	Create(ctx context.Context, user *models.User) error
+	// Update writes columns of user (every writable column when empty) to
+	// the row with user.UserID and stores the bumped version, the time of
+	// the write and the actor of ctx on user. Every write, deleting and
+	// restoring included, records its time and actor as the last write.
+	Update(ctx context.Context, user *models.User, columns []string, pre *Precondition) error
+	// Delete soft deletes a user: it is kept, with DeletedAt set, but hidden
+	// from every other call unless their context comes from WithDeleted.
+	Delete(ctx context.Context, id int64, pre *Precondition) error
+	// Restore undeletes a soft deleted user and returns it. It fails with
+	// ErrUserNameTaken when the user name has been reused since and with
+	// ErrNotDeleted when the user is not deleted.
+	Restore(ctx context.Context, id int64, pre *Precondition) (*models.User, error)
+	// Purge permanently removes the users deleted before the given time and
+	// returns how many it removed.
+	Purge(ctx context.Context, before time.Time) (int64, error)
+	// WithTx runs fn against a repository whose writes are committed
+	// together when fn returns nil and rolled back otherwise.
+	WithTx(ctx context.Context, fn func(UserRepository) error) error
+}
+
+// Precondition restricts a write to a user whose current version is one of
+// Versions. A nil *Precondition makes the write unconditional, while an empty
+// Versions list never matches.
+type Precondition struct {
+	Versions []int64
+}
+
+// SortField orders a listing by one column.
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// Filter matches users whose Column equals any of Values, or starts with
+// Values[0] when Prefix is set.
+type Filter struct {
+	Column string
+	Values []string
+	Prefix bool
+}
+
+// TimeRange matches users whose time Column is at or after Since and before
+// Until. A zero bound leaves that side open.
+type TimeRange struct {
+	Column string
+	Since  time.Time
+	Until  time.Time
+}
+
+// Cursor positions a listing strictly after, or with Before strictly before,
+// the row whose sort columns hold Keys.
+type Cursor struct {
+	Keys   map[string]interface{}
+	Before bool
+}
+
+// ListOptions selects a page of users. Rows are returned in Sort order even
+// when paging backwards from a cursor.
+type ListOptions struct {
+	Filters []Filter
+	Ranges  []TimeRange
+	Sort    []SortField
+	// Limit caps the number of rows returned; zero returns every row.
+	Limit  uint64
+	Offset uint64
+	Cursor *Cursor
+}
+
+// ColumnValue returns the value of column for user. The manager_id of a user
+// without a manager is nil, and otherwise the id rather than a pointer to
+// it, so that values can be compared.
+func ColumnValue(user models.User, column string) interface{} {
+	switch column {
+	case "user_id":
+		return user.UserID
+	case "user_name":
+		return user.UserName
+	case "first_name":
+		return user.FirstName
+	case "last_name":
+		return user.LastName
+	case "email":
+		return user.Email
+	case "user_status":
+		return user.UserStatus
+	case "department":
+		return user.Department
+	case "version":
+		return user.Version
+	case "deleted_at":
+		return user.DeletedAt
+	case "status_reason":
+		return user.StatusReason
+	case "status_effective_date":
+		return user.StatusEffectiveDate
+	case "created_at":
+		return user.CreatedAt
+	case "created_by":
+		return user.CreatedBy
+	case "updated_at":
+		return user.UpdatedAt
+	case "updated_by":
+		return user.UpdatedBy
+	case "manager_id":
+		if user.ManagerID == nil {
+			return nil
+		}
+		return *user.ManagerID
+	}
+	return nil
+}
@@ -0,0 +1,1089 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sedmo/integra-coding-assessment/go-backend/db/connectors"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// createDepartments creates a department for each of names, which users can
+// then belong to.
+func createDepartments(ctx context.Context, repo repository.DepartmentRepository, names ...string) {
+	for _, name := range names {
+		Expect(repo.CreateDepartment(ctx, &models.Department{Code: strings.ToUpper(name), Name: name})).To(Succeed())
+	}
+}
+
+// userRepositoryContract checks the behavior every UserRepository must share
+// with the Postgres schema, against the repository returned by open.
+func userRepositoryContract(open func() repository.UserRepository) {
+	var (
+		repo repository.UserRepository
+		ctx  context.Context
+	)
+
+	newUser := func(name, status, department string) *models.User {
+		return &models.User{
+			UserName:   name,
+			FirstName:  "First",
+			LastName:   "Last",
+			Email:      name + "@example.com",
+			UserStatus: status,
+			Department: department,
+		}
+	}
+
+	BeforeEach(func() {
+		repo = open()
+		ctx = context.Background()
+		createDepartments(ctx, repo, "Engineering", "HR", "Marketing", "Sales", "Support")
+	})
+
+	Describe("Create", func() {
+		It("should assign increasing ids starting at version 1", func() {
+			first, second := newUser("a", "A", "HR"), newUser("b", "A", "HR")
+			Expect(repo.Create(ctx, first)).To(Succeed())
+			Expect(repo.Create(ctx, second)).To(Succeed())
+			Expect(first.UserID).To(Equal(int64(1)))
+			Expect(second.UserID).To(Equal(int64(2)))
+			Expect(second.Version).To(Equal(int64(1)))
+		})
+
+		It("should reject a duplicate user name", func() {
+			Expect(repo.Create(ctx, newUser("a", "A", "HR"))).To(Succeed())
+			Expect(repo.Create(ctx, newUser("a", "I", "Sales"))).To(MatchError(repository.ErrUserNameTaken))
+		})
+
+		It("should not reuse the id of a deleted user", func() {
+			first, second := newUser("a", "A", "HR"), newUser("b", "A", "HR")
+			Expect(repo.Create(ctx, first)).To(Succeed())
+			Expect(repo.Create(ctx, second)).To(Succeed())
+			Expect(repo.Delete(ctx, second.UserID, nil)).To(Succeed())
+
+			next := newUser("c", "A", "HR")
+			Expect(repo.Create(ctx, next)).To(Succeed())
+			Expect(next.UserID).To(Equal(int64(3)))
+		})
+
+		It("should enforce the user_status check and column lengths", func() {
+			Expect(repo.Create(ctx, newUser("a", "X", "HR"))).To(MatchError(repository.ErrInvalidUser))
+			Expect(repo.Create(ctx, newUser(strings.Repeat("a", 51), "A", "HR"))).To(MatchError(repository.ErrInvalidUser))
+		})
+
+		It("should be safe for concurrent use", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					Expect(repo.Create(ctx, newUser(fmt.Sprintf("user%d", i), "A", "HR"))).To(Succeed())
+				}(i)
+			}
+			wg.Wait()
+
+			users, total, err := repo.List(ctx, repository.ListOptions{Sort: []repository.SortField{{Column: "user_id"}}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(int64(50)))
+			Expect(users[49].UserID).To(Equal(int64(50)))
+		})
+	})
+
+	Describe("Update", func() {
+		var user *models.User
+
+		BeforeEach(func() {
+			user = newUser("a", "A", "HR")
+			Expect(repo.Create(ctx, user)).To(Succeed())
+		})
+
+		It("should write only the requested columns and bump the version", func() {
+			changed := *user
+			changed.Department = "Sales"
+			changed.FirstName = "Ignored"
+			Expect(repo.Update(ctx, &changed, []string{"department"}, nil)).To(Succeed())
+			Expect(changed.Version).To(Equal(int64(2)))
+
+			stored, err := repo.Get(ctx, user.UserID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored.Department).To(Equal("Sales"))
+			Expect(stored.FirstName).To(Equal("First"))
+		})
+
+		It("should honour the precondition", func() {
+			pre := &repository.Precondition{Versions: []int64{7}}
+			Expect(repo.Update(ctx, user, nil, pre)).To(MatchError(repository.ErrVersionMismatch))
+			Expect(repo.Delete(ctx, user.UserID, pre)).To(MatchError(repository.ErrVersionMismatch))
+			Expect(repo.Delete(ctx, 99, pre)).To(MatchError(repository.ErrNotFound))
+		})
+
+		It("should write the status columns only when asked to", func() {
+			changed := *user
+			changed.UserStatus = models.StatusTerminated
+			changed.StatusReason = "Left the company"
+			changed.StatusEffectiveDate = "2024-06-30"
+			Expect(repo.Update(ctx, &changed, nil, nil)).To(Succeed())
+			stored, err := repo.Get(ctx, user.UserID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored.UserStatus).To(Equal(models.StatusTerminated))
+			Expect(stored.StatusReason).To(BeEmpty())
+			Expect(stored.StatusEffectiveDate).To(BeEmpty())
+
+			Expect(repo.Update(ctx, &changed, repository.StatusColumns, nil)).To(Succeed())
+			stored, err = repo.Get(ctx, user.UserID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored.StatusReason).To(Equal("Left the company"))
+			Expect(stored.StatusEffectiveDate).To(Equal("2024-06-30"))
+
+			changed.StatusReason = strings.Repeat("x", 256)
+			Expect(repo.Update(ctx, &changed, []string{"status_reason"}, nil)).To(MatchError(repository.ErrInvalidUser))
+		})
+
+		It("should reject renaming to a taken user name", func() {
+			Expect(repo.Create(ctx, newUser("b", "A", "HR"))).To(Succeed())
+			user.UserName = "b"
+			Expect(repo.Update(ctx, user, []string{"user_name"}, nil)).To(MatchError(repository.ErrUserNameTaken))
+		})
+	})
+
+	Describe("List", func() {
+		BeforeEach(func() {
+			for _, u := range []*models.User{
+				newUser("carol", "A", "Sales"),
+				newUser("alice", "I", "HR"),
+				newUser("bob", "A", "Sales"),
+				newUser("dave", "A", "Support"),
+			} {
+				Expect(repo.Create(ctx, u)).To(Succeed())
+			}
+		})
+
+		names := func(users []models.User) []string {
+			var out []string
+			for _, u := range users {
+				out = append(out, u.UserName)
+			}
+			return out
+		}
+
+		It("should filter, sort and count", func() {
+			users, total, err := repo.List(ctx, repository.ListOptions{
+				Filters: []repository.Filter{
+					{Column: "user_status", Values: []string{"A"}},
+					{Column: "department", Values: []string{"S"}, Prefix: true},
+				},
+				Sort:  []repository.SortField{{Column: "department"}, {Column: "user_name", Desc: true}, {Column: "user_id"}},
+				Limit: 2,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(int64(3)))
+			Expect(names(users)).To(Equal([]string{"carol", "bob"}))
+		})
+
+		It("should page forwards and backwards from a cursor", func() {
+			sort := []repository.SortField{{Column: "user_name"}, {Column: "user_id"}}
+			keys := map[string]interface{}{"user_name": "bob", "user_id": int64(3)}
+
+			after, _, err := repo.List(ctx, repository.ListOptions{Sort: sort, Limit: 2, Cursor: &repository.Cursor{Keys: keys}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(after)).To(Equal([]string{"carol", "dave"}))
+
+			keys = map[string]interface{}{"user_name": "dave", "user_id": int64(4)}
+			before, _, err := repo.List(ctx, repository.ListOptions{Sort: sort, Limit: 2, Cursor: &repository.Cursor{Keys: keys, Before: true}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(before)).To(Equal([]string{"bob", "carol"}))
+		})
+	})
+
+	Describe("WithTx", func() {
+		It("should discard every write when the function fails", func() {
+			failure := errors.New("stop")
+			err := repo.WithTx(ctx, func(tx repository.UserRepository) error {
+				Expect(tx.Create(ctx, newUser("a", "A", "HR"))).To(Succeed())
+				return failure
+			})
+			Expect(err).To(MatchError(failure))
+
+			_, err = repo.GetByUsername(ctx, "a")
+			Expect(err).To(MatchError(repository.ErrNotFound))
+		})
+
+		It("should keep the writes when the function succeeds", func() {
+			err := repo.WithTx(ctx, func(tx repository.UserRepository) error {
+				return tx.Create(ctx, newUser("a", "A", "HR"))
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = repo.GetByUsername(ctx, "a")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("Roles", func() {
+		viewer := models.Grant{Role: "viewer"}
+		admin := models.Grant{Role: "admin"}
+		salesEditor := models.Grant{Role: "editor", Department: "Sales"}
+
+		It("should list the default roles", func() {
+			Expect(repo.Roles(ctx)).To(Equal(repository.DefaultRoles))
+		})
+
+		It("should grant and revoke roles idempotently", func() {
+			user := newUser("a", "A", "HR")
+			Expect(repo.Create(ctx, user)).To(Succeed())
+			Expect(repo.UserRoles(ctx, user.UserID)).To(BeEmpty())
+
+			Expect(repo.GrantRole(ctx, user.UserID, viewer)).To(Succeed())
+			Expect(repo.GrantRole(ctx, user.UserID, admin)).To(Succeed())
+			Expect(repo.GrantRole(ctx, user.UserID, admin)).To(Succeed())
+			Expect(repo.UserRoles(ctx, user.UserID)).To(Equal([]models.Grant{admin, viewer}))
+
+			Expect(repo.RevokeRole(ctx, user.UserID, viewer)).To(Succeed())
+			Expect(repo.RevokeRole(ctx, user.UserID, models.Grant{Role: "editor"})).To(Succeed())
+			Expect(repo.UserRoles(ctx, user.UserID)).To(Equal([]models.Grant{admin}))
+		})
+
+		It("should keep grants of the same role in different departments apart", func() {
+			user := newUser("a", "A", "HR")
+			Expect(repo.Create(ctx, user)).To(Succeed())
+			hrEditor := models.Grant{Role: "editor", Department: "HR"}
+			Expect(repo.GrantRole(ctx, user.UserID, salesEditor)).To(Succeed())
+			Expect(repo.GrantRole(ctx, user.UserID, hrEditor)).To(Succeed())
+			Expect(repo.GrantRole(ctx, user.UserID, models.Grant{Role: "editor"})).To(Succeed())
+			Expect(repo.UserRoles(ctx, user.UserID)).To(Equal([]models.Grant{{Role: "editor"}, hrEditor, salesEditor}))
+
+			Expect(repo.RevokeRole(ctx, user.UserID, salesEditor)).To(Succeed())
+			Expect(repo.UserRoles(ctx, user.UserID)).To(Equal([]models.Grant{{Role: "editor"}, hrEditor}))
+		})
+
+		It("should reject unknown roles and users", func() {
+			user := newUser("a", "A", "HR")
+			Expect(repo.Create(ctx, user)).To(Succeed())
+			Expect(repo.GrantRole(ctx, user.UserID, models.Grant{Role: "owner"})).To(MatchError(repository.ErrUnknownRole))
+			Expect(repo.RevokeRole(ctx, user.UserID, models.Grant{Role: "owner"})).To(MatchError(repository.ErrUnknownRole))
+			Expect(repo.GrantRole(ctx, 42, admin)).To(MatchError(repository.ErrNotFound))
+			_, err := repo.UserRoles(ctx, 42)
+			Expect(err).To(MatchError(repository.ErrNotFound))
+		})
+
+		It("should hide the grants of a deleted user until it is restored", func() {
+			user := newUser("a", "A", "HR")
+			Expect(repo.Create(ctx, user)).To(Succeed())
+			Expect(repo.GrantRole(ctx, user.UserID, salesEditor)).To(Succeed())
+			Expect(repo.Delete(ctx, user.UserID, nil)).To(Succeed())
+
+			_, err := repo.UserRoles(ctx, user.UserID)
+			Expect(err).To(MatchError(repository.ErrNotFound))
+
+			_, err = repo.Restore(ctx, user.UserID, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repo.UserRoles(ctx, user.UserID)).To(ConsistOf(salesEditor))
+		})
+
+		It("should discard grants made in a failed transaction", func() {
+			user := newUser("a", "A", "HR")
+			Expect(repo.Create(ctx, user)).To(Succeed())
+			failure := errors.New("stop")
+			err := repo.WithTx(ctx, func(tx repository.UserRepository) error {
+				Expect(tx.GrantRole(ctx, user.UserID, admin)).To(Succeed())
+				return failure
+			})
+			Expect(err).To(MatchError(failure))
+			Expect(repo.UserRoles(ctx, user.UserID)).To(BeEmpty())
+		})
+	})
+
+	Describe("APIKeys", func() {
+		created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		newKey := func(prefix string) *models.APIKey {
+			return &models.APIKey{
+				Name:       "HR sync",
+				Prefix:     prefix,
+				Scopes:     []string{"users:read", "users:update"},
+				CreatedBy:  "admin",
+				CreatedAt:  created,
+				SecretHash: strings.Repeat("a", 64),
+			}
+		}
+
+		It("should store keys and find them by prefix", func() {
+			expires := created.Add(24 * time.Hour)
+			first, second := newKey("aaaaaaaaaaaa"), newKey("bbbbbbbbbbbb")
+			second.ExpiresAt = &expires
+			Expect(repo.CreateAPIKey(ctx, first)).To(Succeed())
+			Expect(repo.CreateAPIKey(ctx, second)).To(Succeed())
+			Expect(second.ID).To(BeNumerically(">", first.ID))
+
+			found, err := repo.APIKeyByPrefix(ctx, "bbbbbbbbbbbb")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found.ID).To(Equal(second.ID))
+			Expect(found.Scopes).To(Equal(second.Scopes))
+			Expect(found.SecretHash).To(Equal(second.SecretHash))
+			Expect(found.CreatedAt.Equal(created)).To(BeTrue())
+			Expect(found.ExpiresAt.Equal(expires)).To(BeTrue())
+			Expect(found.LastUsedAt).To(BeNil())
+
+			_, err = repo.APIKeyByPrefix(ctx, "cccccccccccc")
+			Expect(err).To(MatchError(repository.ErrAPIKeyNotFound))
+
+			keys, err := repo.APIKeys(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(HaveLen(2))
+			Expect(keys[0].Prefix).To(Equal("aaaaaaaaaaaa"))
+		})
+
+		It("should record use and keep the first revocation", func() {
+			key := newKey("aaaaaaaaaaaa")
+			Expect(repo.CreateAPIKey(ctx, key)).To(Succeed())
+
+			used := created.Add(time.Hour)
+			Expect(repo.TouchAPIKey(ctx, key.ID, used)).To(Succeed())
+			revoked, err := repo.RevokeAPIKey(ctx, key.ID, used.Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revoked.LastUsedAt.Equal(used)).To(BeTrue())
+			Expect(revoked.RevokedAt.Equal(used.Add(time.Hour))).To(BeTrue())
+
+			revoked, err = repo.RevokeAPIKey(ctx, key.ID, used.Add(2*time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revoked.RevokedAt.Equal(used.Add(time.Hour))).To(BeTrue())
+
+			_, err = repo.RevokeAPIKey(ctx, key.ID+1, used)
+			Expect(err).To(MatchError(repository.ErrAPIKeyNotFound))
+			Expect(repo.TouchAPIKey(ctx, key.ID+1, used)).To(MatchError(repository.ErrAPIKeyNotFound))
+		})
+	})
+
+	Describe("AuditLog", func() {
+		at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+		record := func(userID int64, actor, operation string, offset time.Duration) *models.AuditEntry {
+			entry := &models.AuditEntry{
+				UserID:    userID,
+				Actor:     actor,
+				RequestID: "req",
+				Operation: operation,
+				Changes:   map[string]models.FieldChange{"department": {Old: "HR", New: "Sales"}},
+				At:        at.Add(offset),
+			}
+			Expect(repo.RecordAudit(ctx, entry)).To(Succeed())
+			return entry
+		}
+
+		BeforeEach(func() {
+			record(1, "alice", models.AuditCreate, 0)
+			record(1, "bob", models.AuditUpdate, time.Hour)
+			record(2, "alice", models.AuditCreate, 2*time.Hour)
+			record(1, "alice", models.AuditDelete, 3*time.Hour)
+		})
+
+		ids := func(entries []models.AuditEntry) []int64 {
+			var ids []int64
+			for _, entry := range entries {
+				ids = append(ids, entry.ID)
+			}
+			return ids
+		}
+
+		It("should list entries newest first with their changes", func() {
+			entries, total, err := repo.AuditLog(ctx, repository.AuditFilter{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(int64(4)))
+			Expect(ids(entries)).To(Equal([]int64{4, 3, 2, 1}))
+			Expect(entries[0].Changes).To(Equal(map[string]models.FieldChange{"department": {Old: "HR", New: "Sales"}}))
+			Expect(entries[0].RequestID).To(Equal("req"))
+			Expect(entries[0].At.Equal(at.Add(3 * time.Hour))).To(BeTrue())
+		})
+
+		It("should filter by user, actor, operation and time", func() {
+			entries, total, err := repo.AuditLog(ctx, repository.AuditFilter{UserID: 1, Actors: []string{"alice"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(int64(2)))
+			Expect(ids(entries)).To(Equal([]int64{4, 1}))
+
+			entries, _, err = repo.AuditLog(ctx, repository.AuditFilter{Operations: []string{models.AuditCreate, models.AuditDelete}, Since: at.Add(time.Hour), Until: at.Add(3 * time.Hour)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids(entries)).To(Equal([]int64{3}))
+		})
+
+		It("should page and count the matching entries", func() {
+			entries, total, err := repo.AuditLog(ctx, repository.AuditFilter{Limit: 2, Offset: 1})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(int64(4)))
+			Expect(ids(entries)).To(Equal([]int64{3, 2}))
+		})
+
+		It("should chain every entry onto the one before it", func() {
+			entries, _, err := repo.AuditLog(ctx, repository.AuditFilter{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries[3].PrevHash).To(BeEmpty())
+			for i := 0; i < 3; i++ {
+				Expect(entries[i].PrevHash).To(Equal(entries[i+1].Hash))
+				Expect(entries[i].Hash).To(Equal(entries[i].ComputeHash()))
+			}
+
+			report, err := repository.VerifyAuditChain(ctx, repo)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*report).To(Equal(models.AuditVerification{Valid: true, Entries: 4, Head: entries[0].Hash}))
+		})
+
+		It("should discard entries recorded in a failed transaction", func() {
+			failure := errors.New("stop")
+			err := repo.WithTx(ctx, func(tx repository.UserRepository) error {
+				Expect(tx.RecordAudit(ctx, &models.AuditEntry{UserID: 3, Actor: "carol", Operation: models.AuditCreate, At: at})).To(Succeed())
+				return failure
+			})
+			Expect(err).To(MatchError(failure))
+			_, total, err := repo.AuditLog(ctx, repository.AuditFilter{Actors: []string{"carol"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(BeZero())
+		})
+	})
+
+	Describe("Soft delete", func() {
+		var user *models.User
+
+		BeforeEach(func() {
+			user = newUser("a", "A", "HR")
+			Expect(repo.Create(ctx, user)).To(Succeed())
+			Expect(repo.Create(ctx, newUser("b", "A", "HR"))).To(Succeed())
+			Expect(repo.Delete(ctx, user.UserID, nil)).To(Succeed())
+		})
+
+		It("should hide deleted users unless the context includes them", func() {
+			users, total, err := repo.List(ctx, repository.ListOptions{Sort: []repository.SortField{{Column: "user_id"}}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(int64(1)))
+			Expect(users).To(HaveLen(1))
+			_, err = repo.Get(ctx, user.UserID)
+			Expect(err).To(MatchError(repository.ErrNotFound))
+			_, err = repo.GetByUsername(ctx, "a")
+			Expect(err).To(MatchError(repository.ErrNotFound))
+
+			withDeleted := repository.WithDeleted(ctx)
+			users, total, err = repo.List(withDeleted, repository.ListOptions{Sort: []repository.SortField{{Column: "user_id"}}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(int64(2)))
+			Expect(users[0].DeletedAt).NotTo(BeNil())
+			Expect(users[1].DeletedAt).To(BeNil())
+			deleted, err := repo.Get(withDeleted, user.UserID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deleted.Version).To(Equal(int64(2)))
+			Expect(*deleted.DeletedAt).To(BeTemporally("~", time.Now(), time.Minute))
+		})
+
+		It("should not update or delete a deleted user again", func() {
+			user.FirstName = "Changed"
+			Expect(repo.Update(ctx, user, []string{"first_name"}, nil)).To(MatchError(repository.ErrNotFound))
+			Expect(repo.Delete(ctx, user.UserID, nil)).To(MatchError(repository.ErrNotFound))
+			Expect(repo.Delete(repository.WithDeleted(ctx), user.UserID, nil)).To(MatchError(repository.ErrNotFound))
+		})
+
+		It("should free the user name of a deleted user", func() {
+			Expect(repo.Create(ctx, newUser("a", "I", "Sales"))).To(Succeed())
+			_, err := repo.Restore(ctx, user.UserID, nil)
+			Expect(err).To(MatchError(repository.ErrUserNameTaken))
+		})
+
+		It("should restore a deleted user once", func() {
+			_, err := repo.Restore(ctx, user.UserID, &repository.Precondition{Versions: []int64{1}})
+			Expect(err).To(MatchError(repository.ErrVersionMismatch))
+
+			restored, err := repo.Restore(ctx, user.UserID, &repository.Precondition{Versions: []int64{2}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restored.UserName).To(Equal("a"))
+			Expect(restored.Version).To(Equal(int64(3)))
+			Expect(restored.DeletedAt).To(BeNil())
+			Expect(repo.Get(ctx, user.UserID)).To(Equal(restored))
+
+			_, err = repo.Restore(ctx, user.UserID, nil)
+			Expect(err).To(MatchError(repository.ErrNotDeleted))
+			_, err = repo.Restore(ctx, 42, nil)
+			Expect(err).To(MatchError(repository.ErrNotFound))
+		})
+
+		It("should purge only the users deleted before the cutoff", func() {
+			purged, err := repo.Purge(ctx, time.Now().Add(-time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(purged).To(BeZero())
+
+			purged, err = repo.Purge(ctx, time.Now().Add(time.Second))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(purged).To(Equal(int64(1)))
+			_, err = repo.Get(repository.WithDeleted(ctx), user.UserID)
+			Expect(err).To(MatchError(repository.ErrNotFound))
+			_, err = repo.Restore(ctx, user.UserID, nil)
+			Expect(err).To(MatchError(repository.ErrNotFound))
+
+			_, total, err := repo.List(repository.WithDeleted(ctx), repository.ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(int64(1)))
+		})
+	})
+
+	Describe("Change tracking", func() {
+		It("should stamp who created and last changed a user, and when", func() {
+			before := time.Now().Add(-time.Second)
+			user := newUser("a", "A", "HR")
+			Expect(repo.Create(repository.WithActor(ctx, "alice"), user)).To(Succeed())
+			Expect(user.CreatedBy).To(Equal("alice"))
+			Expect(user.UpdatedBy).To(Equal("alice"))
+			Expect(user.CreatedAt).To(BeTemporally(">", before))
+			Expect(user.UpdatedAt).To(Equal(user.CreatedAt))
+			created := user.CreatedAt
+
+			user.Department = "Sales"
+			Expect(repo.Update(repository.WithActor(ctx, "bob"), user, []string{"department"}, nil)).To(Succeed())
+			stored, err := repo.Get(ctx, user.UserID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored.CreatedAt).To(Equal(created))
+			Expect(stored.CreatedBy).To(Equal("alice"))
+			Expect(stored.UpdatedBy).To(Equal("bob"))
+			Expect(stored.UpdatedAt).To(BeTemporally(">=", created))
+
+			Expect(repo.Delete(repository.WithActor(ctx, "carol"), user.UserID, nil)).To(Succeed())
+			stored, err = repo.Get(repository.WithDeleted(ctx), user.UserID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored.UpdatedBy).To(Equal("carol"))
+
+			restored, err := repo.Restore(repository.WithActor(ctx, "dave"), user.UserID, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restored.UpdatedBy).To(Equal("dave"))
+			Expect(restored.CreatedBy).To(Equal("alice"))
+		})
+
+		It("should filter by and sort on the tracked columns", func() {
+			for _, name := range []string{"a", "b", "c"} {
+				Expect(repo.Create(repository.WithActor(ctx, "alice"), newUser(name, "A", "HR"))).To(Succeed())
+			}
+			first, err := repo.GetByUsername(ctx, "a")
+			Expect(err).NotTo(HaveOccurred())
+			time.Sleep(time.Millisecond)
+			first.FirstName = "Changed"
+			Expect(repo.Update(repository.WithActor(ctx, "bob"), first, []string{"first_name"}, nil)).To(Succeed())
+
+			users, total, err := repo.List(ctx, repository.ListOptions{
+				Sort: []repository.SortField{{Column: "updated_at", Desc: true}, {Column: "user_id"}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(int64(3)))
+			Expect(users[0].UserName).To(Equal("a"))
+
+			users, total, err = repo.List(ctx, repository.ListOptions{
+				Ranges: []repository.TimeRange{{Column: "updated_at", Since: first.UpdatedAt}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(int64(1)))
+			Expect(users[0].UpdatedBy).To(Equal("bob"))
+
+			_, total, err = repo.List(ctx, repository.ListOptions{
+				Ranges:  []repository.TimeRange{{Column: "created_at", Until: first.UpdatedAt}},
+				Filters: []repository.Filter{{Column: "updated_by", Values: []string{"alice"}}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(int64(2)))
+
+			_, _, err = repo.List(ctx, repository.ListOptions{Ranges: []repository.TimeRange{{Column: "nope"}}})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Scheduled changes", func() {
+		var (
+			hr, sales *models.User
+			now       time.Time
+		)
+
+		schedule := func(user *models.User, status, department string, in time.Duration) *models.ScheduledChange {
+			change := &models.ScheduledChange{
+				UserID:      user.UserID,
+				UserStatus:  status,
+				Department:  department,
+				EffectiveAt: now.Add(in),
+				State:       models.ChangePending,
+				CreatedBy:   "alice",
+				CreatedAt:   now,
+			}
+			Expect(repo.ScheduleChange(ctx, change)).To(Succeed())
+			return change
+		}
+
+		BeforeEach(func() {
+			hr, sales = newUser("hr", "A", "HR"), newUser("sales", "A", "Sales")
+			Expect(repo.Create(ctx, hr)).To(Succeed())
+			Expect(repo.Create(ctx, sales)).To(Succeed())
+			now = time.Now().UTC().Truncate(time.Microsecond)
+		})
+
+		It("should list changes in the order they take effect", func() {
+			later := schedule(hr, "T", "", 2*time.Hour)
+			sooner := schedule(sales, "", "HR", time.Hour)
+			schedule(hr, "I", "Sales", 3*time.Hour)
+
+			changes, err := repo.ScheduledChanges(ctx, repository.ScheduleFilter{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(HaveLen(3))
+			Expect(changes[0]).To(Equal(*sooner))
+			Expect(changes[1]).To(Equal(*later))
+
+			changes, err = repo.ScheduledChanges(ctx, repository.ScheduleFilter{UserID: hr.UserID, DueBy: now.Add(2 * time.Hour)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(ConsistOf(*later))
+
+			Expect(repo.ScheduledChange(ctx, sooner.ID)).To(Equal(sooner))
+			_, err = repo.ScheduledChange(ctx, 42)
+			Expect(err).To(MatchError(repository.ErrChangeNotFound))
+		})
+
+		It("should resolve a pending change once", func() {
+			change := schedule(hr, "T", "", time.Hour)
+			change.State = models.ChangeCancelled
+			change.ResolvedBy = "bob"
+			change.ResolvedAt = &now
+			Expect(repo.ResolveScheduledChange(ctx, change)).To(Succeed())
+			Expect(repo.ScheduledChange(ctx, change.ID)).To(Equal(change))
+
+			change.State = models.ChangeApplied
+			Expect(repo.ResolveScheduledChange(ctx, change)).To(MatchError(repository.ErrChangeNotPending))
+			change.ID = 42
+			Expect(repo.ResolveScheduledChange(ctx, change)).To(MatchError(repository.ErrChangeNotFound))
+
+			changes, err := repo.ScheduledChanges(ctx, repository.ScheduleFilter{States: []string{models.ChangePending}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(BeEmpty())
+		})
+
+		It("should only see the changes of users in the departments", func() {
+			schedule(hr, "I", "", time.Hour)
+			change := schedule(sales, "I", "", time.Hour)
+			ctx = repository.WithDepartments(ctx, []string{"HR"})
+
+			changes, err := repo.ScheduledChanges(ctx, repository.ScheduleFilter{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(HaveLen(1))
+			Expect(changes[0].UserID).To(Equal(hr.UserID))
+			_, err = repo.ScheduledChange(ctx, change.ID)
+			Expect(err).To(MatchError(repository.ErrChangeNotFound))
+			change.State = models.ChangeCancelled
+			Expect(repo.ResolveScheduledChange(ctx, change)).To(MatchError(repository.ErrChangeNotFound))
+		})
+
+		It("should drop the changes of purged users", func() {
+			schedule(sales, "I", "", time.Hour)
+			Expect(repo.Delete(ctx, sales.UserID, nil)).To(Succeed())
+			_, err := repo.Purge(ctx, time.Now().Add(time.Second))
+			Expect(err).NotTo(HaveOccurred())
+
+			changes, err := repo.ScheduledChanges(ctx, repository.ScheduleFilter{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(BeEmpty())
+		})
+	})
+
+	Describe("WithDepartments", func() {
+		var hr, sales *models.User
+
+		BeforeEach(func() {
+			hr, sales = newUser("hr", "A", "HR"), newUser("sales", "A", "Sales")
+			Expect(repo.Create(ctx, hr)).To(Succeed())
+			Expect(repo.Create(ctx, sales)).To(Succeed())
+			ctx = repository.WithDepartments(ctx, []string{"HR", "Engineering"})
+		})
+
+		It("should only list and find users of the departments", func() {
+			users, total, err := repo.List(ctx, repository.ListOptions{Sort: []repository.SortField{{Column: "user_id"}}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(int64(1)))
+			Expect(users).To(HaveLen(1))
+			Expect(users[0].UserName).To(Equal("hr"))
+
+			_, err = repo.Get(ctx, sales.UserID)
+			Expect(err).To(MatchError(repository.ErrNotFound))
+			_, err = repo.GetByUsername(ctx, "sales")
+			Expect(err).To(MatchError(repository.ErrNotFound))
+			_, err = repo.UserRoles(ctx, sales.UserID)
+			Expect(err).To(MatchError(repository.ErrNotFound))
+			Expect(repo.Get(ctx, hr.UserID)).To(Equal(hr))
+		})
+
+		It("should not write users outside the departments", func() {
+			sales.FirstName = "Changed"
+			Expect(repo.Update(ctx, sales, []string{"first_name"}, nil)).To(MatchError(repository.ErrNotFound))
+			Expect(repo.Update(ctx, sales, []string{"first_name"}, &repository.Precondition{Versions: []int64{1}})).To(MatchError(repository.ErrNotFound))
+			Expect(repo.Delete(ctx, sales.UserID, nil)).To(MatchError(repository.ErrNotFound))
+			Expect(repo.Create(ctx, newUser("b", "A", "Sales"))).To(MatchError(repository.ErrOutOfScope))
+
+			Expect(repo.Get(context.Background(), sales.UserID)).To(HaveField("FirstName", "First"))
+		})
+
+		It("should not move users out of the departments", func() {
+			hr.Department = "Sales"
+			Expect(repo.Update(ctx, hr, []string{"department"}, nil)).To(MatchError(repository.ErrOutOfScope))
+			hr.Department = "Engineering"
+			Expect(repo.Update(ctx, hr, []string{"department"}, nil)).To(Succeed())
+			Expect(repo.Create(ctx, newUser("b", "A", "HR"))).To(Succeed())
+		})
+
+		It("should find nothing when confined to no department", func() {
+			ctx = repository.WithDepartments(context.Background(), []string{})
+			users, total, err := repo.List(ctx, repository.ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(BeZero())
+			Expect(users).To(BeEmpty())
+		})
+	})
+
+	Describe("Managers", func() {
+		var ceo, vp, dev1, dev2, intern *models.User
+
+		reportingTo := func(name, department string, manager *models.User) *models.User {
+			user := newUser(name, "A", department)
+			if manager != nil {
+				user.ManagerID = &manager.UserID
+			}
+			Expect(repo.Create(ctx, user)).To(Succeed())
+			return user
+		}
+
+		names := func(users []models.User) []string {
+			var names []string
+			for _, u := range users {
+				names = append(names, u.UserName)
+			}
+			return names
+		}
+
+		BeforeEach(func() {
+			ceo = reportingTo("ceo", "HR", nil)
+			vp = reportingTo("vp", "Engineering", ceo)
+			dev1 = reportingTo("dev1", "Sales", vp)
+			dev2 = reportingTo("dev2", "Engineering", vp)
+			intern = reportingTo("intern", "Engineering", dev1)
+		})
+
+		It("should store the manager of a user", func() {
+			Expect(repo.Get(ctx, vp.UserID)).To(HaveField("ManagerID", HaveValue(Equal(ceo.UserID))))
+			Expect(repo.Get(ctx, ceo.UserID)).To(HaveField("ManagerID", BeNil()))
+
+			dev2.ManagerID = nil
+			Expect(repo.Update(ctx, dev2, []string{"manager_id"}, nil)).To(Succeed())
+			Expect(repo.Get(ctx, dev2.UserID)).To(HaveField("ManagerID", BeNil()))
+		})
+
+		It("should reject unknown and deleted managers", func() {
+			unknown := int64(42)
+			user := newUser("a", "A", "HR")
+			user.ManagerID = &unknown
+			Expect(repo.Create(ctx, user)).To(MatchError(repository.ErrUnknownManager))
+
+			Expect(repo.Delete(ctx, dev2.UserID, nil)).To(Succeed())
+			intern.ManagerID = &dev2.UserID
+			Expect(repo.Update(ctx, intern, []string{"manager_id"}, nil)).To(MatchError(repository.ErrUnknownManager))
+			Expect(repo.Get(ctx, intern.UserID)).To(HaveField("ManagerID", HaveValue(Equal(dev1.UserID))))
+		})
+
+		It("should not let a user report to themselves or to their reports", func() {
+			ceo.ManagerID = &ceo.UserID
+			Expect(repo.Update(ctx, ceo, []string{"manager_id"}, nil)).To(MatchError(repository.ErrManagerCycle))
+			ceo.ManagerID = &intern.UserID
+			Expect(repo.Update(ctx, ceo, []string{"manager_id"}, nil)).To(MatchError(repository.ErrManagerCycle))
+
+			// The cycle is found through users the context cannot see.
+			scoped := repository.WithDepartments(ctx, []string{"HR"})
+			Expect(repo.Update(scoped, ceo, []string{"manager_id"}, nil)).To(MatchError(repository.ErrManagerCycle))
+		})
+
+		It("should keep a manager who was deleted since", func() {
+			Expect(repo.Delete(ctx, vp.UserID, nil)).To(Succeed())
+			dev2.FirstName = "Changed"
+			Expect(repo.Update(ctx, dev2, []string{"first_name", "manager_id"}, nil)).To(Succeed())
+			Expect(repo.Get(ctx, dev2.UserID)).To(HaveField("ManagerID", HaveValue(Equal(vp.UserID))))
+		})
+
+		It("should list the management chain upwards and the subordinates by id", func() {
+			chain, err := repo.ManagementChain(ctx, intern.UserID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(chain)).To(Equal([]string{"dev1", "vp", "ceo"}))
+			Expect(repo.ManagementChain(ctx, ceo.UserID)).To(BeEmpty())
+
+			subordinates, err := repo.Subordinates(ctx, vp.UserID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(subordinates)).To(Equal([]string{"dev1", "dev2", "intern"}))
+			Expect(repo.Subordinates(ctx, intern.UserID)).To(BeEmpty())
+		})
+
+		It("should cut the lines through deleted users", func() {
+			Expect(repo.Delete(ctx, vp.UserID, nil)).To(Succeed())
+
+			chain, err := repo.ManagementChain(ctx, intern.UserID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(chain)).To(Equal([]string{"dev1"}))
+			Expect(repo.Subordinates(ctx, ceo.UserID)).To(BeEmpty())
+		})
+
+		It("should follow the lines through users outside the departments", func() {
+			scoped := repository.WithDepartments(ctx, []string{"HR", "Engineering"})
+
+			chain, err := repo.ManagementChain(scoped, intern.UserID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(chain)).To(Equal([]string{"vp", "ceo"}))
+
+			subordinates, err := repo.Subordinates(scoped, ceo.UserID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(subordinates)).To(Equal([]string{"vp", "dev2", "intern"}))
+		})
+
+		It("should filter and sort users by manager", func() {
+			users, total, err := repo.List(ctx, repository.ListOptions{
+				Filters: []repository.Filter{{Column: "manager_id", Values: []string{fmt.Sprint(vp.UserID)}}},
+				Sort:    []repository.SortField{{Column: "user_id"}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(int64(2)))
+			Expect(names(users)).To(Equal([]string{"dev1", "dev2"}))
+		})
+
+		It("should clear the manager of the reports of a purged user", func() {
+			Expect(repo.Delete(ctx, dev1.UserID, nil)).To(Succeed())
+			Expect(repo.Get(ctx, intern.UserID)).To(HaveField("ManagerID", HaveValue(Equal(dev1.UserID))))
+
+			_, err := repo.Purge(ctx, time.Now().Add(time.Second))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repo.Get(ctx, intern.UserID)).To(HaveField("ManagerID", BeNil()))
+		})
+	})
+
+	Describe("Departments", func() {
+		var engineering, hr *models.Department
+
+		BeforeEach(func() {
+			var err error
+			engineering, err = repo.DepartmentByName(ctx, "Engineering")
+			Expect(err).NotTo(HaveOccurred())
+			hr, err = repo.DepartmentByName(ctx, "HR")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should create, list and find departments", func() {
+			platform := &models.Department{Code: "PLT", Name: "Platform", ParentID: &engineering.ID, CostCenter: "CC-100"}
+			Expect(repo.CreateDepartment(ctx, platform)).To(Succeed())
+			Expect(platform.ID).NotTo(BeZero())
+
+			Expect(repo.Department(ctx, platform.ID)).To(Equal(platform))
+			Expect(repo.DepartmentByName(ctx, "Platform")).To(Equal(platform))
+			departments, err := repo.Departments(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(departments).To(HaveLen(6))
+			Expect(departments[3]).To(Equal(*platform))
+
+			_, err = repo.Department(ctx, 42)
+			Expect(err).To(MatchError(repository.ErrDepartmentNotFound))
+			_, err = repo.DepartmentByName(ctx, "Legal")
+			Expect(err).To(MatchError(repository.ErrDepartmentNotFound))
+		})
+
+		It("should reject duplicate codes and names, unknown parents and broken constraints", func() {
+			Expect(repo.CreateDepartment(ctx, &models.Department{Code: "HR", Name: "People"})).To(MatchError(repository.ErrDepartmentCodeTaken))
+			Expect(repo.CreateDepartment(ctx, &models.Department{Code: "PPL", Name: "HR"})).To(MatchError(repository.ErrDepartmentNameTaken))
+			Expect(repo.CreateDepartment(ctx, &models.Department{Code: "PPL", Name: "People", ParentID: new(int64)})).To(MatchError(repository.ErrUnknownDepartment))
+			Expect(repo.CreateDepartment(ctx, &models.Department{Code: "", Name: "People"})).To(MatchError(repository.ErrInvalidDepartment))
+			Expect(repo.CreateDepartment(ctx, &models.Department{Code: strings.Repeat("x", 51), Name: "People"})).To(MatchError(repository.ErrInvalidDepartment))
+
+			hr.Name = "Engineering"
+			Expect(repo.UpdateDepartment(ctx, hr)).To(MatchError(repository.ErrDepartmentNameTaken))
+			hr.ID = 42
+			Expect(repo.UpdateDepartment(ctx, hr)).To(MatchError(repository.ErrDepartmentNotFound))
+		})
+
+		It("should only write users in departments that exist", func() {
+			Expect(repo.Create(ctx, newUser("a", "A", "Legal"))).To(MatchError(repository.ErrUnknownDepartment))
+			user := newUser("a", "A", "HR")
+			Expect(repo.Create(ctx, user)).To(Succeed())
+			user.Department = "Legal"
+			Expect(repo.Update(ctx, user, []string{"department"}, nil)).To(MatchError(repository.ErrUnknownDepartment))
+			Expect(repo.Get(ctx, user.UserID)).To(HaveField("Department", "HR"))
+		})
+
+		It("should not make a department its own ancestor", func() {
+			platform := &models.Department{Code: "PLT", Name: "Platform", ParentID: &engineering.ID}
+			Expect(repo.CreateDepartment(ctx, platform)).To(Succeed())
+
+			engineering.ParentID = &engineering.ID
+			Expect(repo.UpdateDepartment(ctx, engineering)).To(MatchError(repository.ErrDepartmentCycle))
+			engineering.ParentID = &platform.ID
+			Expect(repo.UpdateDepartment(ctx, engineering)).To(MatchError(repository.ErrDepartmentCycle))
+			engineering.ParentID = &hr.ID
+			Expect(repo.UpdateDepartment(ctx, engineering)).To(Succeed())
+		})
+
+		It("should move users, grants and pending changes to a renamed department", func() {
+			user := newUser("a", "A", "HR")
+			Expect(repo.Create(ctx, user)).To(Succeed())
+			deleted := newUser("b", "A", "HR")
+			Expect(repo.Create(ctx, deleted)).To(Succeed())
+			Expect(repo.Delete(ctx, deleted.UserID, nil)).To(Succeed())
+			Expect(repo.GrantRole(ctx, user.UserID, models.Grant{Role: "editor", Department: "HR"})).To(Succeed())
+			change := &models.ScheduledChange{UserID: user.UserID, Department: "HR", EffectiveAt: time.Now().Add(time.Hour), State: models.ChangePending}
+			Expect(repo.ScheduleChange(ctx, change)).To(Succeed())
+
+			hr.Name = "People"
+			Expect(repo.UpdateDepartment(repository.WithActor(ctx, "alice"), hr)).To(Succeed())
+
+			moved, err := repo.Get(ctx, user.UserID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(moved.Department).To(Equal("People"))
+			Expect(moved.Version).To(Equal(user.Version + 1))
+			Expect(moved.UpdatedBy).To(Equal("alice"))
+			Expect(repo.Get(repository.WithDeleted(ctx), deleted.UserID)).To(HaveField("Department", "People"))
+			Expect(repo.UserRoles(ctx, user.UserID)).To(ConsistOf(models.Grant{Role: "editor", Department: "People"}))
+			Expect(repo.ScheduledChange(ctx, change.ID)).To(HaveField("Department", "People"))
+		})
+
+		It("should not delete a department in use", func() {
+			user := newUser("a", "A", "HR")
+			Expect(repo.Create(ctx, user)).To(Succeed())
+			Expect(repo.Delete(ctx, user.UserID, nil)).To(Succeed())
+			Expect(repo.DeleteDepartment(ctx, hr.ID)).To(MatchError(repository.ErrDepartmentInUse))
+
+			platform := &models.Department{Code: "PLT", Name: "Platform", ParentID: &engineering.ID}
+			Expect(repo.CreateDepartment(ctx, platform)).To(Succeed())
+			Expect(repo.DeleteDepartment(ctx, engineering.ID)).To(MatchError(repository.ErrDepartmentInUse))
+
+			Expect(repo.DeleteDepartment(ctx, platform.ID)).To(Succeed())
+			Expect(repo.DeleteDepartment(ctx, engineering.ID)).To(Succeed())
+			_, err := repo.Department(ctx, engineering.ID)
+			Expect(err).To(MatchError(repository.ErrDepartmentNotFound))
+			Expect(repo.DeleteDepartment(ctx, engineering.ID)).To(MatchError(repository.ErrDepartmentNotFound))
+		})
+
+		It("should revoke the grants made in a deleted department", func() {
+			user := newUser("a", "A", "HR")
+			Expect(repo.Create(ctx, user)).To(Succeed())
+			Expect(repo.GrantRole(ctx, user.UserID, models.Grant{Role: "editor", Department: "Engineering"})).To(Succeed())
+			Expect(repo.DeleteDepartment(ctx, engineering.ID)).To(Succeed())
+
+			Expect(repo.UserRoles(ctx, user.UserID)).To(BeEmpty())
+		})
+	})
+}
+
+var _ = Describe("MemoryUserRepository", func() {
+	userRepositoryContract(func() repository.UserRepository {
+		return repository.NewMemoryUserRepository()
+	})
+
+	It("should consume an id on a failed insert, like a Postgres sequence", func() {
+		ctx := context.Background()
+		repo := repository.NewMemoryUserRepository()
+		createDepartments(ctx, repo, "HR")
+		user := &models.User{UserName: "a", FirstName: "A", LastName: "A", Email: "a@example.com", UserStatus: "A", Department: "HR"}
+		Expect(repo.Create(ctx, user)).To(Succeed())
+		duplicate := *user
+		Expect(repo.Create(ctx, &duplicate)).To(MatchError(repository.ErrUserNameTaken))
+
+		_ = repo.WithTx(ctx, func(tx repository.UserRepository) error {
+			rolledBack := *user
+			rolledBack.UserName = "b"
+			Expect(tx.Create(ctx, &rolledBack)).To(Succeed())
+			return errors.New("roll back")
+		})
+
+		next := *user
+		next.UserName = "c"
+		Expect(repo.Create(ctx, &next)).To(Succeed())
+		Expect(next.UserID).To(Equal(int64(4)))
+	})
+})
+
+var _ = Describe("SQLUserRepository with SQLite", func() {
+	var database *sql.DB
+
+	AfterEach(func() {
+		database.Close()
+	})
+
+	userRepositoryContract(func() repository.UserRepository {
+		connector := &connectors.SQLiteConnector{}
+		var err error
+		database, err = connector.Open("sqlite://:memory:")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(connector.RunMigrationsUp(database)).To(Succeed())
+		return repository.NewSQLiteUserRepository(database)
+	})
+
+	Describe("VerifyAuditChain", func() {
+		var (
+			repo *repository.SQLUserRepository
+			ctx  = context.Background()
+		)
+
+		BeforeEach(func() {
+			connector := &connectors.SQLiteConnector{}
+			var err error
+			database, err = connector.Open("sqlite://:memory:")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(connector.RunMigrationsUp(database)).To(Succeed())
+			repo = repository.NewSQLiteUserRepository(database)
+			for _, actor := range []string{"alice", "bob", "carol"} {
+				entry := &models.AuditEntry{UserID: 1, Actor: actor, Operation: models.AuditUpdate, At: time.Now()}
+				Expect(repo.RecordAudit(ctx, entry)).To(Succeed())
+			}
+		})
+
+		verify := func() *models.AuditVerification {
+			report, err := repository.VerifyAuditChain(ctx, repo)
+			Expect(err).NotTo(HaveOccurred())
+			return report
+		}
+
+		It("should report an edited entry", func() {
+			_, err := database.Exec("UPDATE user_audit SET actor = 'mallory' WHERE audit_id = 2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(verify().Broken).To(Equal(&models.BrokenLink{AuditID: 2, Reason: "hash does not match the contents of the entry"}))
+		})
+
+		It("should report a deleted entry at the entry after it", func() {
+			_, err := database.Exec("DELETE FROM user_audit WHERE audit_id = 2")
+			Expect(err).NotTo(HaveOccurred())
+			report := verify()
+			Expect(report.Valid).To(BeFalse())
+			Expect(report.Broken).To(Equal(&models.BrokenLink{AuditID: 3, Reason: "prev_hash does not match the hash of the previous entry"}))
+		})
+
+		It("should report entries deleted from the end", func() {
+			_, err := database.Exec("DELETE FROM user_audit WHERE audit_id = 3")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(verify().Broken).To(Equal(&models.BrokenLink{AuditID: 2, Reason: "chain does not end at the anchored head"}))
+
+			entry := &models.AuditEntry{UserID: 1, Actor: "dave", Operation: models.AuditUpdate, At: time.Now()}
+			Expect(repo.RecordAudit(ctx, entry)).To(Succeed())
+			Expect(verify().Broken).To(Equal(&models.BrokenLink{AuditID: entry.ID, Reason: "prev_hash does not match the hash of the previous entry"}))
+		})
+
+		It("should report entries whose hashes were wiped", func() {
+			_, err := database.Exec("UPDATE user_audit SET prev_hash = '', hash = ''")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(verify().Broken).To(Equal(&models.BrokenLink{AuditID: 1, Reason: "entry has no hash"}))
+		})
+
+		It("should accept entries recorded before the chain only at its start", func() {
+			_, err := database.Exec("INSERT INTO user_audit (audit_id, user_id, actor, operation, changes, occurred_at) VALUES (0, 1, 'legacy', 'create', '{}', '2020-01-01 00:00:00+00:00')")
+			Expect(err).NotTo(HaveOccurred())
+			report := verify()
+			Expect(report.Valid).To(BeTrue())
+			Expect(report.Unsealed).To(Equal(int64(1)))
+
+			_, err = database.Exec("UPDATE user_audit SET hash = '' WHERE audit_id = 3")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(verify().Broken).To(Equal(&models.BrokenLink{AuditID: 3, Reason: "entry has no hash"}))
+		})
+	})
+})
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+const rolesUsage = "usage: roles list <user_name> | roles grant|revoke [-department d] <user_name> <role>"
+
+// rolesCommand shows and changes the roles of a user. It is how the first
+// admin is created, since granting roles through the API needs an admin.
+func rolesCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(rolesUsage)
+	}
+	sub := args[0]
+	flags := flag.NewFlagSet("roles "+sub, flag.ExitOnError)
+	department := flags.String("department", "", "confine the role to the users of this department")
+	flags.Parse(args[1:])
+
+	wantArgs := 2
+	if sub == "list" {
+		wantArgs = 1
+	} else if sub != "grant" && sub != "revoke" {
+		return fmt.Errorf("unknown roles command %q", sub)
+	}
+	if flags.NArg() != wantArgs {
+		return errors.New(rolesUsage)
+	}
+	username := flags.Arg(0)
+
+	repo, closeRepo, err := openUserRepository()
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	ctx := context.Background()
+	user, err := repo.GetByUsername(ctx, username)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("no user named %q", username)
+	}
+	if err != nil {
+		return err
+	}
+
+	if sub != "list" {
+		change := repo.GrantRole
+		if sub == "revoke" {
+			change = repo.RevokeRole
+		}
+		grant := models.Grant{Role: flags.Arg(1), Department: *department}
+		err := change(ctx, user.UserID, grant)
+		if errors.Is(err, repository.ErrUnknownRole) {
+			return fmt.Errorf("no role named %q", grant.Role)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	grants, err := repo.UserRoles(ctx, user.UserID)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, grant := range grants {
+		if grant.Department != "" {
+			names = append(names, fmt.Sprintf("%s (%s)", grant.Role, grant.Department))
+		} else {
+			names = append(names, grant.Role)
+		}
+	}
+	fmt.Printf("%s: %s\n", user.UserName, strings.Join(names, ", "))
+	return nil
+}
@@ -0,0 +1,150 @@
+// Package scheduler applies the changes to users scheduled to take effect
+// later once they are due.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// Scheduler periodically applies the due changes of a repository. Several
+// schedulers may share a database: each change is claimed in the
+// transaction that applies it, so it is applied once.
+type Scheduler struct {
+	users    repository.UserRepository
+	interval time.Duration
+}
+
+// New returns a scheduler that looks for due changes in users every
+// interval.
+func New(users repository.UserRepository, interval time.Duration) *Scheduler {
+	return &Scheduler{users: users, interval: interval}
+}
+
+// Run applies the due changes now and then every interval until ctx is
+// done. Errors are logged and the changes they hit retried on the next run.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		applied, err := s.ApplyDue(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			log.Printf("scheduler: %v", err)
+		}
+		if applied > 0 {
+			log.Printf("scheduler: applied %d scheduled changes", applied)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ApplyDue applies every pending change taking effect at or before now, in
+// the order they take effect, and returns how many it applied. A change
+// that cannot be applied, such as a status the user can no longer move to
+// or a user that has been deleted, is marked failed. Any other error stops
+// the run, leaving the remaining changes pending.
+func (s *Scheduler) ApplyDue(ctx context.Context, now time.Time) (int, error) {
+	// Keep the precision the database stores, so that the audit entries
+	// hash the same once read back.
+	now = now.UTC().Truncate(time.Microsecond)
+	due, err := s.users.ScheduledChanges(ctx, repository.ScheduleFilter{
+		States: []string{models.ChangePending},
+		DueBy:  now,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, change := range due {
+		err := s.apply(ctx, change, now)
+		switch {
+		case err == nil:
+			applied++
+		case errors.Is(err, repository.ErrChangeNotPending), errors.Is(err, repository.ErrChangeNotFound):
+			// Another scheduler or a cancellation got there first.
+		case failed(err):
+			if err := s.fail(ctx, change, now, err); err != nil {
+				return applied, err
+			}
+		default:
+			return applied, fmt.Errorf("applying scheduled change %d: %w", change.ID, err)
+		}
+	}
+	return applied, nil
+}
+
+// apply makes change to its user and marks it applied in one transaction,
+// recording the change on behalf of whoever scheduled it.
+func (s *Scheduler) apply(ctx context.Context, change models.ScheduledChange, now time.Time) error {
+	ctx = repository.WithActor(ctx, change.CreatedBy)
+	return s.users.WithTx(ctx, func(tx repository.UserRepository) error {
+		change.State = models.ChangeApplied
+		change.ResolvedAt = &now
+		if err := tx.ResolveScheduledChange(ctx, &change); err != nil {
+			return err
+		}
+
+		before, err := tx.Get(ctx, change.UserID)
+		if err != nil {
+			return err
+		}
+		user := *before
+		var columns []string
+		if change.UserStatus != "" {
+			if err := models.CheckTransition(before.UserStatus, change.UserStatus); err != nil {
+				return err
+			}
+			user.UserStatus = change.UserStatus
+			user.StatusReason = change.Reason
+			user.StatusEffectiveDate = change.EffectiveAt.UTC().Format(models.DateLayout)
+			columns = append(columns, repository.StatusColumns...)
+		}
+		if change.Department != "" {
+			user.Department = change.Department
+			columns = append(columns, "department")
+		}
+		if err := tx.Update(ctx, &user, columns, nil); err != nil {
+			return err
+		}
+
+		entry := models.NewAuditEntry(models.AuditUpdate, before, &user)
+		entry.Actor = change.CreatedBy
+		entry.RequestID = fmt.Sprintf("scheduled-change-%d", change.ID)
+		entry.At = now
+		return tx.RecordAudit(ctx, entry)
+	})
+}
+
+// fail marks change failed because of err.
+func (s *Scheduler) fail(ctx context.Context, change models.ScheduledChange, now time.Time, err error) error {
+	change.State = models.ChangeFailed
+	change.ResolvedAt = &now
+	change.Error = err.Error()
+	err = s.users.ResolveScheduledChange(ctx, &change)
+	if errors.Is(err, repository.ErrChangeNotPending) || errors.Is(err, repository.ErrChangeNotFound) {
+		return nil
+	}
+	return err
+}
+
+// failed reports whether err means that a change can never be applied,
+// rather than that applying it failed this time.
+func failed(err error) bool {
+	var transitionErr *models.TransitionError
+	return errors.As(err, &transitionErr) ||
+		errors.Is(err, repository.ErrNotFound) ||
+		errors.Is(err, repository.ErrInvalidUser) ||
+		errors.Is(err, repository.ErrUnknownDepartment)
+}
@@ -0,0 +1,179 @@
+package scheduler_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sedmo/integra-coding-assessment/go-backend/db/connectors"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+	"github.com/sedmo/integra-coding-assessment/go-backend/scheduler"
+)
+
+func TestScheduler(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Scheduler Suite")
+}
+
+// schedulerBehavior checks the scheduler against the repository returned by
+// open.
+func schedulerBehavior(open func() repository.UserRepository) {
+	var (
+		repo  repository.UserRepository
+		ctx   context.Context
+		now   time.Time
+		user  *models.User
+		sched *scheduler.Scheduler
+	)
+
+	schedule := func(status, department, reason string, in time.Duration) *models.ScheduledChange {
+		change := &models.ScheduledChange{
+			UserID:      user.UserID,
+			UserStatus:  status,
+			Department:  department,
+			Reason:      reason,
+			EffectiveAt: now.Add(in),
+			State:       models.ChangePending,
+			CreatedBy:   "alice",
+			CreatedAt:   now,
+		}
+		Expect(repo.ScheduleChange(ctx, change)).To(Succeed())
+		return change
+	}
+
+	changeState := func(change *models.ScheduledChange) string {
+		stored, err := repo.ScheduledChange(ctx, change.ID)
+		Expect(err).NotTo(HaveOccurred())
+		return stored.State
+	}
+
+	BeforeEach(func() {
+		repo = open()
+		ctx = context.Background()
+		now = time.Now().UTC().Truncate(time.Microsecond)
+		for _, name := range []string{"Engineering", "HR", "Sales"} {
+			Expect(repo.CreateDepartment(ctx, &models.Department{Code: name, Name: name})).To(Succeed())
+		}
+		user = &models.User{UserName: "jdoe", FirstName: "John", LastName: "Doe", Email: "jdoe@example.com", UserStatus: "A", Department: "Engineering"}
+		Expect(repo.Create(ctx, user)).To(Succeed())
+		sched = scheduler.New(repo, time.Minute)
+	})
+
+	It("should apply the due changes in the order they take effect", func() {
+		move := schedule("", "Sales", "", time.Hour)
+		deactivate := schedule("I", "", "Sabbatical", 2*time.Hour)
+		terminate := schedule("T", "", "", 3*time.Hour)
+
+		applied, err := sched.ApplyDue(ctx, now.Add(2*time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied).To(Equal(2))
+		Expect(changeState(move)).To(Equal(models.ChangeApplied))
+		Expect(changeState(deactivate)).To(Equal(models.ChangeApplied))
+		Expect(changeState(terminate)).To(Equal(models.ChangePending))
+
+		stored, err := repo.Get(ctx, user.UserID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stored.Department).To(Equal("Sales"))
+		Expect(stored.UserStatus).To(Equal(models.StatusInactive))
+		Expect(stored.StatusReason).To(Equal("Sabbatical"))
+		Expect(stored.StatusEffectiveDate).To(Equal(deactivate.EffectiveAt.Format(models.DateLayout)))
+
+		entries, total, err := repo.AuditLog(ctx, repository.AuditFilter{UserID: user.UserID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(total).To(Equal(int64(2)))
+		Expect(entries[0].Actor).To(Equal("alice"))
+		Expect(entries[0].Changes).To(HaveKeyWithValue("user_status", models.FieldChange{Old: "A", New: "I"}))
+		Expect(entries[1].Changes).To(Equal(map[string]models.FieldChange{"department": {Old: "Engineering", New: "Sales"}}))
+
+		applied, err = sched.ApplyDue(ctx, now.Add(2*time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied).To(BeZero())
+	})
+
+	It("should mark failed the changes that can no longer be applied", func() {
+		reactivate := schedule("A", "", "", time.Hour)
+		move := schedule("", "Sales", "", time.Hour)
+		cancelled := schedule("T", "", "", time.Hour)
+		cancelled.State = models.ChangeCancelled
+		Expect(repo.ResolveScheduledChange(ctx, cancelled)).To(Succeed())
+
+		applied, err := sched.ApplyDue(ctx, now.Add(time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied).To(Equal(1))
+		failed, err := repo.ScheduledChange(ctx, reactivate.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(failed.State).To(Equal(models.ChangeFailed))
+		Expect(failed.Error).To(Equal("user is already active"))
+		Expect(failed.ResolvedAt).NotTo(BeNil())
+		Expect(changeState(move)).To(Equal(models.ChangeApplied))
+		Expect(changeState(cancelled)).To(Equal(models.ChangeCancelled))
+
+		stored, err := repo.Get(ctx, user.UserID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stored.UserStatus).To(Equal(models.StatusActive))
+		Expect(stored.Version).To(Equal(int64(2)))
+	})
+
+	It("should fail the changes of a deleted user", func() {
+		change := schedule("I", "", "", time.Hour)
+		Expect(repo.Delete(ctx, user.UserID, nil)).To(Succeed())
+
+		applied, err := sched.ApplyDue(ctx, now.Add(time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied).To(BeZero())
+		Expect(changeState(change)).To(Equal(models.ChangeFailed))
+	})
+
+	It("should fail a move to a department deleted since", func() {
+		change := schedule("", "HR", "", time.Hour)
+		hr, err := repo.DepartmentByName(ctx, "HR")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(repo.DeleteDepartment(ctx, hr.ID)).To(Succeed())
+
+		applied, err := sched.ApplyDue(ctx, now.Add(time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied).To(BeZero())
+		Expect(changeState(change)).To(Equal(models.ChangeFailed))
+	})
+
+	It("should keep applying changes until stopped", func() {
+		change := schedule("I", "", "", 0)
+		runCtx, stop := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			scheduler.New(repo, 10*time.Millisecond).Run(runCtx)
+		}()
+
+		Eventually(func() string { return changeState(change) }).Should(Equal(models.ChangeApplied))
+		stop()
+		Eventually(done).Should(BeClosed())
+	})
+}
+
+var _ = Describe("Scheduler (in memory)", func() {
+	schedulerBehavior(func() repository.UserRepository {
+		return repository.NewMemoryUserRepository()
+	})
+})
+
+var _ = Describe("Scheduler (SQLite)", func() {
+	var database *sql.DB
+
+	AfterEach(func() {
+		database.Close()
+	})
+
+	schedulerBehavior(func() repository.UserRepository {
+		connector := &connectors.SQLiteConnector{}
+		var err error
+		database, err = connector.Open("sqlite://:memory:")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(connector.RunMigrationsUp(database)).To(Succeed())
+		return repository.NewSQLiteUserRepository(database)
+	})
+})
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"log"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/db"
+	"github.com/sedmo/integra-coding-assessment/go-backend/db/seeds"
+)
+
+func seedCommand(args []string) error {
+	database, err := db.InitDB(newConnector())
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if err := seeds.Run(database); err != nil {
+		return err
+	}
+	log.Println("Sample users seeded")
+	return nil
+}
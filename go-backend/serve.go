@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	echoSwagger "github.com/swaggo/echo-swagger"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/auth"
+	"github.com/sedmo/integra-coding-assessment/go-backend/handlers"
+	"github.com/sedmo/integra-coding-assessment/go-backend/scheduler"
+)
+
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":1323", "address to listen on")
+	scheduleInterval := flags.Duration("schedule-interval", time.Minute, "how often to apply due scheduled changes, or 0 to leave them to another instance")
+	flags.Parse(args)
+
+	e := echo.New()
+	e.HTTPErrorHandler = handlers.HTTPErrorHandler
+
+	e.Use(middleware.RequestID())
+	e.Use(middleware.Logger())
+	e.Use(middleware.Recover())
+
+	// Enable CORS
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins:  []string{"http://localhost:4200"},
+		AllowMethods:  []string{echo.GET, echo.POST, echo.PUT, echo.PATCH, echo.DELETE},
+		ExposeHeaders: []string{"ETag", echo.HeaderWWWAuthenticate, echo.HeaderXRequestID},
+	}))
+
+	authentication, err := authConfig()
+	if err != nil {
+		return err
+	}
+
+	repo, closeRepo, err := openUserRepository()
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	authentication.APIKeys = apiKeyStore{repo}
+	e.Use(auth.Middleware(authentication))
+
+	e.GET("/swagger/*", echoSwagger.WrapHandler)
+	e.GET("/healthz", handlers.Health)
+
+	// Routes
+	api := e.Group("", auth.Authorize(subjectRoles(repo)))
+	handlers.RegisterRoutes(api, handlers.NewUserHandler(repo), handlers.NewRoleHandler(repo), handlers.NewAPIKeyHandler(repo), handlers.NewAuditHandler(repo), handlers.NewDepartmentHandler(repo))
+
+	// Apply scheduled changes in the background while serving.
+	if *scheduleInterval > 0 {
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		go scheduler.New(repo, *scheduleInterval).Run(ctx)
+	}
+
+	return e.Start(*addr)
+}
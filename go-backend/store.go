@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/db"
+	"github.com/sedmo/integra-coding-assessment/go-backend/db/connectors"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+)
+
+// openUserRepository returns the user repository selected by DATABASE_URL
+// along with a function that releases it. DATABASE_URL=memory:// keeps users
+// in memory for tests and local development; anything else is handed to the
+// matching database connector and migrated.
+func openUserRepository() (repository.UserRepository, func() error, error) {
+	if strings.HasPrefix(os.Getenv("DATABASE_URL"), repository.MemoryURL) {
+		log.Println("Using the in-memory user store; data is lost on exit")
+		return repository.NewMemoryUserRepository(), func() error { return nil }, nil
+	}
+
+	connector := newConnector()
+	database, err := db.InitDB(connector)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := connector.(*connectors.SQLiteConnector); ok {
+		return repository.NewSQLiteUserRepository(database), database.Close, nil
+	}
+	return repository.NewPostgresUserRepository(database), database.Close, nil
+}
@@ -0,0 +1,199 @@
+// Package userio reads and writes users in the JSON and CSV formats used by
+// the users import and export commands.
+package userio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+)
+
+// Format is a file format supported for import and export.
+type Format string
+
+const (
+	JSON Format = "json"
+	CSV  Format = "csv"
+)
+
+// csvHeader is the header row written by the CSV writer. The reader accepts
+// these columns in any order; user_id is optional.
+var csvHeader = []string{"user_id", "user_name", "first_name", "last_name", "email", "user_status", "department"}
+
+// ParseFormat validates a format name given on the command line.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case JSON, CSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported format %q, expected json or csv", name)
+}
+
+// FormatFromPath guesses the format of a file from its extension, defaulting
+// to JSON.
+func FormatFromPath(path string) Format {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return CSV
+	}
+	return JSON
+}
+
+// Read decodes every user in r. JSON input must be an array of user objects.
+func Read(r io.Reader, format Format) ([]models.User, error) {
+	switch format {
+	case JSON:
+		var users []models.User
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&users); err != nil {
+			return nil, err
+		}
+		return users, nil
+	case CSV:
+		return readCSV(r)
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+func readCSV(r io.Reader) ([]models.User, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if !isCSVColumn(name) {
+			return nil, fmt.Errorf("unknown csv column %q", name)
+		}
+		columns[name] = i
+	}
+
+	var users []models.User
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return users, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		user := models.User{
+			UserName:   field("user_name"),
+			FirstName:  field("first_name"),
+			LastName:   field("last_name"),
+			Email:      field("email"),
+			UserStatus: field("user_status"),
+			Department: field("department"),
+		}
+		if id := field("user_id"); id != "" {
+			if user.UserID, err = strconv.ParseInt(id, 10, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid user_id %q", line, id)
+			}
+		}
+		users = append(users, user)
+	}
+}
+
+func isCSVColumn(name string) bool {
+	for _, column := range csvHeader {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Writer streams users to an output one at a time.
+type Writer interface {
+	Write(user models.User) error
+	// Close finishes the document. It does not close the underlying writer.
+	Close() error
+}
+
+// NewWriter returns a Writer producing format on w.
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case JSON:
+		return &jsonWriter{w: w}, nil
+	case CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// jsonWriter writes a JSON array without holding every user in memory.
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonWriter) Write(user models.User) error {
+	sep := ",\n  "
+	if j.count == 0 {
+		sep = "[\n  "
+	}
+	raw, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(j.w, sep); err != nil {
+		return err
+	}
+	_, err = j.w.Write(raw)
+	j.count++
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	end := "\n]\n"
+	if j.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(j.w, end)
+	return err
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) Write(user models.User) error {
+	return c.w.Write([]string{
+		strconv.FormatInt(user.UserID, 10),
+		user.UserName,
+		user.FirstName,
+		user.LastName,
+		user.Email,
+		user.UserStatus,
+		user.Department,
+	})
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,79 @@
+package userio_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/userio"
+)
+
+func TestUserIO(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "UserIO Suite")
+}
+
+var _ = Describe("UserIO", func() {
+	users := []models.User{
+		{UserID: 1, UserName: "jdoe", FirstName: "John", LastName: "Doe", Email: "john.doe@example.com", UserStatus: "A", Department: "Engineering"},
+		{UserID: 2, UserName: "asmith", FirstName: "Alice", LastName: "Smith, Jr.", Email: "alice.smith@example.com", UserStatus: "I", Department: "Marketing"},
+	}
+
+	roundTrip := func(format userio.Format, in []models.User) []models.User {
+		var buf bytes.Buffer
+		w, err := userio.NewWriter(&buf, format)
+		Expect(err).NotTo(HaveOccurred())
+		for _, u := range in {
+			Expect(w.Write(u)).To(Succeed())
+		}
+		Expect(w.Close()).To(Succeed())
+
+		out, err := userio.Read(&buf, format)
+		Expect(err).NotTo(HaveOccurred())
+		return out
+	}
+
+	It("should round trip users through JSON", func() {
+		Expect(roundTrip(userio.JSON, users)).To(Equal(users))
+	})
+
+	It("should round trip users through CSV", func() {
+		Expect(roundTrip(userio.CSV, users)).To(Equal(users))
+	})
+
+	It("should write an empty JSON array when there are no users", func() {
+		var buf bytes.Buffer
+		w, err := userio.NewWriter(&buf, userio.JSON)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		Expect(buf.String()).To(Equal("[]\n"))
+	})
+
+	It("should read CSV columns in any order without a user_id", func() {
+		in := "department,user_name,first_name,last_name,email,user_status\nSales,bwilson,Bob,Wilson,bob@example.com,A\n"
+		out, err := userio.Read(strings.NewReader(in), userio.CSV)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal([]models.User{
+			{UserName: "bwilson", FirstName: "Bob", LastName: "Wilson", Email: "bob@example.com", UserStatus: "A", Department: "Sales"},
+		}))
+	})
+
+	It("should reject unknown CSV columns", func() {
+		_, err := userio.Read(strings.NewReader("user_name,password\njdoe,secret\n"), userio.CSV)
+		Expect(err).To(MatchError(ContainSubstring("password")))
+	})
+
+	It("should reject unknown JSON fields", func() {
+		_, err := userio.Read(strings.NewReader(`[{"user_name":"jdoe","password":"secret"}]`), userio.JSON)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should pick the format from the file extension", func() {
+		Expect(userio.FormatFromPath("users.CSV")).To(Equal(userio.CSV))
+		Expect(userio.FormatFromPath("users.json")).To(Equal(userio.JSON))
+		Expect(userio.FormatFromPath("users")).To(Equal(userio.JSON))
+	})
+})
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/sedmo/integra-coding-assessment/go-backend/models"
+	"github.com/sedmo/integra-coding-assessment/go-backend/repository"
+	"github.com/sedmo/integra-coding-assessment/go-backend/userio"
+)
+
+// exportBatchSize is the number of users read from the database at a time
+// by users export.
+const exportBatchSize = 500
+
+func usersCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: users import|export|purge")
+	}
+
+	switch sub, args := args[0], args[1:]; sub {
+	case "import":
+		return importUsers(args)
+	case "export":
+		return exportUsers(args)
+	case "purge":
+		return purgeUsers(args)
+	default:
+		return fmt.Errorf("unknown users command %q", sub)
+	}
+}
+
+// importUsers validates every record in a file with the same rules as the
+// API and inserts them in a single transaction, so a bad record leaves the
+// database untouched.
+func importUsers(args []string) error {
+	flags := flag.NewFlagSet("users import", flag.ExitOnError)
+	formatName := flags.String("format", "", "json or csv (default: from the file extension)")
+	skipExisting := flags.Bool("skip-existing", false, "skip users whose user name already exists instead of failing")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: users import [-format json|csv] [-skip-existing] <file>")
+	}
+	path := flags.Arg(0)
+
+	format := userio.FormatFromPath(path)
+	if *formatName != "" {
+		var err error
+		if format, err = userio.ParseFormat(*formatName); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	users, err := userio.Read(f, format)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var invalid []error
+	for i := range users {
+		if err := users[i].Validate(); err != nil {
+			var validationErrors validator.ValidationErrors
+			if errors.As(err, &validationErrors) {
+				err = validationErrors
+			}
+			invalid = append(invalid, fmt.Errorf("record %d (%s): %w", i+1, users[i].UserName, err))
+		}
+	}
+	if len(invalid) > 0 {
+		return errors.Join(invalid...)
+	}
+
+	repo, closeRepo, err := openUserRepository()
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	ctx := repository.WithActor(context.Background(), cliActor())
+	imported := 0
+	err = repo.WithTx(ctx, func(tx repository.UserRepository) error {
+		for i := range users {
+			user := &users[i]
+			// Ids are assigned afresh, so a manager_id from another database
+			// would point at the wrong user; reporting lines are set up again
+			// through the API.
+			user.ManagerID = nil
+			if *skipExisting {
+				// Earlier records of the same file are visible inside the
+				// transaction, so duplicates within the file are skipped too.
+				_, err := tx.GetByUsername(ctx, user.UserName)
+				if err == nil {
+					continue
+				}
+				if !errors.Is(err, repository.ErrNotFound) {
+					return fmt.Errorf("record %d: %w", i+1, err)
+				}
+			}
+
+			err := tx.Create(ctx, user)
+			if errors.Is(err, repository.ErrUserNameTaken) {
+				return fmt.Errorf("record %d: user name %q already exists", i+1, user.UserName)
+			}
+			if errors.Is(err, repository.ErrUnknownDepartment) {
+				return fmt.Errorf("record %d: department %q does not exist", i+1, user.Department)
+			}
+			if err != nil {
+				return fmt.Errorf("record %d: %w", i+1, err)
+			}
+			entry := models.NewAuditEntry(models.AuditCreate, nil, user)
+			entry.Actor = cliActor()
+			entry.At = time.Now().UTC().Truncate(time.Microsecond)
+			if err := tx.RecordAudit(ctx, entry); err != nil {
+				return fmt.Errorf("record %d: %w", i+1, err)
+			}
+			imported++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Imported %d users, skipped %d", imported, len(users)-imported)
+	return nil
+}
+
+// cliActor is the actor recorded in the audit log for changes made from the
+// command line.
+func cliActor() string {
+	if name := os.Getenv("USER"); name != "" {
+		return "cli:" + name
+	}
+	return "cli"
+}
+
+func exportUsers(args []string) error {
+	flags := flag.NewFlagSet("users export", flag.ExitOnError)
+	formatName := flags.String("format", "", "json or csv (default: from -o, else json)")
+	output := flags.String("o", "", "file to write to (default: stdout)")
+	flags.Parse(args)
+
+	format := userio.FormatFromPath(*output)
+	if *formatName != "" {
+		var err error
+		if format, err = userio.ParseFormat(*formatName); err != nil {
+			return err
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	users, closeRepo, err := openUserRepository()
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	w, err := userio.NewWriter(out, format)
+	if err != nil {
+		return err
+	}
+
+	// Page through the users by id so that large tables are streamed rather
+	// than loaded into memory at once.
+	ctx := context.Background()
+	opts := repository.ListOptions{
+		Sort:  []repository.SortField{{Column: "user_id"}},
+		Limit: exportBatchSize,
+	}
+	for {
+		batch, _, err := users.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for _, user := range batch {
+			if err := w.Write(user); err != nil {
+				return err
+			}
+		}
+		if uint64(len(batch)) < opts.Limit {
+			break
+		}
+		last := batch[len(batch)-1]
+		opts.Cursor = &repository.Cursor{Keys: map[string]interface{}{"user_id": last.UserID}}
+	}
+	return w.Close()
+}
+
+// purgeUsers permanently removes the users that were soft deleted longer ago
+// than the retention period.
+func purgeUsers(args []string) error {
+	flags := flag.NewFlagSet("users purge", flag.ExitOnError)
+	olderThan := flags.Duration("older-than", 30*24*time.Hour, "purge users deleted longer ago than this")
+	flags.Parse(args)
+	if flags.NArg() != 0 || *olderThan < 0 {
+		return errors.New("usage: users purge [-older-than duration]")
+	}
+
+	users, closeRepo, err := openUserRepository()
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	purged, err := users.Purge(context.Background(), time.Now().Add(-*olderThan))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Purged %d users deleted more than %s ago", purged, *olderThan)
+	return nil
+}